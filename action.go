@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// summaryFilename is the run summary written by the action command when
+// -summary is not set.
+const summaryFilename = "summary.json"
+
+// action runs like run inside a GitHub Actions job. Flags are also read
+// from the step inputs, and the output paths and a job summary are
+// written where the runner expects them.
+func action(ctx context.Context) error {
+	err := flagsFromInputs(flag.CommandLine)
+	if err != nil {
+		return err
+	}
+	sum := newSummary()
+	err = runSummary(ctx, sum)
+	if err != nil {
+		sum.Errors = append(sum.Errors, err.Error())
+	}
+	sumFile := *summaryOut
+	if len(sumFile) == 0 || sumFile == "-" {
+		sumFile = filepath.Join(outputDir, summaryFilename)
+	}
+	werr := sum.write(sumFile)
+	if werr == nil {
+		werr = writeActionOutputs(sumFile)
+	}
+	if werr == nil {
+		werr = appendEnvFile("GITHUB_STEP_SUMMARY", func(w io.Writer) error {
+			return sum.markdown(w)
+		})
+	}
+	if err == nil {
+		err = werr
+	}
+	return runStatus(sum, err)
+}
+
+// flagsFromInputs sets the flags of fs not given on the command line from
+// the step inputs. The runner passes input cache-dir as INPUT_CACHE-DIR;
+// INPUT_CACHE_DIR, which shells can set, is read too.
+func flagsFromInputs(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		name := "INPUT_" + strings.ToUpper(f.Name)
+		v := strings.TrimSpace(os.Getenv(name))
+		if len(v) == 0 {
+			v = strings.TrimSpace(os.Getenv(strings.ReplaceAll(name, "-", "_")))
+		}
+		if len(v) == 0 {
+			return
+		}
+		if serr := fs.Set(f.Name, v); serr != nil {
+			err = fmt.Errorf("input %s: %w", f.Name, serr)
+		}
+	})
+	return err
+}
+
+// writeActionOutputs sets the charts-path and summary-json step outputs.
+func writeActionOutputs(sumFile string) error {
+	charts, err := filepath.Abs(outputDir)
+	if err != nil {
+		return err
+	}
+	sumFile, err = filepath.Abs(sumFile)
+	if err != nil {
+		return err
+	}
+	return appendEnvFile("GITHUB_OUTPUT", func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "charts-path=%s\nsummary-json=%s\n", charts, sumFile)
+		return err
+	})
+}
+
+// appendEnvFile appends to the file named by the environment variable
+// key, doing nothing outside of a runner.
+func appendEnvFile(key string, write func(w io.Writer) error) error {
+	name := os.Getenv(key)
+	if len(name) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// markdown writes the summary as a Markdown job summary.
+func (s *summary) markdown(w io.Writer) error {
+	newCommits := 0
+	for _, rs := range s.Repos {
+		newCommits += rs.NewCommits
+	}
+	fmt.Fprintf(w, "## gitgraph\n\n%d repositories, %d new commits", len(s.Repos), newCommits)
+	if n := s.failed(); n > 0 {
+		fmt.Fprintf(w, ", %d failed", n)
+	}
+	fmt.Fprint(w, ".\n\n| Repository | Commits | New | Charts | Status |\n|---|--:|--:|--:|---|\n")
+	for _, rs := range s.Repos {
+		status := "ok"
+		switch {
+		case len(rs.Error) > 0:
+			status = "failed: " + rs.Error
+		case rs.Change != nil:
+			status = rs.Change.Message
+		}
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %s |\n", markdownCell(rs.Name), rs.Commits, rs.NewCommits, len(rs.Charts), markdownCell(status))
+	}
+	if len(s.Alerts) > 0 {
+		fmt.Fprint(w, "\n### Alerts\n\n")
+		for _, a := range s.Alerts {
+			fmt.Fprintf(w, "- **%s** %s: %s\n", markdownCell(a.Rule), markdownCell(a.Name), markdownCell(a.Message))
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// markdownCell escapes s for a table cell on one line.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
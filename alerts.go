@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// alertRule raises an alert for a repository. Idle alerts when the last
+// commit is older than Idle. Below alerts when each of the last Weeks
+// completed weeks has fewer than Below commits.
+type alertRule struct {
+	Name  string
+	Idle  duration `json:",omitempty"`
+	Below float64  `json:",omitempty"`
+	Weeks int      `json:",omitempty"` // Defaults to 4.
+}
+
+type alert struct {
+	Rule    string
+	URL     string
+	Name    string
+	Message string
+}
+
+func (r alertRule) check(ch *chart, now time.Time) (string, bool) {
+	var last time.Time
+	for _, c := range ch.Commits {
+		if c.When.After(last) && !c.When.After(now) {
+			last = c.When
+		}
+	}
+	if r.Idle > 0 && now.Sub(last) >= time.Duration(r.Idle) {
+		if last.IsZero() {
+			return "no commits", true
+		}
+		return fmt.Sprintf("no commits since %s", last.Format("2006-01-02")), true
+	}
+	if r.Below > 0 {
+		weeks := r.Weeks
+		if weeks <= 0 {
+			weeks = 4
+		}
+		const week = 7 * 24 * time.Hour
+		end := history.Weekly.Start(now)
+		start := end.Add(-time.Duration(weeks) * week)
+		counts := make([]int, weeks)
+		for _, c := range ch.Commits {
+			if c.When.Before(start) || !c.When.Before(end) {
+				continue
+			}
+			counts[int(c.When.Sub(start)/week)]++
+		}
+		for _, n := range counts {
+			if float64(n) >= r.Below {
+				return "", false
+			}
+		}
+		return fmt.Sprintf("fewer than %g commits a week for %d weeks", r.Below, weeks), true
+	}
+	return "", false
+}
+
+// checkAlerts returns the alerts raised for the repository u.
+func (cfg *config) checkAlerts(u string, ch *chart, now time.Time) []alert {
+	rules := cfg.Alerts
+	if rc := cfg.Repos[u]; rc != nil && rc.Alerts != nil {
+		rules = rc.Alerts
+	}
+	var list []alert
+	for _, r := range rules {
+		msg, ok := r.check(ch, now)
+		if !ok {
+			continue
+		}
+		list = append(list, alert{Rule: r.Name, URL: u, Name: ch.Name, Message: msg})
+	}
+	return list
+}
+
+// notification is posted to the configured webhook.
+type notification struct {
+	Title   string
+	Time    time.Time
+	Alerts  []alert
+	Changes []*repoSummary
+}
+
+// notify posts the alerts and changes of a run to the webhook as JSON. It
+// posts nothing if there are neither.
+func notify(ctx context.Context, webhook, title string, sum *summary) error {
+	n := notification{Title: title, Time: sum.Start, Alerts: sum.Alerts}
+	for _, rs := range sum.Repos {
+		if rs.Change != nil {
+			n.Changes = append(n.Changes, rs)
+		}
+	}
+	if len(webhook) == 0 || (len(n.Alerts) == 0 && len(n.Changes) == 0) {
+		return nil
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var annotationsFile = flag.String("annotations", "", "CSV file of date,label[,repository] events to mark on charts")
+
+// annotation is an event marked on charts. Repo is a repository URL or
+// name, or empty for all repositories.
+type annotation struct {
+	Time  time.Time
+	Label string
+	Repo  string `json:",omitempty"`
+}
+
+var handoffNotes = flag.Bool("handoffs", false, "annotate the charts with the quarters the author with the most commits changed")
+
+// handoffAnnotations returns the handoffs of rr as annotations if
+// -handoffs is set.
+func handoffAnnotations(rr *reportRepo) []annotation {
+	if !*handoffNotes {
+		return nil
+	}
+	var list []annotation
+	for _, h := range rr.Handoffs {
+		list = append(list, annotation{Time: h.Time, Label: "to " + h.To.Name})
+	}
+	return list
+}
+
+// loadAnnotations reads the annotations file. Lines starting with # are
+// comments; dates are 2006-01-02 or RFC 3339.
+func loadAnnotations(fn string) ([]annotation, error) {
+	if len(fn) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var list []annotation
+	for n := 1; ; n++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		if len(rec) < 2 || len(rec) > 3 {
+			return nil, fmt.Errorf("%s: record %d: want date,label[,repository]", fn, n)
+		}
+		t, err := parseTime(strings.TrimSpace(rec[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w", fn, n, err)
+		}
+		a := annotation{Time: t, Label: strings.TrimSpace(rec[1])}
+		if len(rec) == 3 {
+			a.Repo = strings.TrimSpace(rec[2])
+		}
+		list = append(list, a)
+	}
+}
+
+// annotationsFor returns the annotations of the repository u or group
+// called name.
+func annotationsFor(all []annotation, u, name string) []annotation {
+	var list []annotation
+	for _, a := range all {
+		if len(a.Repo) == 0 || a.Repo == u || strings.EqualFold(a.Repo, name) {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// addAnnotations draws a labeled vertical line for each annotation between
+// min and max on the X axis, in Unix seconds.
+func addAnnotations(p *plot.Plot, notes []annotation, min, max, top float64) error {
+	labels := plotter.XYLabels{}
+	for _, a := range notes {
+		x := float64(a.Time.Unix())
+		if x < min || x > max {
+			continue
+		}
+		line, err := plotter.NewLine(plotter.XYs{{X: x, Y: 0}, {X: x, Y: top}})
+		if err != nil {
+			return err
+		}
+		line.Color = color.RGBA{R: 0x60, G: 0x60, B: 0x60, A: 0xff}
+		line.Dashes = []vg.Length{vg.Points(4), vg.Points(3)}
+		p.Add(line)
+		labels.XYs = append(labels.XYs, plotter.XY{X: x, Y: top})
+		labels.Labels = append(labels.Labels, a.Label)
+	}
+	if len(labels.Labels) == 0 {
+		return nil
+	}
+	l, err := plotter.NewLabels(labels)
+	if err != nil {
+		return err
+	}
+	for i := range l.TextStyle {
+		l.TextStyle[i].Rotation = -1.5708 // Read top to bottom along the line.
+		l.TextStyle[i].XAlign = draw.XLeft
+		l.TextStyle[i].YAlign = draw.YBottom
+	}
+	l.XOffset, l.YOffset = vg.Points(2), vg.Points(-2)
+	p.Add(l)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var anomalyMADs = flag.Float64("anomalies", 0, "mark windows whose value is more than this many median absolute deviations from the median, and list recent ones in the run summary; 0 disables")
+
+// anomalyRecent is how far back anomalies are listed in the summary.
+const anomalyRecent = 90 * 24 * time.Hour
+
+// anomaly is a window whose value is far from the usual.
+type anomaly struct {
+	Metric string
+	Time   time.Time
+	Value  float64
+	// Score is the distance from the median in median absolute
+	// deviations, negative below it.
+	Score float64
+}
+
+var anomalyColor = color.RGBA{R: 0xd6, G: 0x27, B: 0x28, A: 0xff}
+
+// findAnomalies returns the points of data, with windows without activity
+// as zero, further than -anomalies median absolute deviations from the
+// median. The deviation is scaled to match the standard deviation of
+// normal data.
+func findAnomalies(metric string, data plotter.XYs, w history.Window) []anomaly {
+	if *anomalyMADs <= 0 {
+		return nil
+	}
+	filled := fillWindows(data, w)
+	if len(filled) < burstMinWindows {
+		return nil
+	}
+	values := make([]float64, len(filled))
+	for i, pt := range filled {
+		values[i] = pt.Y
+	}
+	sort.Float64s(values)
+	median := quantile(values, 0.5)
+	for i, v := range values {
+		values[i] = math.Abs(v - median)
+	}
+	sort.Float64s(values)
+	mad := 1.4826 * quantile(values, 0.5)
+	if mad == 0 {
+		return nil
+	}
+	var list []anomaly
+	for _, pt := range filled {
+		score := (pt.Y - median) / mad
+		if math.Abs(score) > *anomalyMADs {
+			list = append(list, anomaly{
+				Metric: metric,
+				Time:   time.Unix(int64(pt.X), 0).UTC(),
+				Value:  pt.Y,
+				Score:  score,
+			})
+		}
+	}
+	return list
+}
+
+// addAnomalies circles the anomalies on p, at limit if they are above a
+// nonzero limit.
+func addAnomalies(p *plot.Plot, list []anomaly, limit float64) error {
+	if len(list) == 0 {
+		return nil
+	}
+	pts := make(plotter.XYs, len(list))
+	for i, a := range list {
+		pts[i] = plotter.XY{X: float64(a.Time.Unix()), Y: a.Value}
+		if limit > 0 && a.Value > limit {
+			pts[i].Y = limit
+		}
+	}
+	s, err := plotter.NewScatter(pts)
+	if err != nil {
+		return err
+	}
+	s.GlyphStyle = draw.GlyphStyle{Color: anomalyColor, Radius: vg.Points(4), Shape: draw.RingGlyph{}}
+	p.Add(s)
+	p.Legend.Add("anomaly", s)
+	p.Legend.Top = true
+	return nil
+}
+
+// recentAnomalies returns the anomalies of each metric of ch in the
+// windows of the last 90 days.
+func recentAnomalies(ch *chart, metrics []string, opt chartOptions) ([]anomaly, error) {
+	if *anomalyMADs <= 0 {
+		return nil, nil
+	}
+	_, w, err := opt.lookupWindow()
+	if err != nil {
+		return nil, err
+	}
+	now := renderTime()
+	var list []anomaly
+	for _, metric := range metrics {
+		data, err := seriesPoints(ch, metric, w, opt.cal, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range findAnomalies(metric, data, w) {
+			if now.Sub(a.Time) <= anomalyRecent {
+				list = append(list, a)
+			}
+		}
+	}
+	return list, nil
+}
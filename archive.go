@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// An archive keeps all commits of a repository for the long term, apart
+// from the cache. It is a sequence of blocks of up to archiveBlockSize
+// commits, each an independent gzip member holding the commits column by
+// column, followed by a JSON index of the blocks and its length as eight
+// bytes big endian. A reader seeks to the index at the end and then to any
+// block without reading the others.
+
+const (
+	archiveDir       = "archive"
+	archiveExt       = ".gga"
+	archiveBlockSize = 4096
+)
+
+// archiveVersion 2 archives keep the whole state of the shard, not only
+// the commits.
+const archiveVersion = 2
+
+// archiveIndex ends an archive file.
+type archiveIndex struct {
+	Version int `json:",omitempty"`
+	URL     string
+	Name    string
+	Ref     string `json:",omitempty"`
+	Tip     string `json:",omitempty"`
+	Tool    string `json:",omitempty"`
+	Fetched time.Time
+	// Truncated, Generated, Branches, Backports and LargeFile are those
+	// of the chart.
+	Truncated time.Time  `json:",omitempty"`
+	Generated []string   `json:",omitempty"`
+	Branches  []branch   `json:",omitempty"`
+	Backports []backport `json:",omitempty"`
+	LargeFile int64      `json:",omitempty"`
+	Blocks    []archiveBlockInfo
+}
+
+type archiveBlockInfo struct {
+	Offset, Length int64
+	Count          int
+	// First and Last are the times of the oldest and newest commit.
+	First, Last time.Time
+}
+
+// archiveBlock holds the fields of its commits as columns, which compress
+// better than rows.
+type archiveBlock struct {
+	Hash    []string
+	When    []time.Time
+	Author  []string
+	Email   []string
+	Signed  []bool
+	Message []string
+	Files   [][]history.File
+}
+
+func (b *archiveBlock) add(c history.Commit) {
+	b.Hash = append(b.Hash, c.Hash)
+	b.When = append(b.When, c.When)
+	b.Author = append(b.Author, c.Author)
+	b.Email = append(b.Email, c.Email)
+	b.Signed = append(b.Signed, c.Signed)
+	b.Message = append(b.Message, c.Message)
+	b.Files = append(b.Files, c.Files)
+}
+
+func (b *archiveBlock) commits() ([]history.Commit, error) {
+	n := len(b.Hash)
+	if len(b.When) != n || len(b.Author) != n || len(b.Email) != n || len(b.Signed) != n || len(b.Message) != n || len(b.Files) != n {
+		return nil, errors.New("columns differ in length")
+	}
+	list := make([]history.Commit, n)
+	for i := range list {
+		list[i] = history.Commit{
+			Hash:    b.Hash[i],
+			When:    b.When[i],
+			Author:  b.Author[i],
+			Email:   b.Email[i],
+			Signed:  b.Signed[i],
+			Message: b.Message[i],
+			Files:   b.Files[i],
+		}
+	}
+	return list, nil
+}
+
+// archiveFilename returns the archive file of u, named like its shard.
+func archiveFilename(u string) string {
+	return urlHash(u) + archiveExt
+}
+
+// writeArchive writes the commits of ch, the cached repository u, to
+// location.
+func writeArchive(location, u string, ch *chart) error {
+	return writeFileAtomic(location, func(w io.Writer) error {
+		cw := &countWriter{w: w}
+		ix := archiveIndex{
+			Version:   archiveVersion,
+			URL:       u,
+			Name:      ch.Name,
+			Ref:       ch.Ref,
+			Tip:       ch.Tip,
+			Tool:      ch.Tool,
+			Fetched:   ch.Fetched,
+			Truncated: ch.Truncated,
+			Generated: ch.Generated,
+			Branches:  ch.Branches,
+			Backports: ch.Backports,
+			LargeFile: ch.LargeFile,
+		}
+		for start := 0; start < len(ch.Commits); start += archiveBlockSize {
+			end := start + archiveBlockSize
+			if end > len(ch.Commits) {
+				end = len(ch.Commits)
+			}
+			info := archiveBlockInfo{Offset: cw.n, Count: end - start}
+			var b archiveBlock
+			for _, c := range ch.Commits[start:end] {
+				b.add(c)
+				if info.First.IsZero() || c.When.Before(info.First) {
+					info.First = c.When
+				}
+				if c.When.After(info.Last) {
+					info.Last = c.When
+				}
+			}
+			zw, err := gzip.NewWriterLevel(cw, gzip.BestCompression)
+			if err != nil {
+				return err
+			}
+			err = json.NewEncoder(zw).Encode(b)
+			if err != nil {
+				return err
+			}
+			err = zw.Close()
+			if err != nil {
+				return err
+			}
+			info.Length = cw.n - info.Offset
+			ix.Blocks = append(ix.Blocks, info)
+		}
+		b, err := json.Marshal(ix)
+		if err != nil {
+			return err
+		}
+		b = append(b, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(b[len(b)-8:], uint64(len(b)-8))
+		_, err = cw.Write(b)
+		return err
+	})
+}
+
+// readArchiveIndex reads the index at the end of an archive.
+func readArchiveIndex(f *os.File) (*archiveIndex, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var tail [8]byte
+	if fi.Size() < 8 {
+		return nil, fmt.Errorf("%s: not an archive", f.Name())
+	}
+	_, err = f.ReadAt(tail[:], fi.Size()-8)
+	if err != nil {
+		return nil, err
+	}
+	n := int64(binary.BigEndian.Uint64(tail[:]))
+	if n > fi.Size()-8 {
+		return nil, fmt.Errorf("%s: not an archive", f.Name())
+	}
+	b := make([]byte, n)
+	_, err = f.ReadAt(b, fi.Size()-8-n)
+	if err != nil {
+		return nil, err
+	}
+	ix := &archiveIndex{}
+	err = json.Unmarshal(b, ix)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return ix, nil
+}
+
+// readArchiveBlock reads the commits of one block of an archive.
+func readArchiveBlock(f *os.File, info archiveBlockInfo) ([]history.Commit, error) {
+	zr, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(f, info.Offset, info.Length)))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var b archiveBlock
+	err = json.NewDecoder(zr).Decode(&b)
+	if err != nil {
+		return nil, err
+	}
+	list, err := b.commits()
+	if err != nil {
+		return nil, err
+	}
+	if len(list) != info.Count {
+		return nil, fmt.Errorf("block at %d has %d commits, not %d", info.Offset, len(list), info.Count)
+	}
+	return list, nil
+}
+
+// archive writes an archive of each selected cached repository:
+//
+//	gitgraph archive [DIR]
+func archive(ctx context.Context) error {
+	dir, err := archiveArgs("archive")
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	err = cfg.selectRepos(nil)
+	if err != nil {
+		return err
+	}
+	charts, err := readCache(cfg)
+	if err != nil {
+		return err
+	}
+	for _, u := range charts.urls() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ch := charts[u]
+		if len(ch.Commits) == 0 {
+			continue
+		}
+		fn := filepath.Join(dir, archiveFilename(u))
+		err = writeArchive(fn, u, ch)
+		if err != nil {
+			return fmt.Errorf("%s: %w", u, err)
+		}
+		fmt.Printf("%s: %d commits to %s\n", ch.Name, len(ch.Commits), fn)
+	}
+	return nil
+}
+
+// restore reads the archives of the selected repositories back into the
+// cache, replacing their cached commits and the state stored with them:
+//
+//	gitgraph restore [DIR]
+func restore(ctx context.Context) error {
+	dir, err := archiveArgs("restore")
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	err = cfg.selectRepos(nil)
+	if err != nil {
+		return err
+	}
+	charts := cfg.charts()
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlockCache(lock)
+
+	for _, u := range charts.urls() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fn := filepath.Join(dir, archiveFilename(u))
+		ch := charts[u]
+		err = loadShard(u, ch)
+		if err != nil {
+			return err
+		}
+		err = restoreArchive(fn, u, ch)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = charts.Save(u)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: restored %d commits from %s\n", ch.Name, len(ch.Commits), fn)
+	}
+	return nil
+}
+
+// restoreArchive reads the archive at location of u into ch. Archives
+// written before version 2 only hold the commits, so the branches and
+// other state already in ch are kept.
+func restoreArchive(location, u string, ch *chart) error {
+	f, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ix, err := readArchiveIndex(f)
+	if err != nil {
+		return err
+	}
+	if ix.URL != u {
+		return fmt.Errorf("%s: holds %q, not %q", location, ix.URL, u)
+	}
+	var commits []history.Commit
+	for _, info := range ix.Blocks {
+		list, err := readArchiveBlock(f, info)
+		if err != nil {
+			return fmt.Errorf("%s: %w", location, err)
+		}
+		commits = append(commits, list...)
+	}
+	ch.Commits = commits
+	ch.Ref = ix.Ref
+	ch.Tip = ix.Tip
+	ch.Tool = ix.Tool
+	ch.Fetched = ix.Fetched
+	if ix.Version >= 2 {
+		ch.Truncated = ix.Truncated
+		ch.Generated = ix.Generated
+		ch.Branches = ix.Branches
+		ch.Backports = ix.Backports
+		ch.LargeFile = ix.LargeFile
+	}
+	return nil
+}
+
+// archiveArgs returns the archive directory given to the command name.
+func archiveArgs(name string) (string, error) {
+	args := flag.Args()[1:]
+	switch len(args) {
+	case 0:
+		return archiveDir, nil
+	case 1:
+		return args[0], nil
+	}
+	return "", fmt.Errorf("usage: %s [DIR]", name)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	const u = "https://example.com/repo.git"
+	day := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	commits := func(n int) []history.Commit {
+		list := make([]history.Commit, n)
+		for i := range list {
+			list[i] = history.Commit{
+				Hash:    strconv.Itoa(i),
+				When:    day.Add(-time.Duration(i) * time.Hour),
+				Author:  "Ann",
+				Email:   "ann@example.com",
+				Signed:  i%2 == 0,
+				Message: "change " + strconv.Itoa(i) + "\n",
+				Files:   []history.File{{Name: "a.go", Add: i, Del: 1}, {Name: "big.bin", Size: 6 << 20, LFS: i%3 == 0}},
+			}
+		}
+		return list
+	}
+	tests := []struct {
+		name string
+		ch   *chart
+	}{
+		{"empty", &chart{Name: "Repo"}},
+		{"one block", &chart{Name: "Repo", Ref: "refs/heads/main", Tip: "0", Tool: "v1", Fetched: day, Commits: commits(3)}},
+		{"several blocks", &chart{Name: "Repo", Fetched: day, Commits: commits(2*archiveBlockSize + 1)}},
+		{"state", &chart{
+			Name:      "Repo",
+			Fetched:   day,
+			Truncated: day.AddDate(-1, 0, 0),
+			Commits:   commits(2),
+			Generated: []string{"*.pb.go"},
+			Branches:  []branch{{Name: "dev", Created: day, Last: day, Author: "Ann", Ahead: 2}},
+			Backports: []backport{{Branch: "release-1", Hash: "b", Source: "1", Time: day}},
+			LargeFile: 1 << 20,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), archiveFilename(u))
+			err := writeArchive(fn, u, tt.ch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &chart{Name: tt.ch.Name}
+			err = restoreArchive(fn, u, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.ch) {
+				t.Errorf("restored\n%+v\nwant\n%+v", got, tt.ch)
+			}
+		})
+	}
+}
+
+func TestRestoreArchiveOtherURL(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "a"+archiveExt)
+	err := writeArchive(fn, "https://example.com/a.git", &chart{Name: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = restoreArchive(fn, "https://example.com/b.git", &chart{})
+	if err == nil {
+		t.Fatal("restored the archive of another repository")
+	}
+}
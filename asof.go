@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var asOfFlag = flag.String("as-of", "", "render the charts as they looked at the end of this date, 2006-01-02, leaving out later commits")
+
+// asOf is the cutoff of -as-of, the midnight UTC after its date, or zero.
+var asOf time.Time
+
+func parseAsOf(s string) error {
+	asOf = time.Time{}
+	if len(s) == 0 {
+		return nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return fmt.Errorf("-as-of: %w", err)
+	}
+	asOf = t.AddDate(0, 0, 1)
+	return nil
+}
+
+// renderTime returns the time the charts are drawn at: the -as-of cutoff
+// if set, else now.
+func renderTime() time.Time {
+	if !asOf.IsZero() {
+		return asOf
+	}
+	return time.Now()
+}
+
+// cutOff leaves out the commits and branches of ch after the -as-of
+// cutoff. The provenance becomes the cutoff and the newest commit kept.
+func cutOff(ch *chart) {
+	if asOf.IsZero() {
+		return
+	}
+	commits := ch.Commits[:0:0]
+	var tip string
+	var last time.Time
+	for _, c := range ch.Commits {
+		if !c.When.Before(asOf) {
+			continue
+		}
+		commits = append(commits, c)
+		if c.When.After(last) {
+			tip, last = c.Hash, c.When
+		}
+	}
+	ch.Commits = commits
+	branches := ch.Branches[:0:0]
+	for _, b := range ch.Branches {
+		if b.Created.Before(asOf) {
+			branches = append(branches, b)
+		}
+	}
+	ch.Branches = branches
+	backports := ch.Backports[:0:0]
+	for _, b := range ch.Backports {
+		if b.Time.Before(asOf) {
+			backports = append(backports, b)
+		}
+	}
+	ch.Backports = backports
+	if ch.Fetched.After(asOf) {
+		ch.Fetched = asOf
+		ch.Tip = tip
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// excluded returns the patterns of files left out of the metrics of ch:
+// those marked generated or vendored in its .gitattributes and the
+// configured ones.
+func (ch *chart) excluded() []string {
+	if len(ch.ignore) == 0 {
+		return ch.Generated
+	}
+	list := append([]string(nil), ch.Generated...)
+	return append(list, ch.ignore...)
+}
+
+var linguistExcluded = map[string]bool{
+	"linguist-generated":      true,
+	"linguist-generated=true": true,
+	"linguist-vendored":       true,
+	"linguist-vendored=true":  true,
+}
+
+// parseAttributes returns the patterns of a .gitattributes file that set
+// linguist-generated or linguist-vendored.
+func parseAttributes(content string) []string {
+	var list []string
+	s := bufio.NewScanner(strings.NewReader(content))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			if linguistExcluded[attr] {
+				list = append(list, fields[0])
+				break
+			}
+		}
+	}
+	return list
+}
+
+// headAttributes reads the generated and vendored patterns of the
+// .gitattributes file at the root of HEAD.
+func headAttributes(r *git.Repository) ([]string, error) {
+	ref, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.File(".gitattributes")
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return parseAttributes(content), nil
+}
+
+// headAttributesCLI is headAttributes for the cli backend.
+func headAttributesCLI(ctx context.Context, dir string) []string {
+	cmd := gitCommand(ctx, "-C", dir, "show", "HEAD:.gitattributes")
+	cmd.Stderr = nil
+	out, err := cmd.Output()
+	if err != nil {
+		// Most repositories have no .gitattributes.
+		return nil
+	}
+	return parseAttributes(string(out))
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authConfig protects the server with basic authentication, an OpenID
+// Connect provider, or both.
+type authConfig struct {
+	// Users maps user names to the hash of their password, as printed by
+	// the passwd command, for basic authentication.
+	Users map[string]string `json:",omitempty"`
+	// OIDC signs users of the dashboard in with an OpenID Connect
+	// provider.
+	OIDC *oidcConfig `json:",omitempty"`
+}
+
+type oidcConfig struct {
+	// Issuer is the URL of the provider, such as
+	// https://accounts.google.com.
+	Issuer   string
+	ClientID string
+	// RedirectURL is the /auth/callback URL of the server as browsers
+	// reach it.
+	RedirectURL string
+	// Emails and Domains are the email addresses and domains allowed to
+	// sign in; any user of the provider if both are empty.
+	Emails  []string `json:",omitempty"`
+	Domains []string `json:",omitempty"`
+}
+
+const (
+	sessionCookie = "gitgraph_session"
+	stateCookie   = "gitgraph_state"
+	// sessionLife is how long a sign in lasts.
+	sessionLife = 7 * 24 * time.Hour
+)
+
+// authenticator checks the requests to the server.
+type authenticator struct {
+	users map[string]passwordHash
+	oidc  *oidcConfig
+
+	mu sync.Mutex
+	// checked holds by user name the HMAC under checkKey of the last
+	// password that matched, so requests skip the slow hash.
+	checked  map[string][]byte
+	checkKey []byte
+
+	clientSecret string
+	// Endpoints of the provider, from its discovery document.
+	authURL, tokenURL, userinfoURL string
+	// key signs the session cookies. It is derived from the client
+	// secret, so sessions outlive restarts.
+	key    []byte
+	secure bool
+}
+
+// newAuthenticator returns the authenticator of ac, or nil if ac is nil.
+func newAuthenticator(ctx context.Context, ac *authConfig) (*authenticator, error) {
+	if ac == nil {
+		return nil, nil
+	}
+	a := &authenticator{users: map[string]passwordHash{}, oidc: ac.OIDC}
+	for name, s := range ac.Users {
+		h, err := parsePasswordHash(s)
+		if err != nil {
+			return nil, fmt.Errorf("config Auth: user %q: %w", name, err)
+		}
+		a.users[name] = h
+	}
+	if a.oidc == nil {
+		if len(a.users) == 0 {
+			return nil, errors.New("config Auth: no Users or OIDC")
+		}
+		return a, nil
+	}
+	if len(a.oidc.Issuer) == 0 || len(a.oidc.ClientID) == 0 || len(a.oidc.RedirectURL) == 0 {
+		return nil, errors.New("config Auth: OIDC needs Issuer, ClientID and RedirectURL")
+	}
+	a.clientSecret = credential("OIDC_CLIENT_SECRET")
+	if len(a.clientSecret) == 0 {
+		return nil, errors.New("config Auth: OIDC needs the client secret in OIDC_CLIENT_SECRET")
+	}
+	mac := hmac.New(sha256.New, []byte(a.clientSecret))
+	mac.Write([]byte("gitgraph session"))
+	a.key = mac.Sum(nil)
+	a.secure = strings.HasPrefix(a.oidc.RedirectURL, "https:")
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	err := getJSON(ctx, strings.TrimSuffix(a.oidc.Issuer, "/")+"/.well-known/openid-configuration", "", &doc)
+	if err != nil {
+		return nil, fmt.Errorf("config Auth: OIDC discovery: %w", err)
+	}
+	if len(doc.AuthorizationEndpoint) == 0 || len(doc.TokenEndpoint) == 0 || len(doc.UserinfoEndpoint) == 0 {
+		return nil, errors.New("config Auth: OIDC discovery: missing endpoints")
+	}
+	a.authURL, a.tokenURL, a.userinfoURL = doc.AuthorizationEndpoint, doc.TokenEndpoint, doc.UserinfoEndpoint
+	return a, nil
+}
+
+// getJSON decodes the response to a GET of u into v, sending token as a
+// bearer token if set.
+func getJSON(ctx context.Context, u, token string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// wrap returns h behind the authenticator. /healthz stays open for
+// probes and /hooks/push checks its own secret. Basic authentication is
+// accepted everywhere, so API clients need no browser sign in.
+func (a *authenticator) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz", "/hooks/push":
+			h.ServeHTTP(w, r)
+			return
+		case "/auth/callback":
+			if a.oidc != nil {
+				a.callback(w, r)
+				return
+			}
+		}
+		if a.basic(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if a.oidc == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="gitgraph"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if len(a.session(r)) > 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		a.login(w, r)
+	})
+}
+
+// basic reports if r carries the password of a user.
+func (a *authenticator) basic(r *http.Request) bool {
+	name, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	want, ok := a.users[name]
+	if !ok {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.checked == nil {
+		a.checked = map[string][]byte{}
+		a.checkKey = make([]byte, 32)
+		rand.Read(a.checkKey)
+	}
+	mac := hmac.New(sha256.New, a.checkKey)
+	mac.Write([]byte(pass))
+	sum := mac.Sum(nil)
+	if hmac.Equal(sum, a.checked[name]) {
+		return true
+	}
+	if !want.match(pass) {
+		return false
+	}
+	a.checked[name] = sum
+	return true
+}
+
+// passwordIter is the PBKDF2 iteration count of new password hashes.
+const passwordIter = 100000
+
+// passwordHash is a salted PBKDF2-HMAC-SHA256 password hash, written as
+// pbkdf2-sha256$ITERATIONS$SALT$HASH with the salt and hash in base64.
+type passwordHash struct {
+	iter int
+	salt []byte
+	sum  []byte
+}
+
+func newPasswordHash(pass string) (passwordHash, error) {
+	h := passwordHash{iter: passwordIter, salt: make([]byte, 16)}
+	_, err := rand.Read(h.salt)
+	if err != nil {
+		return h, err
+	}
+	h.sum = pbkdf2([]byte(pass), h.salt, h.iter)
+	return h, nil
+}
+
+func parsePasswordHash(s string) (passwordHash, error) {
+	var h passwordHash
+	f := strings.Split(s, "$")
+	if len(f) != 4 || f[0] != "pbkdf2-sha256" {
+		return h, errors.New("want a password hash printed by gitgraph passwd")
+	}
+	var err error
+	h.iter, err = strconv.Atoi(f[1])
+	if err != nil || h.iter < 1 {
+		return h, fmt.Errorf("invalid iteration count %q", f[1])
+	}
+	h.salt, err = base64.RawStdEncoding.DecodeString(f[2])
+	if err != nil {
+		return h, fmt.Errorf("invalid salt: %w", err)
+	}
+	h.sum, err = base64.RawStdEncoding.DecodeString(f[3])
+	if err != nil || len(h.sum) != sha256.Size {
+		return h, errors.New("invalid hash")
+	}
+	return h, nil
+}
+
+func (h passwordHash) String() string {
+	return "pbkdf2-sha256$" + strconv.Itoa(h.iter) + "$" + base64.RawStdEncoding.EncodeToString(h.salt) + "$" + base64.RawStdEncoding.EncodeToString(h.sum)
+}
+
+// match reports if pass hashes to h.
+func (h passwordHash) match(pass string) bool {
+	return subtle.ConstantTimeCompare(pbkdf2([]byte(pass), h.salt, h.iter), h.sum) == 1
+}
+
+// pbkdf2 returns the 32 byte PBKDF2-HMAC-SHA256 key of pass.
+func pbkdf2(pass, salt []byte, iter int) []byte {
+	mac := hmac.New(sha256.New, pass)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < iter; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// passwd prints the hash of the password on the first line of stdin for
+// the Users of the Auth config:
+//
+//	gitgraph passwd < password.txt
+func passwd(ctx context.Context) error {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	pass := strings.TrimRight(line, "\r\n")
+	if len(pass) == 0 {
+		return errors.New("passwd: no password on stdin")
+	}
+	h, err := newPasswordHash(pass)
+	if err != nil {
+		return err
+	}
+	fmt.Println(h)
+	return nil
+}
+
+// sign returns the signature of v.
+func (a *authenticator) sign(v string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(v))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// session returns the email of the signed in user of r, or "".
+func (a *authenticator) session(r *http.Request) string {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	f := strings.Split(c.Value, ".")
+	if len(f) != 3 || !hmac.Equal([]byte(f[2]), []byte(a.sign(f[0]+"."+f[1]))) {
+		return ""
+	}
+	exp, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ""
+	}
+	email, err := base64.RawURLEncoding.DecodeString(f[0])
+	if err != nil {
+		return ""
+	}
+	return string(email)
+}
+
+// login redirects to the provider. The state carries a nonce, also set
+// as a cookie to tie the callback to this browser, and the page to
+// return to.
+func (a *authenticator) login(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	nonce := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name: stateCookie, Value: nonce, Path: "/auth/", MaxAge: 600,
+		HttpOnly: true, Secure: a.secure, SameSite: http.SameSiteLaxMode,
+	})
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {a.oidc.ClientID},
+		"redirect_uri":  {a.oidc.RedirectURL},
+		"scope":         {"openid email"},
+		"state":         {nonce + "." + base64.RawURLEncoding.EncodeToString([]byte(r.URL.RequestURI()))},
+	}
+	sep := "?"
+	if strings.Contains(a.authURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, a.authURL+sep+q.Encode(), http.StatusFound)
+}
+
+// localPath reports if s is a path on this server, so redirecting to it
+// after the sign in cannot lead to another site.
+func localPath(s string) bool {
+	// Browsers read a backslash as a slash, so /\host is //host.
+	if !strings.HasPrefix(s, "/") || strings.Contains(s, `\`) {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && len(u.Scheme) == 0 && len(u.Host) == 0
+}
+
+// callback completes the sign in: it exchanges the code for a token,
+// reads the email of the user and sets the session cookie.
+func (a *authenticator) callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); len(e) > 0 {
+		http.Error(w, "sign in failed: "+e, http.StatusForbidden)
+		return
+	}
+	c, err := r.Cookie(stateCookie)
+	state := strings.SplitN(q.Get("state"), ".", 2)
+	if err != nil || len(state) != 2 || subtle.ConstantTimeCompare([]byte(state[0]), []byte(c.Value)) != 1 {
+		http.Error(w, "invalid state, sign in again", http.StatusBadRequest)
+		return
+	}
+	back := "/"
+	if b, err := base64.RawURLEncoding.DecodeString(state[1]); err == nil && localPath(string(b)) {
+		back = string(b)
+	}
+
+	resp, err := http.PostForm(a.tokenURL, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {q.Get("code")},
+		"redirect_uri":  {a.oidc.RedirectURL},
+		"client_id":     {a.oidc.ClientID},
+		"client_secret": {a.clientSecret},
+	})
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err)
+		return
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&tok)
+	if resp.StatusCode != http.StatusOK || err != nil || len(tok.AccessToken) == 0 {
+		http.Error(w, "token exchange failed: "+resp.Status, http.StatusBadGateway)
+		return
+	}
+	var user struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+	}
+	err = getJSON(r.Context(), a.userinfoURL, tok.AccessToken, &user)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err)
+		return
+	}
+	if len(user.Email) == 0 || (user.EmailVerified != nil && !*user.EmailVerified) || !a.allowed(user.Email) {
+		http.Error(w, fmt.Sprintf("%q may not sign in", user.Email), http.StatusForbidden)
+		return
+	}
+
+	v := base64.RawURLEncoding.EncodeToString([]byte(user.Email)) + "." + strconv.FormatInt(time.Now().Add(sessionLife).Unix(), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name: sessionCookie, Value: v + "." + a.sign(v), Path: "/", MaxAge: int(sessionLife / time.Second),
+		HttpOnly: true, Secure: a.secure, SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/auth/", MaxAge: -1})
+	http.Redirect(w, r, back, http.StatusFound)
+}
+
+// allowed reports if the user with email may sign in.
+func (a *authenticator) allowed(email string) bool {
+	if len(a.oidc.Emails) == 0 && len(a.oidc.Domains) == 0 {
+		return true
+	}
+	for _, e := range a.oidc.Emails {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, d := range a.oidc.Domains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAuthenticator(t *testing.T) {
+	// A low iteration count keeps the test fast.
+	pass := passwordHash{iter: 10, salt: []byte("salt")}
+	pass.sum = pbkdf2([]byte("secret"), pass.salt, pass.iter)
+	a := &authenticator{
+		users: map[string]passwordHash{"ann": pass},
+		oidc:  &oidcConfig{Issuer: "https://id.example.com", ClientID: "gitgraph", RedirectURL: "https://graphs.example.com/auth/callback"},
+		key:   []byte("session key"),
+		// Sign ins redirect to the provider.
+		authURL: "https://id.example.com/authorize",
+	}
+	session := func(email string, exp time.Time) string {
+		v := base64.RawURLEncoding.EncodeToString([]byte(email)) + "." + strconv.FormatInt(exp.Unix(), 10)
+		return v + "." + a.sign(v)
+	}
+	valid := session("bob@example.com", time.Now().Add(time.Hour))
+	tests := []struct {
+		name   string
+		path   string
+		user   string
+		pass   string
+		cookie string
+		accept string
+		code   int
+	}{
+		{name: "anonymous", path: "/", code: http.StatusUnauthorized},
+		{name: "health", path: "/healthz", code: http.StatusOK},
+		{name: "push hook", path: "/hooks/push", code: http.StatusOK},
+		{name: "basic", path: "/", user: "ann", pass: "secret", code: http.StatusOK},
+		{name: "basic again", path: "/", user: "ann", pass: "secret", code: http.StatusOK},
+		{name: "wrong password", path: "/", user: "ann", pass: "guess", code: http.StatusUnauthorized},
+		{name: "unknown user", path: "/", user: "bob", pass: "secret", code: http.StatusUnauthorized},
+		{name: "session", path: "/", cookie: valid, code: http.StatusOK},
+		{name: "tampered session", path: "/", cookie: valid[:len(valid)-2] + "xx", code: http.StatusUnauthorized},
+		{name: "expired session", path: "/", cookie: session("bob@example.com", time.Now().Add(-time.Hour)), code: http.StatusUnauthorized},
+		{name: "browser", path: "/", accept: "text/html", code: http.StatusFound},
+	}
+	h := a.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			if len(tt.user) > 0 {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+			if len(tt.cookie) > 0 {
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+			if len(tt.accept) > 0 {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.code {
+				t.Errorf("status %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorAllowed(t *testing.T) {
+	tests := []struct {
+		emails, domains []string
+		email           string
+		want            bool
+	}{
+		{email: "any@example.com", want: true},
+		{emails: []string{"Ann@Example.com"}, email: "ann@example.com", want: true},
+		{emails: []string{"ann@example.com"}, email: "bob@example.com"},
+		{domains: []string{"example.com"}, email: "bob@EXAMPLE.com", want: true},
+		{domains: []string{"example.com"}, email: "bob@example.com.evil.org"},
+		{domains: []string{"example.com"}, email: "bob@sub.example.com"},
+	}
+	for _, tt := range tests {
+		a := &authenticator{oidc: &oidcConfig{Emails: tt.emails, Domains: tt.domains}}
+		if got := a.allowed(tt.email); got != tt.want {
+			t.Errorf("allowed(%q) with %v %v = %v, want %v", tt.email, tt.emails, tt.domains, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordHash(t *testing.T) {
+	h, err := newPasswordHash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := parsePasswordHash(h.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.match("secret") || p.match("guess") {
+		t.Errorf("%v matches wrongly", h)
+	}
+	// The first 32 bytes of the PBKDF2-HMAC-SHA256 vector of RFC 7914.
+	got := base64.StdEncoding.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1))
+	if want := "VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLw="; got != want {
+		t.Errorf("pbkdf2 = %s, want %s", got, want)
+	}
+	for _, s := range []string{"", "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8", "pbkdf2-sha256$0$c2FsdA$c2FsdA"} {
+		if _, err := parsePasswordHash(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/repo.html?window=monthly", true},
+		{"", false},
+		{"//evil.com", false},
+		{`/\evil.com`, false},
+		{"https://evil.com/", false},
+		{"evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := localPath(tt.path); got != tt.want {
+			t.Errorf("localPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
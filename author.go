@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+// authorTimeline renders the commits of one person across all
+// repositories, a line per repository:
+//
+//	gitgraph author EMAIL|NAME [FILE]
+//
+// Identities are matched after applying the mailmap, so any of the emails
+// of a person finds all of their commits.
+func authorTimeline(ctx context.Context) error {
+	args := flag.Args()[1:]
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: author EMAIL|NAME [FILE]")
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	err = cfg.selectRepos(nil)
+	if err != nil {
+		return err
+	}
+	mm, err := cfg.loadMailmap(filepath.Dir(*configFile))
+	if err != nil {
+		return err
+	}
+	charts, err := readCache(cfg)
+	if err != nil {
+		return err
+	}
+	opt := chartOptions{window: cfg.window("")}
+	wname, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	loc, err := lookupLocale(*localeName)
+	if err != nil {
+		return err
+	}
+
+	query := args[0]
+	if strings.Contains(query, "@") {
+		query = mm.resolve(history.Person{Email: query}).Email
+	}
+	match := func(c *history.Commit) (history.Person, bool) {
+		for _, p := range c.Authors() {
+			p = mm.resolve(p)
+			if strings.EqualFold(p.Email, query) || strings.EqualFold(p.Name, query) {
+				return p, true
+			}
+		}
+		return history.Person{}, false
+	}
+
+	now := renderTime()
+	slugs := charts.slugs()
+	var who history.Person
+	var styles styleSet
+	p := plot.New()
+	p.Y.Label.Text = fmt.Sprintf("commits (%s)", wname)
+	p.X.Tick.Marker = plot.TimeTicks{Format: loc.DateFormat}
+	p.Add(plotter.NewGrid())
+	p.Legend.Top = true
+	p.Legend.Left = true
+	total := 0
+	for _, u := range charts.urls() {
+		ch := charts[u]
+		mine := &chart{Name: ch.Name, ignore: ch.ignore, Generated: ch.Generated, newMetric: ch.newMetric}
+		for i := range ch.Commits {
+			if person, ok := match(&ch.Commits[i]); ok {
+				who = person
+				mine.Commits = append(mine.Commits, ch.Commits[i])
+			}
+		}
+		if len(mine.Commits) == 0 {
+			continue
+		}
+		first, last := mine.Commits[0].When, mine.Commits[0].When
+		for _, c := range mine.Commits {
+			if c.When.Before(first) {
+				first = c.When
+			}
+			if c.When.After(last) {
+				last = c.When
+			}
+		}
+		fmt.Printf("%s: %d commits, %s to %s\n", ch.Name, len(mine.Commits), first.Format("2006-01-02"), last.Format("2006-01-02"))
+		total += len(mine.Commits)
+
+		data, err := seriesPoints(mine, "commits", w, nil, now)
+		if err != nil {
+			return err
+		}
+		line, err := plotter.NewLine(data)
+		if err != nil {
+			return err
+		}
+		st := styles.pick(cfg, u, slugs[u])
+		line.Color = st.Color
+		line.Dashes = st.Dashes
+		p.Add(line)
+		p.Legend.Add(ch.Name, line)
+	}
+	if total == 0 {
+		return fmt.Errorf("no commits by %q", args[0])
+	}
+	p.Title.Text = fmt.Sprintf("%s <%s>, %d commits", who.Name, who.Email, total)
+
+	out := filepath.Join(outputDir, "author-"+slugify(args[0])+".png")
+	if len(args) == 2 {
+		out = args[1]
+	}
+	err = savePlot(p, out)
+	if err != nil {
+		return err
+	}
+	fmt.Println("wrote", out)
+	return nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+// backport is a commit of a release branch with the same patch-id as a
+// commit of the default branch, so one was cherry-picked from the other.
+type backport struct {
+	Branch string
+	Hash   string
+	Source string // Commit of the default branch.
+	Time   time.Time
+}
+
+// backportBranches returns the patterns of the release branches of u
+// whose cherry-picks are tracked, those of the repository replacing the
+// global ones.
+func (cfg *config) backportBranches(u string) []string {
+	if rc := cfg.Repos[u]; rc != nil && len(rc.Backports) > 0 {
+		return rc.Backports
+	}
+	return cfg.Backports
+}
+
+// isReleaseBranch reports if the branch name, without a remote prefix,
+// matches any of the patterns.
+func isReleaseBranch(patterns []string, name string) bool {
+	short := strings.TrimPrefix(name, "origin/")
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, short); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pairBackports returns the commits of the branch name, patch-ids by hash
+// in onBranch, whose patch-id is that of a commit of the default branch
+// in onHead, hashes by patch-id, oldest first. when holds the commit
+// times of the branch.
+func pairBackports(name string, onBranch, onHead map[string]string, when map[string]time.Time) []backport {
+	var list []backport
+	for h, id := range onBranch {
+		if src, ok := onHead[id]; ok {
+			list = append(list, backport{Branch: name, Hash: h, Source: src, Time: when[h]})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	return list
+}
+
+// patchID returns the patch-id of the change c makes to its parent, or ""
+// for merge, root and empty commits. Like git patch-id --stable it
+// ignores whitespace, line numbers and the order of the files, but the
+// ids differ from those of git.
+func patchID(c *object.Commit) (string, error) {
+	if c.NumParents() != 1 {
+		return "", nil
+	}
+	parent, err := c.Parent(0)
+	if err != nil {
+		return "", err
+	}
+	patch, err := parent.Patch(c)
+	if err != nil {
+		return "", fmt.Errorf("patch of %s: %w", c.Hash, err)
+	}
+	sum := make([]byte, sha1.Size)
+	empty := true
+	for _, fp := range patch.FilePatches() {
+		h := sha1.New()
+		from, to := fp.Files()
+		if from != nil {
+			io.WriteString(h, from.Path())
+		}
+		h.Write([]byte{0})
+		if to != nil {
+			io.WriteString(h, to.Path())
+		}
+		for _, chunk := range fp.Chunks() {
+			var sign string
+			switch chunk.Type() {
+			case diff.Add:
+				sign = "+"
+			case diff.Delete:
+				sign = "-"
+			default:
+				continue
+			}
+			for _, line := range strings.Split(chunk.Content(), "\n") {
+				io.WriteString(h, sign+strings.Join(strings.Fields(line), ""))
+			}
+		}
+		// Adding the hashes of the files makes the id independent of
+		// their order.
+		for i, b := range h.Sum(nil) {
+			sum[i] += b
+		}
+		empty = false
+	}
+	if empty {
+		return "", nil
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// readBackports finds the cherry-picks between the release branches
+// matching patterns and the default branch of r, whose commits are head.
+// Commits of the default branch older than the newest commit a branch
+// shares with it are not compared.
+func readBackports(r *git.Repository, head []history.Commit, branches []branch, patterns []string) ([]backport, error) {
+	headWhen := make(map[string]time.Time, len(head))
+	for _, c := range head {
+		headWhen[c.Hash] = c.When
+	}
+	headIDs := map[string]string{} // Patch-id of each compared commit by hash.
+	var list []backport
+	for _, b := range branches {
+		if !isReleaseBranch(patterns, b.Name) {
+			continue
+		}
+		tip, err := r.ResolveRevision(plumbing.Revision(b.Name))
+		if err != nil {
+			return nil, fmt.Errorf("branch %s: %w", b.Name, err)
+		}
+		onBranch := map[string]string{}
+		when := map[string]time.Time{}
+		var fork time.Time
+		seen := map[plumbing.Hash]bool{*tip: true}
+		queue := []plumbing.Hash{*tip}
+		for n := 0; len(queue) > 0 && n < maxAhead; n++ {
+			h := queue[0]
+			queue = queue[1:]
+			if t, ok := headWhen[h.String()]; ok {
+				if t.After(fork) {
+					fork = t
+				}
+				continue
+			}
+			c, err := r.CommitObject(h)
+			if err != nil {
+				return nil, err
+			}
+			id, err := patchID(c)
+			if err != nil {
+				return nil, err
+			}
+			if len(id) > 0 {
+				onBranch[c.Hash.String()] = id
+				when[c.Hash.String()] = c.Committer.When
+			}
+			for _, p := range c.ParentHashes {
+				if !seen[p] {
+					seen[p] = true
+					queue = append(queue, p)
+				}
+			}
+		}
+		onHead := map[string]string{}
+		for _, hc := range head {
+			if !hc.When.After(fork) {
+				continue
+			}
+			id, ok := headIDs[hc.Hash]
+			if !ok {
+				c, err := r.CommitObject(plumbing.NewHash(hc.Hash))
+				if err != nil {
+					return nil, err
+				}
+				id, err = patchID(c)
+				if err != nil {
+					return nil, err
+				}
+				headIDs[hc.Hash] = id
+			}
+			if len(id) > 0 {
+				onHead[id] = hc.Hash
+			}
+		}
+		list = append(list, pairBackports(b.Name, onBranch, onHead, when)...)
+	}
+	return list, nil
+}
+
+// readBackportsCLI is readBackports for the cli backend, using git
+// patch-id.
+func readBackportsCLI(ctx context.Context, dir string, branches []branch, patterns []string) ([]backport, error) {
+	var list []backport
+	for _, b := range branches {
+		if !isReleaseBranch(patterns, b.Name) {
+			continue
+		}
+		onBranch, err := gitPatchIDs(ctx, dir, "HEAD.."+b.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(onBranch) == 0 {
+			continue
+		}
+		ids, err := gitPatchIDs(ctx, dir, b.Name+"..HEAD")
+		if err != nil {
+			return nil, err
+		}
+		onHead := make(map[string]string, len(ids))
+		for h, id := range ids {
+			onHead[id] = h
+		}
+		out, err := gitCommand(ctx, "-C", dir, "log", "--no-merges", "--format=%H %ct", "HEAD.."+b.Name).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git log: %w", err)
+		}
+		when := map[string]time.Time{}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			f := strings.Fields(line)
+			if len(f) != 2 {
+				continue
+			}
+			t, err := parseGitTime(f[1])
+			if err != nil {
+				return nil, err
+			}
+			when[f[0]] = t
+		}
+		list = append(list, pairBackports(b.Name, onBranch, onHead, when)...)
+	}
+	return list, nil
+}
+
+// gitPatchIDs returns the patch-id of each commit of the revision range
+// by hash.
+func gitPatchIDs(ctx context.Context, dir, revs string) (map[string]string, error) {
+	logCmd := gitCommand(ctx, "-C", dir, "log", "-p", "--no-merges", "--no-color", revs)
+	idCmd := gitCommand(ctx, "-C", dir, "patch-id", "--stable")
+	var err error
+	idCmd.Stdin, err = logCmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = logCmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	out, err := idCmd.Output()
+	werr := logCmd.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("git patch-id: %w", err)
+	}
+	if werr != nil {
+		return nil, fmt.Errorf("git log: %w", werr)
+	}
+	ids := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 {
+			ids[f[1]] = f[0]
+		}
+	}
+	return ids, nil
+}
+
+func backportFilename(slug string) string {
+	return slug + "--backports.png"
+}
+
+// displayBackports charts the cherry-picks onto each release branch of
+// ch per window, a line per branch.
+func displayBackports(ch *chart, opt chartOptions, filename string) error {
+	wname, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	byBranch := map[string]*chart{}
+	var names []string
+	for _, b := range ch.Backports {
+		sub := byBranch[b.Branch]
+		if sub == nil {
+			sub = &chart{Name: ch.Name}
+			byBranch[b.Branch] = sub
+			names = append(names, b.Branch)
+		}
+		sub.Commits = append(sub.Commits, history.Commit{Hash: b.Hash, When: b.Time})
+	}
+	sort.Strings(names)
+
+	now := renderTime()
+	p := plot.New()
+	addProvenance(p, ch)
+	p.Title.Text, err = chartTitle(ch, "backports", opt, ch.Name+" (backports)")
+	if err != nil {
+		return err
+	}
+	p.Y.Label.Text = fmt.Sprintf("cherry-picked commits (%s)", wname)
+	p.X.Tick.Marker = plot.TimeTicks{Format: opt.loc.DateFormat}
+	p.Add(plotter.NewGrid())
+	p.Legend.Top = true
+	p.Legend.Left = true
+	for i, name := range names {
+		data, err := seriesPoints(byBranch[name], "commits", w, nil, now)
+		if err != nil {
+			return err
+		}
+		line, err := plotter.NewLine(data)
+		if err != nil {
+			return err
+		}
+		st := palette[i%len(palette)]
+		line.Color = st.Color
+		line.Dashes = st.Dashes
+		p.Add(line)
+		p.Legend.Add(fmt.Sprintf("%s (%d)", name, len(byBranch[name].Commits)), line)
+	}
+	p.Y.Min = 0
+	return savePlot(p, filename)
+}
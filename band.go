@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"sort"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+var iqrBand = flag.Bool("band", false, "shade the inter-quartile range of the trailing year of values behind each chart")
+
+// bandYear is the trailing span the quartiles are computed over.
+const bandYear = 365 * 24 * time.Hour
+
+// fillWindows returns data with a zero value for every window of w
+// between the first and last point that has no commits.
+func fillWindows(data plotter.XYs, w history.Window) plotter.XYs {
+	if len(data) == 0 {
+		return nil
+	}
+	values := make(map[int64]float64, len(data))
+	for _, pt := range data {
+		values[int64(pt.X)] = pt.Y
+	}
+	last := time.Unix(int64(data[len(data)-1].X), 0).UTC()
+	var filled plotter.XYs
+	for t := time.Unix(int64(data[0].X), 0).UTC(); !t.After(last); {
+		filled = append(filled, plotter.XY{X: float64(t.Unix()), Y: values[t.Unix()]})
+		next := t
+		for w.Start(next).Equal(t) {
+			next = next.Add(24 * time.Hour)
+		}
+		t = w.Start(next)
+	}
+	return filled
+}
+
+// quantile returns the q quantile of sorted values, interpolating between
+// neighbours.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// addBand shades the range between the first and third quartile of the
+// values in the year up to each point. Points with less than a quarter
+// year of history have no band.
+func addBand(p *plot.Plot, data plotter.XYs, w history.Window) error {
+	filled := fillWindows(data, w)
+	var lower, upper plotter.XYs
+	for i, pt := range filled {
+		var window []float64
+		for j := i; j >= 0 && pt.X-filled[j].X < bandYear.Seconds(); j-- {
+			window = append(window, filled[j].Y)
+		}
+		if pt.X-filled[0].X < bandYear.Seconds()/4 || len(window) < 4 {
+			continue
+		}
+		sort.Float64s(window)
+		lower = append(lower, plotter.XY{X: pt.X, Y: quantile(window, 0.25)})
+		upper = append(upper, plotter.XY{X: pt.X, Y: quantile(window, 0.75)})
+	}
+	if len(upper) < 2 {
+		return nil
+	}
+	outline := make(plotter.XYs, 0, 2*len(upper))
+	outline = append(outline, upper...)
+	for i := len(lower) - 1; i >= 0; i-- {
+		outline = append(outline, lower[i])
+	}
+	poly, err := plotter.NewPolygon(outline)
+	if err != nil {
+		return err
+	}
+	poly.Color = color.RGBA{R: 0x40, G: 0x80, B: 0xff, A: 0x30}
+	poly.LineStyle.Width = 0
+	p.Add(poly)
+	p.Legend.Add("inter-quartile range, trailing year", poly)
+	return nil
+}
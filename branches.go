@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+var (
+	branchCharts = flag.Bool("branches", false, "also render a chart of the number of active branches of each repository over time")
+	staleBranch  = duration(90 * 24 * time.Hour)
+)
+
+func init() {
+	flag.Var(&staleBranch, "stale-branch", "list branches without commits for this long, such as 90d, as stale on repository pages")
+}
+
+// branch is a branch of the repository other than the default one. Git
+// does not record when a branch was created, so Created is the time of
+// the oldest commit on the branch that is not on the default branch.
+type branch struct {
+	Name    string
+	Created time.Time
+	Last    time.Time // Time of the tip commit.
+	Author  string    // Author of the tip commit.
+	Ahead   int       // Commits not on the default branch.
+}
+
+// maxAhead limits the walk of a branch that shares no history with the
+// default branch.
+const maxAhead = 10000
+
+// readBranches returns the branches of r other than HEAD. onHead holds
+// the hashes of the commits of the default branch.
+func readBranches(r *git.Repository, onHead map[string]bool) ([]branch, error) {
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+	var tips []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		if ref.Type() != plumbing.HashReference || !(name.IsBranch() || name.IsRemote()) {
+			return nil
+		}
+		if name == head.Name() || ref.Hash() == head.Hash() {
+			return nil
+		}
+		tips = append(tips, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var list []branch
+	for _, ref := range tips {
+		tip, err := r.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, err
+		}
+		b := branch{
+			Name:    ref.Name().Short(),
+			Created: tip.Committer.When,
+			Last:    tip.Committer.When,
+			Author:  tip.Author.Name,
+		}
+		// Walk back from the tip until reaching the default branch.
+		seen := map[plumbing.Hash]bool{tip.Hash: true}
+		queue := []plumbing.Hash{tip.Hash}
+		for len(queue) > 0 && b.Ahead < maxAhead {
+			h := queue[0]
+			queue = queue[1:]
+			if onHead[h.String()] {
+				continue
+			}
+			c, err := r.CommitObject(h)
+			if err != nil {
+				return nil, err
+			}
+			b.Ahead++
+			if c.Committer.When.Before(b.Created) {
+				b.Created = c.Committer.When
+			}
+			for _, p := range c.ParentHashes {
+				if !seen[p] {
+					seen[p] = true
+					queue = append(queue, p)
+				}
+			}
+		}
+		list = append(list, b)
+	}
+	sortBranches(list)
+	return list, nil
+}
+
+// readBranchesCLI is readBranches for the cli backend.
+func readBranchesCLI(ctx context.Context, dir string) ([]branch, error) {
+	out, err := gitCommand(ctx, "-C", dir, "for-each-ref", "--format=%(refname:short)%09%(committerdate:unix)%09%(authorname)%09%(objectname)", "refs/heads").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref: %w", err)
+	}
+	head, err := gitCommand(ctx, "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git rev-parse: %w", err)
+	}
+	var list []branch
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != 4 || f[3] == strings.TrimSpace(string(head)) {
+			continue
+		}
+		last, err := parseGitTime(f[1])
+		if err != nil {
+			return nil, err
+		}
+		b := branch{Name: f[0], Created: last, Last: last, Author: f[2]}
+		times, err := gitCommand(ctx, "-C", dir, "log", "--format=%ct", "--max-count="+strconv.Itoa(maxAhead), "HEAD.."+f[3]).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git log: %w", err)
+		}
+		for _, s := range strings.Fields(string(times)) {
+			t, err := parseGitTime(s)
+			if err != nil {
+				return nil, err
+			}
+			b.Ahead++
+			if t.Before(b.Created) {
+				b.Created = t
+			}
+		}
+		list = append(list, b)
+	}
+	sortBranches(list)
+	return list, nil
+}
+
+func sortBranches(list []branch) {
+	sort.Slice(list, func(i, j int) bool { return list[i].Last.After(list[j].Last) })
+}
+
+// staleBranches returns the branches without commits since the
+// -stale-branch period before now, oldest first.
+func staleBranches(list []branch, now time.Time) []branch {
+	var stale []branch
+	for _, b := range list {
+		if now.Sub(b.Last) >= time.Duration(staleBranch) {
+			stale = append(stale, b)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Last.Before(stale[j].Last) })
+	return stale
+}
+
+// branchFilename returns the file name of the branch chart.
+func branchFilename(slug string) string {
+	return slug + "--branches.png"
+}
+
+// activeBranches returns the number of branches that existed and had not
+// yet received their last commit at the start of each window, from the
+// oldest branch to now. Deleted branches are not known, so the count of
+// earlier times is a lower bound.
+func activeBranches(list []branch, w history.Window, now time.Time) plotter.XYs {
+	if len(list) == 0 {
+		return nil
+	}
+	first := now
+	for _, b := range list {
+		if b.Created.Before(first) {
+			first = b.Created
+		}
+	}
+	var data plotter.XYs
+	for t := w.Start(first); !t.After(now); {
+		n := 0
+		for _, b := range list {
+			if !b.Created.After(t) && !b.Last.Before(t) {
+				n++
+			}
+		}
+		data = append(data, plotter.XY{X: float64(t.Unix()), Y: float64(n)})
+		next := t
+		for w.Start(next).Equal(t) {
+			next = next.Add(24 * time.Hour)
+		}
+		t = w.Start(next)
+	}
+	return data
+}
+
+// displayBranches charts the number of active branches of ch.
+func displayBranches(ch *chart, opt chartOptions, filename string) error {
+	_, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	data := activeBranches(ch.Branches, w, renderTime())
+	p := plot.New()
+	addProvenance(p, ch)
+	p.Title.Text, err = chartTitle(ch, "branches", opt, ch.Name+" (active branches)")
+	if err != nil {
+		return err
+	}
+	p.Y.Label.Text = "branches"
+	p.X.Tick.Marker = plot.TimeTicks{Format: opt.loc.DateFormat}
+	p.Add(plotter.NewGrid())
+	if len(data) > 0 {
+		line, err := plotter.NewLine(data)
+		if err != nil {
+			return err
+		}
+		line.Color = color.RGBA{B: 0xc0, A: 0xff}
+		line.StepStyle = plotter.PostStep
+		p.Add(line)
+	}
+	p.Y.Min = 0
+	return savePlot(p, filename)
+}
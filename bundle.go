@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// isBundle reports if u is a git bundle file, such as made by
+// "git bundle create repo.bundle --all", rather than a remote.
+func isBundle(u string) bool {
+	return strings.HasSuffix(u, ".bundle")
+}
+
+// openBundle reads a git bundle into memory. The bundle must hold the
+// complete history; incremental bundles that need commits from elsewhere
+// are refused.
+func openBundle(u string) (*git.Repository, error) {
+	f, err := os.Open(strings.TrimPrefix(u, "file://"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fmt.Fprintln(progress, "read bundle", u)
+	br := bufio.NewReader(f)
+	header, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("bundle %s: %w", u, err)
+	}
+	switch header {
+	case "# v2 git bundle\n", "# v3 git bundle\n":
+	default:
+		return nil, fmt.Errorf("bundle %s: not a git bundle", u)
+	}
+
+	refs := map[plumbing.ReferenceName]plumbing.Hash{}
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			return nil, fmt.Errorf("bundle %s: no pack data", u)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle %s: %w", u, err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if len(line) == 0 {
+			break
+		}
+		switch line[0] {
+		case '-':
+			return nil, fmt.Errorf("bundle %s: needs prerequisite commits, create it with the complete history", u)
+		case '@':
+			// Capabilities of a v3 bundle.
+			if strings.HasPrefix(line, "@object-format=") && line != "@object-format=sha1" {
+				return nil, fmt.Errorf("bundle %s: unsupported %s", u, line[1:])
+			}
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bundle %s: invalid reference line %q", u, line)
+		}
+		refs[plumbing.ReferenceName(fields[1])] = plumbing.NewHash(fields[0])
+	}
+
+	st := memory.NewStorage()
+	err = packfile.UpdateObjectStorage(st, br)
+	if err != nil {
+		return nil, fmt.Errorf("bundle %s: %w", u, err)
+	}
+	for name, h := range refs {
+		err = st.SetReference(plumbing.NewHashReference(name, h))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// A bundle of a branch has no HEAD; use main or master if present.
+	if _, ok := refs[plumbing.HEAD]; !ok {
+		head := plumbing.ReferenceName("")
+		for _, name := range []plumbing.ReferenceName{"refs/heads/main", "refs/heads/master"} {
+			if _, ok := refs[name]; ok {
+				head = name
+				break
+			}
+		}
+		if len(head) == 0 {
+			for name := range refs {
+				if name.IsBranch() && (len(head) == 0 || name < head) {
+					head = name
+				}
+			}
+		}
+		if len(head) == 0 {
+			return nil, fmt.Errorf("bundle %s: has no branch", u)
+		}
+		err = st.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, head))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return git.Open(st, nil)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var capBursts = flag.Bool("cap-bursts", false, "cap windows with far more activity than usual, such as history imports or vendoring, so the rest of the chart stays readable, and label their real values")
+
+const (
+	// burstFactor is how many times the median of the windows with
+	// activity a burst exceeds.
+	burstFactor = 10
+	// burstMinWindows is the number of windows with activity needed to
+	// tell a burst from the usual.
+	burstMinWindows = 8
+)
+
+// burstLimit returns the value above which a point of data is a burst, or
+// zero if there are too few points to tell.
+func burstLimit(data plotter.XYs) float64 {
+	var values []float64
+	for _, pt := range data {
+		if pt.Y > 0 {
+			values = append(values, pt.Y)
+		}
+	}
+	if len(values) < burstMinWindows {
+		return 0
+	}
+	sort.Float64s(values)
+	return burstFactor * quantile(values, 0.5)
+}
+
+// flattenBursts finds the bursts of the metric chart of name. With
+// -cap-bursts it returns data with the bursts lowered to the limit, and
+// the bursts with their real values; otherwise it suggests the flag.
+func flattenBursts(name, metric string, data plotter.XYs) (plotter.XYs, plotter.XYs, float64) {
+	limit := burstLimit(data)
+	if limit == 0 {
+		return data, nil, 0
+	}
+	var bursts plotter.XYs
+	for _, pt := range data {
+		if pt.Y > limit {
+			bursts = append(bursts, pt)
+		}
+	}
+	if len(bursts) == 0 {
+		return data, nil, 0
+	}
+	if !*capBursts {
+		for _, pt := range bursts {
+			fmt.Fprintf(progress, "%s: %s %s in the window of %s, over %d times the median; -cap-bursts keeps it from flattening the chart\n",
+				name, strconv.FormatFloat(pt.Y, 'f', -1, 64), metric, time.Unix(int64(pt.X), 0).UTC().Format("2006-01-02"), burstFactor)
+		}
+		return data, nil, 0
+	}
+	capped := make(plotter.XYs, len(data))
+	for i, pt := range data {
+		if pt.Y > limit {
+			pt.Y = limit
+		}
+		capped[i] = pt
+	}
+	return capped, bursts, limit
+}
+
+// addBurstLabels labels the capped bursts at the limit with their real
+// values.
+func addBurstLabels(p *plot.Plot, bursts plotter.XYs, limit float64) error {
+	labels := plotter.XYLabels{}
+	for _, pt := range bursts {
+		labels.XYs = append(labels.XYs, plotter.XY{X: pt.X, Y: limit})
+		labels.Labels = append(labels.Labels, "↑ "+strconv.FormatFloat(pt.Y, 'f', -1, 64))
+	}
+	l, err := plotter.NewLabels(labels)
+	if err != nil {
+		return err
+	}
+	for i := range l.TextStyle {
+		l.TextStyle[i].XAlign = draw.XCenter
+		l.TextStyle[i].YAlign = draw.YBottom
+	}
+	l.YOffset = vg.Points(2)
+	p.Add(l)
+	return nil
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// The cache keeps the commits of each repository in its own shard file,
+// cache/repos/xx/<hash>.json, named by a hash of the URL. cache/index.json
+// holds the state of every repository so a run can decide what to fetch
+// without reading the commits, and renders one repository at a time.
+// Both are read and written through the Store selected by -store.
+
+const (
+	indexFilename = "index.json"
+	shardDir      = "repos"
+)
+
+func indexPath() string { return filepath.Join(cacheDir, indexFilename) }
+
+// urlHash names the cache files of the repository u.
+func urlHash(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:8])
+}
+
+// shardName returns the name in the store of the shard of the repository
+// u.
+func shardName(u string) string {
+	h := urlHash(u)
+	return shardDir + "/" + h[:2] + "/" + h + ".json"
+}
+
+// shardPath returns the cache file of the repository u with the default
+// store.
+func shardPath(u string) string {
+	return filepath.Join(cacheDir, filepath.FromSlash(shardName(u)))
+}
+
+// shard is the content of a shard file.
+type shard struct {
+	URL string
+	chart
+}
+
+// loadShard reads the cached commits of u into ch. A missing shard leaves
+// ch unchanged.
+func loadShard(u string, ch *chart) error {
+	st, err := cacheStore()
+	if err != nil {
+		return err
+	}
+	b, err := st.Get(shardName(u))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var s shard
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("%s: %w", shardName(u), err)
+	}
+	if s.URL != u {
+		return fmt.Errorf("%s: holds %q, not %q", shardName(u), s.URL, u)
+	}
+	var dups int
+	ch.Commits, dups = uniqueCommits(s.Commits)
+	if dups > 0 {
+		fmt.Fprintf(progress, "%s: dropped %d duplicate cached commits\n", u, dups)
+	}
+	ch.Fetched = s.Fetched
+	ch.Ref = s.Ref
+	ch.Tip = s.Tip
+	ch.Tool = s.Tool
+	ch.Truncated = s.Truncated
+	ch.Generated = s.Generated
+	ch.Branches = s.Branches
+	ch.Backports = s.Backports
+	ch.LargeFile = s.LargeFile
+	return nil
+}
+
+func saveShard(u string, ch *chart) error {
+	st, err := cacheStore()
+	if err != nil {
+		return err
+	}
+	ch.Commits, _ = uniqueCommits(ch.Commits)
+	b, err := json.Marshal(shard{URL: u, chart: *ch})
+	if err != nil {
+		return err
+	}
+	return st.Put(shardName(u), append(b, '\n'))
+}
+
+// uniqueCommits returns commits with one commit of each hash, newest
+// first, as git log lists them, and the number of duplicates dropped.
+// Aggregates count every commit they are given, so the cache must not
+// hold one twice. Commits without a hash, from old caches, are all kept,
+// as nothing tells two commits of the same author and second apart.
+func uniqueCommits(commits []history.Commit) ([]history.Commit, int) {
+	seen := make(map[string]bool, len(commits))
+	list := make([]history.Commit, 0, len(commits))
+	for _, c := range commits {
+		if len(c.Hash) > 0 {
+			if seen[c.Hash] {
+				continue
+			}
+			seen[c.Hash] = true
+		}
+		list = append(list, c)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].When.After(list[j].When) })
+	return list, len(commits) - len(list)
+}
+
+// cacheIndex is the state of each cached repository, keyed by URL.
+type cacheIndex map[string]*indexEntry
+
+type indexEntry struct {
+	Name    string
+	Fetched time.Time
+	Commits int
+	First   time.Time `json:",omitempty"`
+	Last    time.Time `json:",omitempty"`
+	// API is the state reported by the hosting API, if any.
+	API apiState
+}
+
+// readIndex reads the cache index. The cache is empty if there is none.
+func readIndex() (cacheIndex, error) {
+	ix := cacheIndex{}
+	st, err := cacheStore()
+	if err != nil {
+		return nil, err
+	}
+	b, err := st.Get(indexFilename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ix, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(b, &ix)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", indexFilename, err)
+	}
+	return ix, nil
+}
+
+// openIndex reads the cache index, first converting a cache written by
+// older versions as a single file. The caller must hold the cache lock.
+func openIndex() (cacheIndex, error) {
+	_, err := os.Stat(loadFrom())
+	if os.IsNotExist(err) {
+		return readIndex()
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(loadFrom())
+	if err != nil {
+		return nil, err
+	}
+	legacy := FileType{}
+	err = json.NewDecoder(f).Decode(&legacy)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", loadFrom(), err)
+	}
+	ix, err := readIndex()
+	if err != nil {
+		return nil, err
+	}
+	for u, ch := range legacy {
+		if _, ok := ix[u]; ok {
+			continue
+		}
+		err = saveShard(u, ch)
+		if err != nil {
+			return nil, err
+		}
+		ix.set(u, ch)
+	}
+	err = ix.write()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("converted", loadFrom(), "to", indexPath())
+	return ix, os.Rename(loadFrom(), loadFrom()+".old")
+}
+
+// set records the state of ch.
+func (ix cacheIndex) set(u string, ch *chart) {
+	e := &indexEntry{Name: ch.Name, Fetched: ch.Fetched, Commits: len(ch.Commits)}
+	if old := ix[u]; old != nil {
+		e.API = old.API
+	}
+	for _, c := range ch.Commits {
+		if e.First.IsZero() || c.When.Before(e.First) {
+			e.First = c.When
+		}
+		if c.When.After(e.Last) {
+			e.Last = c.When
+		}
+	}
+	ix[u] = e
+}
+
+// write saves the index. A shared store is written by other machines as
+// well, so their entries are merged in: the most recently fetched entry of
+// each repository is kept, and entries missing from ix are not removed.
+func (ix cacheIndex) write() error {
+	st, err := cacheStore()
+	if err != nil {
+		return err
+	}
+	out := ix
+	if sharedStore() {
+		cur, err := readIndex()
+		if err != nil {
+			return err
+		}
+		for u, e := range ix {
+			if old := cur[u]; old == nil || !old.Fetched.After(e.Fetched) {
+				cur[u] = e
+			}
+		}
+		out = cur
+	}
+	b, err := json.MarshalIndent(out, "", "\t")
+	if err != nil {
+		return err
+	}
+	return st.Put(indexFilename, append(b, '\n'))
+}
+
+// FileType holds the commits of several repositories, keyed by URL.
+type FileType map[string]*chart
+
+// Load reads the cached commits of each repository in ft.
+func (ft FileType) Load() error {
+	for u, ch := range ft {
+		err := loadShard(u, ch)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the repositories urls of ft to the cache. The caller must
+// hold the cache lock.
+func (ft FileType) Save(urls ...string) error {
+	ix, err := openIndex()
+	if err != nil {
+		return err
+	}
+	for _, u := range urls {
+		ch := ft[u]
+		err = saveShard(u, ch)
+		if err != nil {
+			return err
+		}
+		ix.set(u, ch)
+	}
+	return ix.write()
+}
+
+// readCache loads the cached commits of the configured repositories.
+func readCache(cfg *config) (FileType, error) {
+	charts := cfg.charts()
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	_, err = openIndex()
+	if err == nil {
+		err = charts.Load()
+	}
+	uerr := unlockCache(lock)
+	if err != nil {
+		return nil, err
+	}
+	return charts, uerr
+}
+
+// urls returns the repository URLs in sorted order.
+func (ft FileType) urls() []string {
+	list := make([]string, 0, len(ft))
+	for u := range ft {
+		list = append(list, u)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// slugs returns the file name slug of each repository URL.
+func (ft FileType) slugs() map[string]string {
+	sg := newSlugger()
+	m := make(map[string]string, len(ft))
+	for _, u := range ft.urls() {
+		m[u] = sg.unique(ft[u].Name)
+	}
+	return m
+}
+
+// writeFileAtomic writes to a temporary file next to location and renames
+// it into place, so readers never see a partially written file.
+func writeFileAtomic(location string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(location)
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(location)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, location)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+var errLocked = errors.New("file is locked")
+
+const lockFilename = "lock"
+
+// lockCache takes an exclusive advisory lock on the cache directory. If
+// another run holds the lock it waits for it to be released.
+func lockCache(dir string) (*os.File, error) {
+	return lockCacheWaiting(dir, func(name string) {
+		fmt.Println("waiting for another run to release", name)
+	})
+}
+
+// lockCacheWaiting is lockCache, calling waiting with the name of the lock
+// file if another run holds it.
+func lockCacheWaiting(dir string, waiting func(name string)) (*os.File, error) {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, lockFilename), os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, err
+	}
+	err = tryLockFile(f)
+	if err == errLocked {
+		waiting(f.Name())
+		err = lockFile(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %q: %w", f.Name(), err)
+	}
+	return f, nil
+}
+
+func unlockCache(f *os.File) error {
+	err := unlockFile(f)
+	cerr := f.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+func TestUniqueCommits(t *testing.T) {
+	t1 := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+	t2, t3 := t1.Add(time.Hour), t1.Add(2*time.Hour)
+	tests := []struct {
+		name string
+		in   []history.Commit
+		want []history.Commit
+		dups int
+	}{
+		{name: "empty", want: []history.Commit{}},
+		{
+			name: "by hash",
+			in:   []history.Commit{{Hash: "a", When: t1}, {Hash: "b", When: t2}, {Hash: "a", When: t1, Author: "again"}},
+			want: []history.Commit{{Hash: "b", When: t2}, {Hash: "a", When: t1}},
+			dups: 1,
+		},
+		{
+			name: "without hashes",
+			in:   []history.Commit{{When: t1, Author: "Ann"}, {When: t1, Author: "Bob"}, {When: t1, Author: "Ann"}},
+			want: []history.Commit{{When: t1, Author: "Ann"}, {When: t1, Author: "Bob"}, {When: t1, Author: "Ann"}},
+		},
+		{
+			name: "newest first, stable",
+			in:   []history.Commit{{Hash: "a", When: t1}, {Hash: "c", When: t3}, {Hash: "b", When: t1}, {Hash: "d", When: t2}},
+			want: []history.Commit{{Hash: "c", When: t3}, {Hash: "d", When: t2}, {Hash: "a", When: t1}, {Hash: "b", When: t1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dups := uniqueCommits(tt.in)
+			if !reflect.DeepEqual(got, tt.want) || dups != tt.dups {
+				t.Errorf("got %+v, %d duplicates\nwant %+v, %d", got, dups, tt.want, tt.dups)
+			}
+		})
+	}
+}
+
+func TestSlugsReserved(t *testing.T) {
+	ft := FileType{
+		"https://example.com/a/index":    {Name: "index"},
+		"https://example.com/b/Combined": {Name: "Combined"},
+		"https://example.com/c/tool":     {Name: "tool"},
+	}
+	got := ft.slugs()
+	want := map[string]string{
+		"https://example.com/a/index":    "index-2",
+		"https://example.com/b/Combined": "Combined-2",
+		"https://example.com/c/tool":     "tool",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("slugs %v, want %v", got, want)
+	}
+}
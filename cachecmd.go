@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+var cacheCommands = map[string]func(ctx context.Context, args []string) error{
+	"verify": verifyCache,
+	"prune":  pruneCommand,
+}
+
+// cacheCommand runs "cache verify" and the other cache maintenance
+// commands.
+func cacheCommand(ctx context.Context) error {
+	args := flag.Args()[1:]
+	var names []string
+	for name := range cacheCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(args) == 0 {
+		return fmt.Errorf("usage: cache %s", strings.Join(names, "|"))
+	}
+	cmd, ok := cacheCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown cache command %q, have %s", args[0], strings.Join(names, ", "))
+	}
+	return cmd(ctx, args[1:])
+}
+
+const quarantineDir = "quarantine"
+
+// futureSlack allows for clock skew before a commit counts as future dated.
+const futureSlack = 24 * time.Hour
+
+// verifyCache checks the shards and index for unreadable files, duplicate
+// commits, commits out of order and commits dated in the future. With
+// -repair, unreadable shards and future dated commits are moved to
+// cache/quarantine, duplicates are dropped, commits are sorted newest
+// first and the index is rebuilt from the shards.
+func verifyCache(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cache verify", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "fix or quarantine bad entries")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlockCache(lock)
+
+	problems := 0
+	report := func(format string, v ...interface{}) {
+		problems++
+		fmt.Printf(format+"\n", v...)
+	}
+	ix, err := openIndex()
+	if err != nil {
+		report("index: %v", err)
+		ix = cacheIndex{}
+	}
+	now := time.Now()
+	found := map[string]bool{}
+	st, err := cacheStore()
+	if err != nil {
+		return err
+	}
+	names, err := st.List(shardDir + "/")
+	if err != nil {
+		return err
+	}
+	check := func(path string) error {
+		if !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		b, err := st.Get(path)
+		if err != nil {
+			return err
+		}
+		var s shard
+		err = json.Unmarshal(b, &s)
+		if err == nil && shardName(s.URL) != path {
+			err = fmt.Errorf("holds %q, which belongs in %s", s.URL, shardName(s.URL))
+		}
+		if err != nil {
+			report("%s: %v", path, err)
+			if *repair {
+				return quarantine(path, b)
+			}
+			return nil
+		}
+		found[s.URL] = true
+
+		changed := false
+		var kept, future []history.Commit
+		seen := map[string]bool{}
+		dups := 0
+		for _, c := range s.Commits {
+			if len(c.Hash) > 0 {
+				if seen[c.Hash] {
+					dups++
+					continue
+				}
+				seen[c.Hash] = true
+			}
+			if c.When.After(now.Add(futureSlack)) {
+				future = append(future, c)
+				continue
+			}
+			kept = append(kept, c)
+		}
+		if dups > 0 {
+			report("%s: %d duplicate commits", s.URL, dups)
+			changed = true
+		}
+		if len(future) > 0 {
+			report("%s: %d commits dated in the future", s.URL, len(future))
+			changed = true
+		}
+		unordered := 0
+		for i := 1; i < len(kept); i++ {
+			if kept[i].When.After(kept[i-1].When) {
+				unordered++
+			}
+		}
+		if unordered > 0 {
+			report("%s: %d commits out of order", s.URL, unordered)
+			changed = true
+		}
+		if e := ix[s.URL]; e == nil || e.Commits != len(s.Commits) {
+			report("%s: index does not match the shard", s.URL)
+			changed = true
+		}
+		if !*repair || !changed {
+			return nil
+		}
+		if len(future) > 0 {
+			fb, err := json.Marshal(shard{URL: s.URL, chart: chart{Name: s.Name, Fetched: s.Fetched, Commits: future}})
+			if err != nil {
+				return err
+			}
+			err = quarantine(strings.TrimSuffix(path, ".json")+"-future.json", fb)
+			if err != nil {
+				return err
+			}
+		}
+		sort.SliceStable(kept, func(i, j int) bool {
+			return kept[i].When.After(kept[j].When)
+		})
+		s.Commits = kept
+		err = saveShard(s.URL, &s.chart)
+		if err != nil {
+			return err
+		}
+		ix.set(s.URL, &s.chart)
+		return nil
+	}
+	for _, name := range names {
+		err = check(name)
+		if err != nil {
+			return err
+		}
+	}
+	for u := range ix {
+		if !found[u] {
+			report("%s: in the index but has no shard", u)
+			if *repair {
+				delete(ix, u)
+			}
+		}
+	}
+	if problems == 0 {
+		fmt.Println("cache ok")
+		return nil
+	}
+	if !*repair {
+		return fmt.Errorf("%d problems found; run cache verify -repair to fix them", problems)
+	}
+	err = ix.write()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("repaired %d problems\n", problems)
+	return nil
+}
+
+// quarantine moves the content of a bad cache file, named as in the
+// store, to cache/quarantine and removes the original.
+func quarantine(name string, b []byte) error {
+	st, err := cacheStore()
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(cacheDir, quarantineDir, time.Now().Format("20060102-150405")+"-"+path.Base(name))
+	err = writeFileAtomic(dst, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("quarantined", name, "to", dst)
+	return st.Delete(name)
+}
+
+// pruneCommand runs "cache prune".
+func pruneCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("cache prune", flag.ContinueOnError)
+	dryRun := fs.Bool("n", false, "only list what would be removed")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	return pruneCache(cfg, *dryRun)
+}
+
+// pruneCache removes the cached commits and mirrors of repositories no
+// longer in the config, and chart and page files in the output directory
+// that the manifest does not list for a configured repository or group.
+func pruneCache(cfg *config, dryRun bool) error {
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlockCache(lock)
+
+	remove := func(path string) error {
+		fmt.Println("remove", path)
+		if dryRun {
+			return nil
+		}
+		return os.RemoveAll(path)
+	}
+
+	ix, err := openIndex()
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for u := range cfg.Repos {
+		keep[shardName(u)] = true
+		keep[mirrorPath(u)] = true
+	}
+	for u := range ix {
+		if _, ok := cfg.Repos[u]; !ok {
+			delete(ix, u)
+		}
+	}
+	st, err := cacheStore()
+	if err != nil {
+		return err
+	}
+	names, err := st.List(shardDir + "/")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if keep[name] {
+			continue
+		}
+		fmt.Println("remove", name)
+		if dryRun {
+			continue
+		}
+		err = st.Delete(name)
+		if err != nil {
+			return err
+		}
+	}
+	mirrors, err := os.ReadDir(filepath.Join(cacheDir, mirrorDir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, m := range mirrors {
+		path := filepath.Join(cacheDir, mirrorDir, m.Name())
+		if !keep[path] {
+			err = remove(path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !dryRun {
+		err = ix.write()
+		if err != nil {
+			return err
+		}
+	}
+
+	groups := map[string]bool{}
+	for _, g := range cfg.Groups {
+		groups[g.Name] = true
+	}
+	manifestPath := filepath.Join(outputDir, manifestFilename)
+	b, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var manifest, live []manifestEntry
+	err = json.Unmarshal(b, &manifest)
+	if err != nil {
+		return fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	files := map[string]bool{"index.html": true, heatmapFilename: true, correlationImage: true}
+	for _, e := range manifest {
+		_, ok := cfg.Repos[e.URL]
+		if len(e.URL) == 0 {
+			ok = groups[e.Name]
+		}
+		if !ok {
+			continue
+		}
+		live = append(live, e)
+		for _, f := range e.Files {
+			files[f] = true
+		}
+	}
+	outputs, err := os.ReadDir(outputDir)
+	if err != nil {
+		return err
+	}
+	for _, o := range outputs {
+		ext := filepath.Ext(o.Name())
+		if o.IsDir() || (ext != ".png" && ext != ".html") || files[o.Name()] || isCombinedFile(o.Name()) {
+			continue
+		}
+		err = remove(filepath.Join(outputDir, o.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	if dryRun || len(live) == len(manifest) {
+		return nil
+	}
+	return writeManifest(manifestPath, live)
+}
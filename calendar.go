@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+var (
+	businessDays = flag.Bool("business-days", false, "chart only Monday to Friday commits, as a rate per business day")
+	holidayFile  = flag.String("holidays", "", "file of dates, one 2006-01-02 per line, excluded with -business-days")
+)
+
+// loadCalendar returns the business day calendar of -business-days, or
+// nil if it is off.
+func loadCalendar() (*history.Calendar, error) {
+	if !*businessDays {
+		return nil, nil
+	}
+	cal := &history.Calendar{Holidays: map[string]bool{}}
+	if len(*holidayFile) == 0 {
+		return cal, nil
+	}
+	f, err := os.Open(*holidayFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if len(line) == 0 {
+			continue
+		}
+		_, err = time.Parse("2006-01-02", line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid date %q", *holidayFile, n, line)
+		}
+		cal.Holidays[line] = true
+	}
+	return cal, sc.Err()
+}
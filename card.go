@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var cards = flag.Bool("cards", false, "also render a 1200x630 social card image per repository")
+
+// cardFilename returns the file name of the social card of a repository.
+func cardFilename(slug string) string {
+	return slug + "--card.png"
+}
+
+var (
+	cardBackground = color.RGBA{R: 0x16, G: 0x1b, B: 0x22, A: 0xff}
+	cardText       = color.RGBA{R: 0xf0, G: 0xf3, B: 0xf6, A: 0xff}
+	cardMuted      = color.RGBA{R: 0x8b, G: 0x94, B: 0x9e, A: 0xff}
+	cardSpark      = color.RGBA{R: 0x3f, G: 0xb9, B: 0x50, A: 0xff}
+)
+
+// renderCard draws a 1200x630 pixel image for link previews: the name and
+// URL of the repository, its key numbers and a sparkline of the last year.
+func renderCard(u string, ch *chart, filename string) error {
+	const dpi = 96
+	w := 1200 * vg.Inch / dpi
+	h := 630 * vg.Inch / dpi
+	px := func(n float64) vg.Length { return vg.Length(n) * vg.Inch / dpi }
+
+	c, err := draw.NewFormattedCanvas(w, h, "png")
+	if err != nil {
+		return err
+	}
+	dc := draw.New(c)
+	dc.FillPolygon(cardBackground, []vg.Point{{X: 0, Y: 0}, {X: w, Y: 0}, {X: w, Y: h}, {X: 0, Y: h}})
+
+	sized := func(size float64) font.Font {
+		f := plot.DefaultFont
+		f.Size = px(size)
+		return f
+	}
+	titleFont, textFont := sized(64), sized(28)
+	margin := px(72)
+	dc.FillText(draw.TextStyle{Color: cardText, Font: titleFont, YAlign: draw.YTop, Handler: plot.DefaultTextHandler}, vg.Point{X: margin, Y: h - margin}, fitWidth(ch.Name, 32))
+	dc.FillText(draw.TextStyle{Color: cardMuted, Font: textFont, YAlign: draw.YTop, Handler: plot.DefaultTextHandler}, vg.Point{X: margin, Y: h - margin - px(90)}, fitWidth(u, 70))
+
+	now := renderTime()
+	authors := map[string]bool{}
+	var last time.Time
+	for _, c := range ch.Commits {
+		for _, p := range c.Authors() {
+			authors[p.Key()] = true
+		}
+		if c.When.After(last) && !c.When.After(now) {
+			last = c.When
+		}
+	}
+	stats := fmt.Sprintf("%d commits · %d contributors · health %d", len(ch.Commits), len(authors), history.NewHealth(ch.Commits, now).Score)
+	if !last.IsZero() {
+		stats += " · last commit " + last.Format("2006-01-02")
+	}
+	dc.FillText(draw.TextStyle{Color: cardText, Font: textFont, YAlign: draw.YTop, Handler: plot.DefaultTextHandler}, vg.Point{X: margin, Y: h - margin - px(150)}, stats)
+
+	// Weekly commits of the last year as a filled area along the bottom.
+	counts := weeklyCounts(ch, now, 52)
+	max := maxCount(counts)
+	if max > 0 {
+		left, right := margin, w-margin
+		bottom, top := margin, margin+px(220)
+		step := (right - left) / vg.Length(len(counts)-1)
+		area := []vg.Point{{X: left, Y: bottom}}
+		line := make([]vg.Point, 0, len(counts))
+		for i, n := range counts {
+			pt := vg.Point{
+				X: left + step*vg.Length(i),
+				Y: bottom + (top-bottom)*vg.Length(n)/vg.Length(max),
+			}
+			area = append(area, pt)
+			line = append(line, pt)
+		}
+		area = append(area, vg.Point{X: right, Y: bottom})
+		fill := cardSpark
+		fill.A = 0x50
+		dc.FillPolygon(fill, area)
+		dc.StrokeLines(draw.LineStyle{Color: cardSpark, Width: px(4)}, line)
+		dc.FillText(draw.TextStyle{Color: cardMuted, Font: textFont, XAlign: draw.XRight, YAlign: draw.YTop, Handler: plot.DefaultTextHandler}, vg.Point{X: right, Y: bottom - px(12)}, "weekly commits, last 52 weeks")
+	}
+
+	return writeCanvas(c, filename)
+}
+
+// writeCanvas draws the watermark on c and writes its image to filename.
+func writeCanvas(c vg.CanvasWriterTo, filename string) error {
+	err := drawWatermark(draw.New(c))
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		_, err := c.WriteTo(w)
+		return err
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var changeThreshold = flag.Float64("change", 50, "report repositories whose weekly commit rate changed by more than this percent since the previous run")
+
+const (
+	previousFilename = "previous.json"
+
+	// rateWeeks is the number of weeks the commit rate is averaged over.
+	rateWeeks = 4
+	// inactiveAfter is the time without commits after which a repository
+	// is inactive.
+	inactiveAfter = 90 * 24 * time.Hour
+)
+
+func previousPath() string { return filepath.Join(cacheDir, previousFilename) }
+
+// aggregates are the per repository numbers kept between runs.
+type aggregates struct {
+	Time  time.Time
+	Repos map[string]aggregate
+}
+
+type aggregate struct {
+	Rate    float64 // Commits per week.
+	Last    time.Time
+	Commits int
+}
+
+// change is a difference between the previous run and this one.
+type change struct {
+	Kind    string // "rate" or "inactive".
+	Before  float64
+	After   float64
+	Percent float64 `json:",omitempty"`
+	Message string
+}
+
+func newAggregates(now time.Time) aggregates {
+	return aggregates{Time: now, Repos: map[string]aggregate{}}
+}
+
+// add records the numbers of the repository u.
+func (a aggregates) add(u string, ch *chart) {
+	since := a.Time.Add(-rateWeeks * 7 * 24 * time.Hour)
+	var g aggregate
+	n := 0
+	for _, c := range ch.Commits {
+		if c.When.After(a.Time) {
+			continue
+		}
+		if c.When.After(g.Last) {
+			g.Last = c.When
+		}
+		if !c.When.Before(since) {
+			n++
+		}
+	}
+	g.Commits = len(ch.Commits)
+	g.Rate = float64(n) / rateWeeks
+	a.Repos[u] = g
+}
+
+// readAggregates reads the previous run, which is empty if there was none.
+func readAggregates(fn string) (aggregates, error) {
+	var a aggregates
+	b, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return a, err
+	}
+	err = json.Unmarshal(b, &a)
+	if err != nil {
+		return a, fmt.Errorf("%s: %w", fn, err)
+	}
+	return a, nil
+}
+
+func (a aggregates) write(fn string) error {
+	return writeFileAtomic(fn, func(w io.Writer) error {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		return e.Encode(a)
+	})
+}
+
+// change compares the repository u with the previous run. It returns nil
+// if nothing changed or u was not in the previous run.
+func (a aggregates) change(prev aggregates, u string, threshold float64) *change {
+	cur, ok := a.Repos[u]
+	if !ok {
+		return nil
+	}
+	old, ok := prev.Repos[u]
+	if !ok {
+		return nil
+	}
+	wasActive := prev.Time.Sub(old.Last) < inactiveAfter
+	if wasActive && a.Time.Sub(cur.Last) >= inactiveAfter {
+		return &change{
+			Kind:    "inactive",
+			Before:  old.Rate,
+			After:   cur.Rate,
+			Message: fmt.Sprintf("inactive, no commits since %s", cur.Last.Format("2006-01-02")),
+		}
+	}
+	if old.Rate == 0 {
+		return nil
+	}
+	pct := (cur.Rate - old.Rate) / old.Rate * 100
+	if math.Abs(pct) <= threshold {
+		return nil
+	}
+	return &change{
+		Kind:    "rate",
+		Before:  old.Rate,
+		After:   cur.Rate,
+		Percent: pct,
+		Message: fmt.Sprintf("weekly rate %+.0f%%, %.1f to %.1f commits per week", pct, old.Rate, cur.Rate),
+	}
+}
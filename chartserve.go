@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// chartImage handles /chart/{slug}.svg and /chart/{slug}.png, rendering the
+// chart of a repository on demand with the options of the query:
+// metric, window, since and until as for the series API, and style.
+func (s *server) chartImage(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/chart/")
+	ext := path.Ext(name)
+	if (ext != ".svg" && ext != ".png") || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	d, err := s.data()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	u, ok := d.bySlug[strings.TrimSuffix(name, ext)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	v := r.URL.Query()
+	if len(v.Get("window")) == 0 {
+		v.Set("window", s.cfg.window(u))
+	}
+	q, err := parseSeriesQuery(v)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	style := v.Get("style")
+	if len(style) == 0 {
+		style = s.cfg.chartStyle(u)
+	}
+	err = checkChartStyle(style)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	loc, err := lookupLocale(*localeName)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The cached chart is shared, so the commits in range go in a copy.
+	ch := *d.charts[u]
+	ch.Commits = nil
+	for _, c := range d.charts[u].Commits {
+		if !c.When.Before(q.since) && c.When.Before(q.until) {
+			ch.Commits = append(ch.Commits, c)
+		}
+	}
+	opt := chartOptions{
+		loc:        loc,
+		url:        u,
+		notes:      annotationsFor(s.notes, u, ch.Name),
+		window:     q.window,
+		style:      s.cfg.repoStyle(u, d.slugs[u]),
+		chartStyle: style,
+	}
+	dir, err := os.MkdirTemp("", "gitgraph-chart")
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "chart"+ext)
+	err = display(&ch, q.metric, opt, fn)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// Charts change at most once per run.
+	w.Header().Set("Cache-Control", "max-age=300")
+	http.ServeFile(w, r, fn)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+var combinedCharts = flag.Bool("combined", false, "also render "+combinedSlug+".png with the commits of all repositories, one such chart per metric, and the interactive "+combinedSlug+".html")
+
+// combinedSlug names the combined charts like the charts of a repository.
+const combinedSlug = "combined"
+
+// combinedPage is the data passed to the combined.html template.
+type combinedPage struct {
+	Title     string
+	Generated time.Time
+	Window    string
+	Charts    []combinedChart
+}
+
+type combinedChart struct {
+	Metric string
+	Image  string
+	Series []combinedSeries
+}
+
+// combinedSeries is a line of the interactive chart, with points as
+// Unix seconds and value.
+type combinedSeries struct {
+	Name   string
+	Color  string
+	Dashes []float64 `json:",omitempty"` // SVG stroke-dasharray in points.
+	Points [][2]float64
+}
+
+// combined collects a line per repository and metric for the combined
+// charts.
+type combined struct {
+	window  string
+	metrics []string
+	lines   map[string][]combinedLine // By metric.
+	styles  styleSet
+	images  []string // Written by render, by metric.
+}
+
+type combinedLine struct {
+	name  string
+	style lineStyle
+	data  plotter.XYs
+}
+
+func newCombined(window string, metrics []string) *combined {
+	return &combined{window: window, metrics: metrics, lines: map[string][]combinedLine{}}
+}
+
+// add adds the lines of a repository to each combined chart.
+func (cb *combined) add(cfg *config, u, slug string, ch *chart, opt chartOptions) error {
+	opt.window = cb.window
+	_, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	st := cb.styles.pick(cfg, u, slug)
+	for _, metric := range cb.metrics {
+		data, err := seriesPoints(ch, metric, w, opt.cal, renderTime())
+		if err != nil {
+			return err
+		}
+		cb.lines[metric] = append(cb.lines[metric], combinedLine{name: ch.Name, style: st, data: data})
+	}
+	return nil
+}
+
+// render writes a combined chart per metric to dir and returns the file
+// names.
+func (cb *combined) render(dir string, loc locale) ([]string, error) {
+	var files []string
+	for _, metric := range cb.metrics {
+		p := plot.New()
+		p.Title.Text = "All repositories"
+		p.Y.Label.Text = fmt.Sprintf("%s (%s)", metric, cb.window)
+		p.X.Tick.Marker = plot.TimeTicks{Format: loc.DateFormat}
+		p.Add(plotter.NewGrid())
+		p.Legend.Top = true
+		p.Legend.Left = true
+		for _, l := range cb.lines[metric] {
+			if len(l.data) == 0 {
+				continue
+			}
+			line, err := plotter.NewLine(l.data)
+			if err != nil {
+				return nil, err
+			}
+			line.Color = l.style.Color
+			line.Dashes = l.style.Dashes
+			p.Add(line)
+			p.Legend.Add(l.name, line)
+		}
+		fn := chartFilename(combinedSlug, metric)
+		err := savePlot(p, filepath.Join(dir, fn))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fn)
+	}
+	cb.images = files
+	return files, nil
+}
+
+// writeHTML writes the interactive page of the combined charts to dir,
+// after render.
+func (cb *combined) writeHTML(dir, templateDir, title string, generated time.Time) error {
+	t, err := loadTemplate(templateDir, combinedSlug+".html")
+	if err != nil {
+		return err
+	}
+	page := combinedPage{Title: title, Generated: generated, Window: cb.window}
+	for i, metric := range cb.metrics {
+		cc := combinedChart{Metric: metric}
+		if i < len(cb.images) {
+			cc.Image = cb.images[i]
+		}
+		for _, l := range cb.lines[metric] {
+			s := combinedSeries{
+				Name:   l.name,
+				Color:  hexColor(l.style.Color),
+				Points: make([][2]float64, len(l.data)),
+			}
+			for _, d := range l.style.Dashes {
+				s.Dashes = append(s.Dashes, float64(d))
+			}
+			for j, pt := range l.data {
+				s.Points[j] = [2]float64{pt.X, pt.Y}
+			}
+			cc.Series = append(cc.Series, s)
+		}
+		page.Charts = append(page.Charts, cc)
+	}
+	return writeFileAtomic(filepath.Join(dir, combinedSlug+".html"), func(w io.Writer) error {
+		return t.Execute(w, page)
+	})
+}
+
+// isCombinedFile reports if name is a combined chart or page.
+func isCombinedFile(name string) bool {
+	return strings.TrimSuffix(name, filepath.Ext(name)) == combinedSlug || strings.HasPrefix(name, combinedSlug+"--")
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// config is read from the -config file. Repos is keyed by clone URL.
+type config struct {
+	// Title is shown on the index page.
+	Title string
+	// ChartTitle and ChartSubtitle are text/template templates of the
+	// chart titles; see titleData for the fields.
+	ChartTitle    string `json:",omitempty"`
+	ChartSubtitle string `json:",omitempty"`
+	// Palette names the palette of the repository lines, okabe-ito if
+	// empty.
+	Palette string `json:",omitempty"`
+	// Watermark is drawn on every chart image when set.
+	Watermark *watermarkConfig `json:",omitempty"`
+	// TTL is how long cached commits are used before they are fetched
+	// again. Zero caches forever.
+	TTL duration
+	// Timeout limits fetching each repository; 30m if zero, none if
+	// negative.
+	Timeout duration `json:",omitempty"`
+	Hooks   hooks
+	// Metrics defines additional metrics that count commits touching
+	// matching paths.
+	Metrics []pathMetricConfig
+	// Ignore lists patterns, in .gitattributes syntax, of files left out
+	// of file and line based metrics, in addition to those marked
+	// linguist-generated or linguist-vendored.
+	Ignore []string `json:",omitempty"`
+	// Licenses maps path patterns to the license of the matching files,
+	// whose commits are charted by license.
+	Licenses map[string]string `json:",omitempty"`
+	// Backports are patterns of release branch names, such as "release-*",
+	// whose commits cherry-picked from or to the default branch are
+	// charted.
+	Backports []string `json:",omitempty"`
+	// CIPaths replaces the patterns of CI and build files of the ci
+	// metric.
+	CIPaths []string `json:",omitempty"`
+	// Classify replaces the patterns that tell fixes from features.
+	Classify *classifyConfig `json:",omitempty"`
+	// Email sends a weekly digest when set.
+	Email *emailConfig `json:",omitempty"`
+	// Alerts are checked for every repository after each run.
+	Alerts []alertRule `json:",omitempty"`
+	// Webhook receives the alerts and changes of a run as JSON.
+	Webhook string `json:",omitempty"`
+	// Dates sets which commit dates are implausible, such as 1970, and
+	// whether those commits are excluded from the charts.
+	Dates *datesConfig `json:",omitempty"`
+	// Quota limits the size of mirrors and the cache, and the download
+	// rate.
+	Quota *quotaConfig `json:",omitempty"`
+	// Auth protects the pages and API of the serve command when set.
+	Auth *authConfig `json:",omitempty"`
+	// Baseline is a repository URL or name drawn faintly behind the charts
+	// of all others.
+	Baseline string `json:",omitempty"`
+	// FiscalYearStart is the month, 1 to 12, the fiscal-year and
+	// fiscal-quarter windows start in. January if zero.
+	FiscalYearStart int `json:",omitempty"`
+	// Groups are charted as one repository with shared commits counted
+	// once.
+	Groups []groupConfig `json:",omitempty"`
+	// Dependencies are go.mod or package.json files, relative to the
+	// config file, whose dependencies are charted as well.
+	Dependencies []string `json:",omitempty"`
+	// Backend reads repositories with "go-git", the default, or "cli" to
+	// run the system git.
+	Backend string `json:",omitempty"`
+	// Color, Branch and Window are the defaults of the repository
+	// settings of the same names.
+	Color  string `json:",omitempty"`
+	Branch string `json:",omitempty"`
+	Window string `json:",omitempty"`
+	// Style is how metric charts draw their values: line, bar, step or
+	// area.
+	Style string `json:",omitempty"`
+	// CacheDir and OutputDir, relative to the config file, are used
+	// unless -cache-dir and -output-dir are given.
+	CacheDir  string `json:",omitempty"`
+	OutputDir string `json:",omitempty"`
+	// Mailmap is a file in git .mailmap format, relative to the config
+	// file, giving each person one identity.
+	Mailmap string `json:",omitempty"`
+	// Credentials maps the environment variables credentials are read
+	// from, such as GITHUB_TOKEN, to other variables to read them from.
+	Credentials map[string]string `json:",omitempty"`
+	Repos       map[string]*repoConfig
+	// Profiles are named sets of settings that replace those above when
+	// selected with -profile.
+	Profiles map[string]json.RawMessage `json:",omitempty"`
+
+	// partial is set when only some repositories were selected on the
+	// command line.
+	partial bool
+	// classify holds the compiled Classify patterns.
+	classify history.Classifier
+}
+
+type pathMetricConfig struct {
+	Name  string
+	Paths []string
+}
+
+// classifyConfig holds regular expressions matched against commit
+// subjects. An empty list keeps the built-in patterns.
+type classifyConfig struct {
+	Fix     []string
+	Feature []string
+}
+
+func compilePatterns(list []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, len(list))
+	for i, p := range list {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = re
+	}
+	return res, nil
+}
+
+type repoConfig struct {
+	Name    string
+	TTL     *duration `json:",omitempty"`
+	Timeout *duration `json:",omitempty"`
+	Backend string    `json:",omitempty"`
+	// MaxSize replaces the MaxRepoSize of the Quota.
+	MaxSize *byteSize `json:",omitempty"`
+	// Blobless clones the repository without file contents, with the cli
+	// backend.
+	Blobless bool `json:",omitempty"`
+	Hooks    hooks
+	// Alerts replace the global alert rules if set.
+	Alerts []alertRule `json:",omitempty"`
+	// Color is the chart line color, such as "#1f77b4".
+	Color string `json:",omitempty"`
+	// Branch is analyzed instead of the default branch of the remote.
+	Branch string `json:",omitempty"`
+	// Window is the period each chart point covers, unless -window is
+	// given.
+	Window string `json:",omitempty"`
+	// Style replaces the global chart style.
+	Style string `json:",omitempty"`
+	// Ignore lists more patterns of files left out of the metrics of
+	// this repository.
+	Ignore []string `json:",omitempty"`
+	// Licenses replaces the global path to license map.
+	Licenses map[string]string `json:",omitempty"`
+	// Backports replaces the global release branch patterns.
+	Backports []string `json:",omitempty"`
+}
+
+var defaultConfig = config{
+	Title: "Git Graph",
+	Repos: map[string]*repoConfig{
+		"https://github.com/linuxdeepin/dde-daemon": {
+			Name: "DDE Daemon",
+		},
+		"https://github.com/linuxdeepin/dde-dock": {
+			Name: "DDE Dock",
+		},
+		"https://github.com/linuxdeepin/dde-session-shell": {
+			Name: "DDE Session Shell",
+		},
+	},
+}
+
+// loadConfig reads the config file at location. If the file does not
+// exist the built-in repository list is used.
+func loadConfig(location string) (*config, error) {
+	b, err := os.ReadFile(location)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if len(*profileName) > 0 {
+				return nil, fmt.Errorf("no config %q for profile %q", location, *profileName)
+			}
+			cfg := defaultConfig
+			return &cfg, nil
+		}
+		return nil, err
+	}
+
+	cfg := &config{Title: defaultConfig.Title}
+	err = decodeConfig(b, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("config %q: %w", location, err)
+	}
+	cfg.useDirs(filepath.Dir(location))
+	credentialEnv = cfg.Credentials
+	if cfg.Repos == nil {
+		cfg.Repos = map[string]*repoConfig{}
+	}
+	for u, rc := range cfg.Repos {
+		if rc == nil {
+			return nil, fmt.Errorf("config %q: repo %q has no settings", location, u)
+		}
+		if len(rc.Name) == 0 {
+			rc.Name = nameFromURL(u)
+		}
+	}
+	if len(cfg.Dependencies) > 0 {
+		err = cfg.addDependencies(filepath.Dir(location), cfg.Dependencies)
+		if err != nil {
+			return nil, fmt.Errorf("config %q: %w", location, err)
+		}
+	}
+	for _, p := range cfg.CIPaths {
+		if _, err = path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("config %q: CIPaths %q: %w", location, p, err)
+		}
+	}
+	if cl := cfg.Classify; cl != nil {
+		if len(cl.Fix) > 0 {
+			cfg.classify.Fix, err = compilePatterns(cl.Fix)
+			if err != nil {
+				return nil, fmt.Errorf("config %q: Classify: %w", location, err)
+			}
+		}
+		if len(cl.Feature) > 0 {
+			cfg.classify.Feature, err = compilePatterns(cl.Feature)
+			if err != nil {
+				return nil, fmt.Errorf("config %q: Classify: %w", location, err)
+			}
+		}
+	}
+	start := time.January
+	if cfg.FiscalYearStart != 0 {
+		if cfg.FiscalYearStart < 1 || cfg.FiscalYearStart > 12 {
+			return nil, fmt.Errorf("config %q: FiscalYearStart must be a month from 1 to 12", location)
+		}
+		start = time.Month(cfg.FiscalYearStart)
+	}
+	// Registered on every load so a reload without FiscalYearStart goes
+	// back to calendar years.
+	history.RegisterWindow("fiscal-year", history.FiscalYear(start))
+	history.RegisterWindow("fiscal-quarter", history.FiscalQuarter(start))
+	if len(cfg.Baseline) > 0 {
+		_, err = cfg.lookup(cfg.Baseline)
+		if err != nil {
+			return nil, fmt.Errorf("config %q: baseline: %w", location, err)
+		}
+	}
+	for _, g := range cfg.Groups {
+		_, err = cfg.groupURLs(g)
+		if err != nil {
+			return nil, fmt.Errorf("config %q: %w", location, err)
+		}
+	}
+	err = cfg.checkOverrides()
+	if err != nil {
+		return nil, fmt.Errorf("config %q: %w", location, err)
+	}
+	return cfg, nil
+}
+
+// nameFromURL uses the last path element of the URL as the name.
+func nameFromURL(u string) string {
+	u = strings.TrimSuffix(strings.TrimRight(u, "/"), ".git")
+	u = strings.TrimSuffix(u, ".bundle")
+	if i := strings.LastIndexAny(u, "/:"); i >= 0 {
+		u = u[i+1:]
+	}
+	return u
+}
+
+// lookup returns the URL of the repository with URL or name r.
+func (cfg *config) lookup(r string) (string, error) {
+	for _, u := range cfg.urls() {
+		if (list{r}).match(u, cfg.Repos[u].Name) {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("repository %q is not in the config", r)
+}
+
+// loadBaseline reads the cached commits of the baseline repository, set
+// by -baseline or the config. It returns nil if there is none.
+func (cfg *config) loadBaseline() (string, *chart, error) {
+	name := cfg.Baseline
+	if len(*baselineRepo) > 0 {
+		name = *baselineRepo
+	}
+	if len(name) == 0 {
+		return "", nil, nil
+	}
+	u, err := cfg.lookup(name)
+	if err != nil {
+		return "", nil, err
+	}
+	ch := cfg.chart(u)
+	err = loadShard(u, ch)
+	if err != nil {
+		return "", nil, err
+	}
+	cfg.sanitizeDates(ch, time.Now())
+	return u, ch, nil
+}
+
+// charts returns an empty chart for each configured repository.
+func (cfg *config) charts() FileType {
+	ft := make(FileType, len(cfg.Repos))
+	for u := range cfg.Repos {
+		ft[u] = cfg.chart(u)
+	}
+	return ft
+}
+
+// chart returns an empty chart for the repository u.
+func (cfg *config) chart(u string) *chart {
+	rc := cfg.Repos[u]
+	ignore := append(append([]string(nil), cfg.Ignore...), rc.Ignore...)
+	return &chart{Name: rc.Name, ignore: ignore, licenses: cfg.licenses(u), newMetric: cfg.newMetric}
+}
+
+// newMetric returns a new instance of the named metric, with the CIPaths
+// and Classify patterns of cfg in place of the built-in ones.
+func (cfg *config) newMetric(name string) history.Metric {
+	switch name {
+	case "ci":
+		if len(cfg.CIPaths) > 0 {
+			return history.NewPathMetric(name, cfg.CIPaths...)
+		}
+	case "fixes", "features", "fix-ratio":
+		return cfg.classify.New(name)
+	}
+	return history.New(name)
+}
+
+// urls returns the configured repository URLs in sorted order.
+func (cfg *config) urls() []string {
+	list := make([]string, 0, len(cfg.Repos))
+	for u := range cfg.Repos {
+		list = append(list, u)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// slugs returns the file name slug of each configured repository.
+func (cfg *config) slugs() map[string]string {
+	return cfg.charts().slugs()
+}
+
+// window returns the window of the charts of u: -window if given, else
+// the repository or global config. An empty u returns the global one.
+func (cfg *config) window(u string) string {
+	switch {
+	case flagSet("window"):
+		return *windowName
+	case cfg.Repos[u] != nil && len(cfg.Repos[u].Window) > 0:
+		return cfg.Repos[u].Window
+	case len(cfg.Window) > 0:
+		return cfg.Window
+	}
+	return *windowName
+}
+
+// color returns the line color of the charts of u, or nil for the
+// default.
+func (cfg *config) color(u string) color.Color {
+	s := cfg.Color
+	if rc := cfg.Repos[u]; rc != nil && len(rc.Color) > 0 {
+		s = rc.Color
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	c, _ := parseColor(s) // Checked by loadConfig.
+	return c
+}
+
+// branch returns the branch to analyze of u, or "" for the default
+// branch of the remote.
+func (cfg *config) branch(u string) string {
+	if rc := cfg.Repos[u]; rc != nil && len(rc.Branch) > 0 {
+		return rc.Branch
+	}
+	return cfg.Branch
+}
+
+// checkOverrides validates the colors, windows, styles and ignore
+// patterns of the config and its repositories.
+func (cfg *config) checkOverrides() error {
+	check := func(where, c, w, style string, ignore []string) error {
+		if err := checkChartStyle(style); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		if len(c) > 0 {
+			if _, err := parseColor(c); err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+		}
+		if len(w) > 0 && history.LookupWindow(w) == nil {
+			return fmt.Errorf("%s: unknown window %q, have %s", where, w, strings.Join(history.WindowNames(), ", "))
+		}
+		for _, p := range ignore {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("%s: Ignore %q: %w", where, p, err)
+			}
+		}
+		return nil
+	}
+	err := check("config", cfg.Color, cfg.Window, cfg.Style, cfg.Ignore)
+	if err != nil {
+		return err
+	}
+	err = checkLicenses("config", cfg.Licenses)
+	if err != nil {
+		return err
+	}
+	_, _, err = cfg.dateRule()
+	if err != nil {
+		return err
+	}
+	for _, u := range cfg.urls() {
+		rc := cfg.Repos[u]
+		err = check(u, rc.Color, rc.Window, rc.Style, rc.Ignore)
+		if err != nil {
+			return err
+		}
+		err = checkLicenses(u, rc.Licenses)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseColor parses a color written as #rgb or #rrggbb.
+func parseColor(s string) (color.Color, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil || len(h) != 6 || h == s {
+		return nil, fmt.Errorf("invalid color %q, use #rrggbb", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+func (cfg *config) ttl(u string) time.Duration {
+	if rc := cfg.Repos[u]; rc != nil && rc.TTL != nil {
+		return time.Duration(*rc.TTL)
+	}
+	return time.Duration(cfg.TTL)
+}
+
+// configMetrics are the paths of the metrics registered from a config,
+// by name. A server loads the config again for every run.
+var configMetrics = map[string][]string{}
+
+// metrics registers the configured path metrics and resolves the list
+// of metric names to chart. A metric registered by an earlier load is
+// kept if unchanged and replaced otherwise.
+func (cfg *config) metrics(names list) ([]string, error) {
+	for _, mc := range cfg.Metrics {
+		if len(mc.Paths) == 0 {
+			return nil, fmt.Errorf("metric %q has no paths", mc.Name)
+		}
+		for _, d := range derivedCharts {
+			if strings.EqualFold(slugify(mc.Name), d) {
+				return nil, fmt.Errorf("metric %q would share the file name of the %s chart", mc.Name, d)
+			}
+		}
+		paths, ok := configMetrics[mc.Name]
+		switch {
+		case ok && strings.Join(paths, "\x00") == strings.Join(mc.Paths, "\x00"):
+			continue
+		case !ok && history.New(mc.Name) != nil:
+			return nil, fmt.Errorf("metric %q is already defined", mc.Name)
+		}
+		mc := mc
+		configMetrics[mc.Name] = mc.Paths
+		history.Replace(func() history.Metric {
+			return history.NewPathMetric(mc.Name, mc.Paths...)
+		})
+	}
+	for _, name := range names {
+		if name == "all" {
+			return history.Names(), nil
+		}
+		if history.New(name) == nil {
+			return nil, fmt.Errorf("unknown metric %q, have %s", name, strings.Join(history.Names(), ", "))
+		}
+	}
+	return names, nil
+}
+
+// hooks returns the hooks for u; repository hooks replace global ones.
+func (cfg *config) hooks(u string) hooks {
+	h := cfg.Hooks
+	if rc := cfg.Repos[u]; rc != nil {
+		if len(rc.Hooks.Pre) > 0 {
+			h.Pre = rc.Hooks.Pre
+		}
+		if len(rc.Hooks.Post) > 0 {
+			h.Post = rc.Hooks.Post
+		}
+	}
+	return h
+}
+
+// duration is a time.Duration that also accepts a "d" suffix for days,
+// and is written as a string in JSON.
+type duration time.Duration
+
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *duration) Set(s string) error {
+	v, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	return d.Set(s)
+}
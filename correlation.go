@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"math"
+	"strconv"
+	"time"
+)
+
+var correlateWeeks = flag.Int("correlate", 0, "write "+correlationCSV+" and "+correlationImage+" with the correlation of the weekly commits of each pair of repositories over the last N weeks; zero disables it")
+
+const (
+	correlationCSV   = "correlation.csv"
+	correlationImage = "correlation.png"
+)
+
+// correlation collects the weekly commit counts of each repository.
+type correlation struct {
+	now    time.Time
+	weeks  int
+	names  []string
+	series [][]int
+}
+
+func newCorrelation(now time.Time, weeks int) *correlation {
+	return &correlation{now: now, weeks: weeks}
+}
+
+func (co *correlation) add(ch *chart) {
+	co.names = append(co.names, ch.Name)
+	co.series = append(co.series, weeklyCounts(ch, co.now, co.weeks))
+}
+
+// pearson returns the correlation coefficient of a and b, or NaN if either
+// has no variance.
+func pearson(a, b []int) float64 {
+	n := float64(len(a))
+	var sa, sb float64
+	for i := range a {
+		sa += float64(a[i])
+		sb += float64(b[i])
+	}
+	ma, mb := sa/n, sb/n
+	var cov, va, vb float64
+	for i := range a {
+		da, db := float64(a[i])-ma, float64(b[i])-mb
+		cov += da * db
+		va += da * da
+		vb += db * db
+	}
+	if va == 0 || vb == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(va*vb)
+}
+
+// matrix returns the correlation of every pair of repositories.
+func (co *correlation) matrix() [][]float64 {
+	m := make([][]float64, len(co.series))
+	for i := range m {
+		m[i] = make([]float64, len(co.series))
+		for j := range m[i] {
+			if i == j {
+				m[i][j] = 1
+				continue
+			}
+			if j < i {
+				m[i][j] = m[j][i]
+				continue
+			}
+			m[i][j] = pearson(co.series[i], co.series[j])
+		}
+	}
+	return m
+}
+
+// write writes the matrix as CSV and as an image. Pairs where a
+// repository had no commits in the period are left empty.
+func (co *correlation) write(csvFile, imageFile string) error {
+	m := co.matrix()
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	err := writeMatrixCSV(co.names, m, format, csvFile)
+	if err != nil {
+		return err
+	}
+	format = func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	return renderMatrix(co.names, m, correlationShade, format, imageFile)
+}
+
+var (
+	correlationNone     = color.RGBA{R: 0xeb, G: 0xed, B: 0xf0, A: 0xff}
+	correlationPositive = color.RGBA{R: 0xb3, G: 0x1b, B: 0x1b, A: 0xff}
+	correlationNegative = color.RGBA{R: 0x1f, G: 0x5f, B: 0xb4, A: 0xff}
+)
+
+// correlationShade mixes white with red for positive and blue for negative
+// correlation.
+func correlationShade(v float64) color.Color {
+	if math.IsNaN(v) {
+		return correlationNone
+	}
+	to := correlationPositive
+	if v < 0 {
+		to = correlationNegative
+		v = -v
+	}
+	mix := func(b uint8) uint8 { return uint8(0xff + (float64(b)-0xff)*v) }
+	return color.RGBA{R: mix(to.R), G: mix(to.G), B: mix(to.B), A: 0xff}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var badDates = flag.String("bad-dates", "", "what to do with commits dated before the config Dates Min or more than a day in the future: exclude (default), clamp or keep")
+
+// defaultMinDate is the earliest plausible commit date. Commits at or
+// near the Unix epoch come from unset clocks and broken imports; history
+// converted from older systems rarely predates it.
+var defaultMinDate = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// datesConfig sets what counts as an implausible commit date and what is
+// done with such commits.
+type datesConfig struct {
+	// Min is the earliest plausible date, as 2006-01-02; 1980-01-01 if
+	// empty. Commits more than a day in the future are implausible too.
+	Min string `json:",omitempty"`
+	// Action is exclude, the default, to leave the commits out of the
+	// charts, clamp to move them to Min or now, or keep.
+	Action string `json:",omitempty"`
+}
+
+// dateReport lists the commits of a repository with implausible dates.
+type dateReport struct {
+	Action  string
+	Commits []badDate
+}
+
+type badDate struct {
+	Hash string `json:",omitempty"`
+	When time.Time
+}
+
+func (r *dateReport) String() string {
+	verb := "excluded"
+	if r.Action == "clamp" {
+		verb = "clamped"
+	}
+	first, last := r.Commits[0].When, r.Commits[0].When
+	for _, c := range r.Commits {
+		if c.When.Before(first) {
+			first = c.When
+		}
+		if c.When.After(last) {
+			last = c.When
+		}
+	}
+	return fmt.Sprintf("%d commits with implausible dates %s, %s to %s", len(r.Commits), verb, first.Format("2006-01-02"), last.Format("2006-01-02"))
+}
+
+// dateRule returns the action and earliest date of the config, -bad-dates
+// replacing the action.
+func (cfg *config) dateRule() (action string, min time.Time, err error) {
+	var dc datesConfig
+	if cfg.Dates != nil {
+		dc = *cfg.Dates
+	}
+	action, min = dc.Action, defaultMinDate
+	if len(*badDates) > 0 {
+		action = *badDates
+	}
+	switch action {
+	case "":
+		action = "exclude"
+	case "exclude", "clamp", "keep":
+	default:
+		return "", min, fmt.Errorf("unknown date action %q, use exclude, clamp or keep", action)
+	}
+	if len(dc.Min) > 0 {
+		min, err = time.Parse("2006-01-02", dc.Min)
+		if err != nil {
+			return "", min, fmt.Errorf("config Dates Min: %w", err)
+		}
+	}
+	return action, min, nil
+}
+
+// sanitizeDates excludes or clamps the commits of ch dated before the
+// earliest plausible date or more than a day after now, so they do not
+// stretch the time axis. The cache keeps them as read. It returns what
+// was changed, nil if nothing was.
+func (cfg *config) sanitizeDates(ch *chart, now time.Time) *dateReport {
+	action, min, err := cfg.dateRule()
+	if err != nil || action == "keep" {
+		// The rule is checked when the config is loaded.
+		return nil
+	}
+	max := now.Add(futureSlack)
+	rep := &dateReport{Action: action}
+	commits := ch.Commits[:0:0]
+	for _, c := range ch.Commits {
+		if !c.When.Before(min) && !c.When.After(max) {
+			commits = append(commits, c)
+			continue
+		}
+		rep.Commits = append(rep.Commits, badDate{Hash: c.Hash, When: c.When})
+		if action == "clamp" {
+			if c.When.Before(min) {
+				c.When = min
+			} else {
+				c.When = now
+			}
+			commits = append(commits, c)
+		}
+	}
+	if len(rep.Commits) == 0 {
+		return nil
+	}
+	ch.Commits = commits
+	return rep
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+func TestSanitizeDates(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)
+	epoch := time.Unix(0, 0).UTC()
+	old := time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+	future := now.AddDate(1, 0, 0)
+	commits := []history.Commit{{Hash: "f", When: future}, {Hash: "n", When: now}, {Hash: "o", When: old}, {Hash: "e", When: epoch}}
+	tests := []struct {
+		name  string
+		dates *datesConfig
+		want  []string // Hashes kept.
+		when  []time.Time
+		bad   int // Commits reported.
+	}{
+		{name: "default", want: []string{"n", "o"}, when: []time.Time{now, old}, bad: 2},
+		{name: "clamp", dates: &datesConfig{Action: "clamp"}, want: []string{"f", "n", "o", "e"}, when: []time.Time{now, now, old, defaultMinDate}, bad: 2},
+		{name: "keep", dates: &datesConfig{Action: "keep"}, want: []string{"f", "n", "o", "e"}, when: []time.Time{future, now, old, epoch}},
+		{name: "min", dates: &datesConfig{Min: "1995-01-01"}, want: []string{"n"}, when: []time.Time{now}, bad: 3},
+		{name: "slack", dates: &datesConfig{Min: "1970-01-01"}, want: []string{"n", "o", "e"}, when: []time.Time{now, old, epoch}, bad: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{Dates: tt.dates}
+			ch := &chart{Commits: append([]history.Commit(nil), commits...)}
+			rep := cfg.sanitizeDates(ch, now)
+			var hashes []string
+			var when []time.Time
+			for _, c := range ch.Commits {
+				hashes = append(hashes, c.Hash)
+				when = append(when, c.When)
+			}
+			if !reflect.DeepEqual(hashes, tt.want) || !reflect.DeepEqual(when, tt.when) {
+				t.Errorf("kept %v at %v, want %v at %v", hashes, when, tt.want, tt.when)
+			}
+			bad := 0
+			if rep != nil {
+				bad = len(rep.Commits)
+			}
+			if bad != tt.bad {
+				t.Errorf("reported %d commits, want %d", bad, tt.bad)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var deltaCharts = flag.Bool("delta", false, "also render a bar chart of the change in each metric from one window to the next")
+
+// deltaFilename returns the file name of the delta chart of a metric.
+func deltaFilename(slug, metric string) string {
+	return strings.TrimSuffix(chartFilename(slug, metric), ".png") + "--delta.png"
+}
+
+// deltas returns the first difference of data. Windows without commits
+// count as zero, so a quiet week shows as a drop.
+func deltas(data plotter.XYs, w history.Window) plotter.XYs {
+	filled := fillWindows(data, w)
+	if len(filled) < 2 {
+		return nil
+	}
+	d := make(plotter.XYs, len(filled)-1)
+	for i := 1; i < len(filled); i++ {
+		d[i-1] = plotter.XY{X: filled[i].X, Y: filled[i].Y - filled[i-1].Y}
+	}
+	return d
+}
+
+// bars draws a bar from zero for each point on a time axis, in Up above
+// zero and Down below.
+type bars struct {
+	plotter.XYs
+	// Width is the bar width in X units.
+	Width    float64
+	Up, Down color.Color
+}
+
+var (
+	deltaUp   = color.RGBA{R: 0x2d, G: 0xa4, B: 0x4e, A: 0xff}
+	deltaDown = color.RGBA{R: 0xcf, G: 0x22, B: 0x2e, A: 0xff}
+)
+
+func (b *bars) Plot(c draw.Canvas, p *plot.Plot) {
+	trX, trY := p.Transforms(&c)
+	for _, pt := range b.XYs {
+		if pt.Y == 0 {
+			continue
+		}
+		clr := b.Up
+		if pt.Y < 0 {
+			clr = b.Down
+		}
+		x0, x1 := trX(pt.X-b.Width*0.4), trX(pt.X+b.Width*0.4)
+		y0, y1 := trY(0), trY(pt.Y)
+		c.FillPolygon(clr, []vg.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}})
+	}
+}
+
+// Thumbnail draws the legend entry of the bars.
+func (b *bars) Thumbnail(c *draw.Canvas) {
+	pts := []vg.Point{{X: c.Min.X, Y: c.Min.Y}, {X: c.Max.X, Y: c.Min.Y}, {X: c.Max.X, Y: c.Max.Y}, {X: c.Min.X, Y: c.Max.Y}}
+	c.FillPolygon(b.Up, pts)
+}
+
+func (b *bars) DataRange() (xmin, xmax, ymin, ymax float64) {
+	xmin, xmax, ymin, ymax = plotter.XYRange(b.XYs)
+	if ymin > 0 {
+		ymin = 0
+	}
+	if ymax < 0 {
+		ymax = 0
+	}
+	return xmin - b.Width/2, xmax + b.Width/2, ymin, ymax
+}
+
+// displayDelta draws the change of the metric from each window to the
+// next as bars around zero.
+func displayDelta(ch *chart, metric string, opt chartOptions, filename string) error {
+	now := renderTime()
+	wname, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	data, err := seriesPoints(ch, metric, w, opt.cal, now)
+	if err != nil {
+		return err
+	}
+	d := deltas(data, w)
+
+	p := plot.New()
+	addProvenance(p, ch)
+	p.Title.Text, err = chartTitle(ch, metric, opt, ch.Name+" (change from previous "+wname+" window)")
+	if err != nil {
+		return err
+	}
+	p.Y.Label.Text = "change in " + metric
+	p.X.Tick.Marker = plot.TimeTicks{Format: opt.loc.DateFormat}
+	p.Add(plotter.NewGrid())
+	if len(d) > 0 {
+		width := (7 * 24 * time.Hour).Seconds()
+		if len(d) > 1 {
+			width = (d[len(d)-1].X - d[0].X) / float64(len(d)-1)
+		}
+		p.Add(&bars{XYs: d, Width: width, Up: deltaUp, Down: deltaDown})
+	}
+	return savePlot(p, filename)
+}
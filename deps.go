@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const depsFilename = "deps.json"
+
+func depsPath() string { return filepath.Join(cacheDir, depsFilename) }
+
+// dependency is a Go module or npm package.
+type dependency struct {
+	Kind string // "go" or "npm".
+	Name string
+}
+
+func (d dependency) key() string { return d.Kind + ":" + d.Name }
+
+// readDependencies returns the dependencies listed in a go.mod or
+// package.json file. Indirect Go requirements and npm dev dependencies
+// are left out.
+func readDependencies(filename string) ([]dependency, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch filepath.Base(filename) {
+	case "go.mod":
+		return goModDependencies(f)
+	case "package.json":
+		var pkg struct {
+			Dependencies map[string]string `json:"dependencies"`
+		}
+		err = json.NewDecoder(f).Decode(&pkg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		var list []dependency
+		for name := range pkg.Dependencies {
+			list = append(list, dependency{Kind: "npm", Name: name})
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("%s: dependencies are read from go.mod or package.json files", filename)
+}
+
+func goModDependencies(r io.Reader) ([]dependency, error) {
+	var list []dependency
+	block := false
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasSuffix(line, "// indirect") {
+			continue
+		}
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "require (":
+			block = true
+			continue
+		case block && line == ")":
+			block = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !block:
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			list = append(list, dependency{Kind: "go", Name: strings.Trim(fields[0], `"`)})
+		}
+	}
+	return list, s.Err()
+}
+
+// goRepoURL maps a module path on a well known host to its repository URL
+// without network access.
+func goRepoURL(path string) (string, bool) {
+	parts := strings.Split(path, "/")
+	switch parts[0] {
+	case "github.com", "gitlab.com", "bitbucket.org":
+		if len(parts) < 3 {
+			return "", false
+		}
+		return "https://" + strings.Join(parts[:3], "/"), true
+	case "golang.org":
+		if len(parts) < 3 || parts[1] != "x" {
+			return "", false
+		}
+		return "https://go.googlesource.com/" + parts[2], true
+	case "gopkg.in":
+		// gopkg.in/pkg.v1 is github.com/go-pkg/pkg and gopkg.in/user/pkg.v1
+		// is github.com/user/pkg.
+		name := parts[len(parts)-1]
+		if i := strings.Index(name, ".v"); i > 0 {
+			name = name[:i]
+		}
+		if len(parts) == 2 {
+			return "https://github.com/go-" + name + "/" + name, true
+		}
+		return "https://github.com/" + parts[1] + "/" + name, true
+	}
+	return "", false
+}
+
+var goImport = regexp.MustCompile(`<meta\s+name="go-import"\s+content="([^"]+)"`)
+
+// resolver finds the repository URL of dependencies. Results are cached
+// in depsPath, so the network is only used for new dependencies.
+type resolver struct {
+	client  *http.Client
+	known   map[string]string
+	changed bool
+}
+
+func newResolver() (*resolver, error) {
+	r := &resolver{
+		client: &http.Client{Timeout: 30 * time.Second},
+		known:  map[string]string{},
+	}
+	b, err := os.ReadFile(depsPath())
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &r.known)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", depsPath(), err)
+	}
+	return r, nil
+}
+
+func (r *resolver) resolve(d dependency) (string, error) {
+	if u, ok := r.known[d.key()]; ok {
+		return u, nil
+	}
+	if d.Kind == "go" {
+		if u, ok := goRepoURL(d.Name); ok {
+			return u, nil
+		}
+	}
+	if *offline {
+		return "", fmt.Errorf("dependency %s is not resolved yet; run without -offline", d.Name)
+	}
+	var u string
+	var err error
+	switch d.Kind {
+	case "go":
+		u, err = r.goGet(d.Name)
+	case "npm":
+		u, err = r.npm(d.Name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("dependency %s: %w", d.Name, err)
+	}
+	r.known[d.key()] = u
+	r.changed = true
+	return u, nil
+}
+
+func (r *resolver) get(u string) ([]byte, error) {
+	resp, err := r.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+}
+
+// goGet resolves a vanity import path with the go-import meta tag, as the
+// go command does.
+func (r *resolver) goGet(path string) (string, error) {
+	b, err := r.get("https://" + path + "?go-get=1")
+	if err != nil {
+		return "", err
+	}
+	for _, m := range goImport.FindAllStringSubmatch(string(b), -1) {
+		f := strings.Fields(m[1])
+		if len(f) != 3 || f[1] != "git" {
+			continue
+		}
+		if f[0] == path || strings.HasPrefix(path, f[0]+"/") {
+			return strings.TrimSuffix(f[2], ".git"), nil
+		}
+	}
+	return "", fmt.Errorf("no git go-import meta tag")
+}
+
+// npm resolves a package by the repository field of its registry entry.
+func (r *resolver) npm(name string) (string, error) {
+	b, err := r.get("https://registry.npmjs.org/" + strings.Replace(name, "/", "%2F", 1))
+	if err != nil {
+		return "", err
+	}
+	var pkg struct {
+		Repository json.RawMessage `json:"repository"`
+	}
+	err = json.Unmarshal(b, &pkg)
+	if err != nil {
+		return "", err
+	}
+	var repo struct {
+		URL string `json:"url"`
+	}
+	if json.Unmarshal(pkg.Repository, &repo) != nil {
+		// The field may also be a plain string.
+		json.Unmarshal(pkg.Repository, &repo.URL)
+	}
+	u := npmRepoURL(repo.URL)
+	if len(u) == 0 {
+		return "", fmt.Errorf("no repository in the registry")
+	}
+	return u, nil
+}
+
+// npmRepoURL normalizes the repository field of package.json, such as
+// "git+https://github.com/user/repo.git" or "github:user/repo", to an
+// https URL.
+func npmRepoURL(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "git+"), ".git")
+	switch {
+	case len(s) == 0:
+		return ""
+	case strings.HasPrefix(s, "github:"):
+		return "https://github.com/" + strings.TrimPrefix(s, "github:")
+	case strings.HasPrefix(s, "gitlab:"):
+		return "https://gitlab.com/" + strings.TrimPrefix(s, "gitlab:")
+	case !strings.Contains(s, ":") && strings.Count(s, "/") == 1:
+		return "https://github.com/" + s
+	}
+	pu, err := url.Parse(s)
+	if err != nil || len(pu.Host) == 0 {
+		return ""
+	}
+	// git:// and ssh:// URLs of public hosts are also served over https.
+	pu.Scheme = "https"
+	pu.User = nil
+	return pu.String()
+}
+
+// write saves new resolutions.
+func (r *resolver) write() error {
+	if !r.changed {
+		return nil
+	}
+	return writeFileAtomic(depsPath(), func(w io.Writer) error {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		return e.Encode(r.known)
+	})
+}
+
+// addDependencies adds the repositories of the dependencies listed in
+// files, relative to the config directory dir, to the config. Dependencies
+// that cannot be resolved are reported and skipped.
+func (cfg *config) addDependencies(dir string, files []string) error {
+	r, err := newResolver()
+	if err != nil {
+		return err
+	}
+	for _, fn := range files {
+		if !filepath.IsAbs(fn) {
+			fn = filepath.Join(dir, fn)
+		}
+		list, err := readDependencies(fn)
+		if err != nil {
+			return err
+		}
+		for _, d := range list {
+			u, err := r.resolve(d)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if _, ok := cfg.Repos[u]; ok {
+				continue
+			}
+			cfg.Repos[u] = &repoConfig{Name: d.Name}
+		}
+	}
+	return r.write()
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// emailConfig configures the weekly digest sent by the serve command and
+// the email command.
+type emailConfig struct {
+	// Addr is the SMTP server as host:port.
+	Addr string
+	From string
+	To   []string
+	// Username and Password authenticate with the server if set. The
+	// password may also be given in SMTP_PASSWORD.
+	Username string `json:",omitempty"`
+	Password string `json:",omitempty"`
+	// Every is the time between digests, seven days if zero.
+	Every duration `json:",omitempty"`
+}
+
+const digestStateFilename = "digest.json"
+
+type digest struct {
+	Title string
+	End   time.Time
+	Repos []*digestRepo
+}
+
+type digestRepo struct {
+	URL      string
+	Name     string
+	ThisWeek int
+	LastWeek int
+	Delta    int
+	Total    int
+	// Authors are the top authors of this week and last week by commits
+	// this week.
+	Authors []digestAuthor
+	// CID is the content ID of the inline chart, if there is one.
+	CID string
+
+	chart string
+}
+
+type digestAuthor struct {
+	Name     string
+	ThisWeek int
+	LastWeek int
+	Delta    int
+}
+
+// digestAuthors is the length of the author leaderboard of a repository.
+const digestAuthors = 5
+
+// newDigest counts the commits of the week before end and the week before
+// that for each repository and each of its authors.
+func newDigest(title string, charts FileType, slugs map[string]string, end time.Time) *digest {
+	d := &digest{Title: title, End: end}
+	week := end.AddDate(0, 0, -7)
+	prev := week.AddDate(0, 0, -7)
+	for _, u := range charts.urls() {
+		ch := charts[u]
+		if len(ch.Commits) == 0 {
+			continue
+		}
+		dr := &digestRepo{URL: u, Name: ch.Name, Total: len(ch.Commits)}
+		authors := map[string]*digestAuthor{}
+		count := func(c *history.Commit, last bool) {
+			for _, p := range c.Authors() {
+				a := authors[p.Key()]
+				if a == nil {
+					a = &digestAuthor{Name: p.Name}
+					authors[p.Key()] = a
+				}
+				if last {
+					a.LastWeek++
+				} else {
+					a.ThisWeek++
+				}
+			}
+		}
+		for i := range ch.Commits {
+			c := &ch.Commits[i]
+			switch {
+			case !c.When.Before(end):
+			case !c.When.Before(week):
+				dr.ThisWeek++
+				count(c, false)
+			case !c.When.Before(prev):
+				dr.LastWeek++
+				count(c, true)
+			}
+		}
+		dr.Delta = dr.ThisWeek - dr.LastWeek
+		for _, a := range authors {
+			a.Delta = a.ThisWeek - a.LastWeek
+			dr.Authors = append(dr.Authors, *a)
+		}
+		sort.Slice(dr.Authors, func(i, j int) bool {
+			a, b := dr.Authors[i], dr.Authors[j]
+			if a.ThisWeek != b.ThisWeek {
+				return a.ThisWeek > b.ThisWeek
+			}
+			if a.LastWeek != b.LastWeek {
+				return a.LastWeek > b.LastWeek
+			}
+			return a.Name < b.Name
+		})
+		if len(dr.Authors) > digestAuthors {
+			dr.Authors = dr.Authors[:digestAuthors]
+		}
+		fn := filepath.Join(outputDir, chartFilename(slugs[u], "commits"))
+		if _, err := os.Stat(fn); err == nil {
+			dr.CID = slugs[u] + "@gitgraph"
+			dr.chart = fn
+		}
+		d.Repos = append(d.Repos, dr)
+	}
+	return d
+}
+
+// message returns the digest as a MIME message with inline charts.
+func (d *digest) message(e *emailConfig) ([]byte, error) {
+	tmpl, err := loadTemplate(*templates, "digest.html")
+	if err != nil {
+		return nil, err
+	}
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", d.Title+" "+d.End.Format("2006-01-02")))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/related; boundary=%q\r\n\r\n", mw.Boundary())
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qw := quotedprintable.NewWriter(pw)
+	err = tmpl.Execute(qw, d)
+	if err != nil {
+		return nil, err
+	}
+	err = qw.Close()
+	if err != nil {
+		return nil, err
+	}
+	for _, dr := range d.Repos {
+		if len(dr.chart) == 0 {
+			continue
+		}
+		img, err := os.ReadFile(dr.chart)
+		if err != nil {
+			return nil, err
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"image/png"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<" + dr.CID + ">"},
+			"Content-Disposition":       {fmt.Sprintf("inline; filename=%q", filepath.Base(dr.chart))},
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = writeBase64Lines(pw, img)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// writeBase64Lines writes b as base64 in lines of 76 characters.
+func writeBase64Lines(w io.Writer, b []byte) error {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 0 {
+		n := 76
+		if n > len(s) {
+			n = len(s)
+		}
+		_, err := io.WriteString(w, s[:n]+"\r\n")
+		if err != nil {
+			return err
+		}
+		s = s[n:]
+	}
+	return nil
+}
+
+func (e *emailConfig) send(msg []byte) error {
+	var auth smtp.Auth
+	if len(e.Username) > 0 {
+		password := e.Password
+		if len(password) == 0 {
+			password = credential("SMTP_PASSWORD")
+		}
+		host := e.Addr
+		if i := strings.LastIndexByte(host, ':'); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", e.Username, password, host)
+	}
+	return smtp.SendMail(e.Addr, auth, e.From, e.To, msg)
+}
+
+func (e *emailConfig) every() time.Duration {
+	if e.Every > 0 {
+		return time.Duration(e.Every)
+	}
+	return 7 * 24 * time.Hour
+}
+
+type digestState struct {
+	Sent time.Time
+}
+
+// sendDigest emails the digest. Unless force is set it is only sent if the
+// last digest is older than the configured interval.
+func sendDigest(cfg *config, force bool) error {
+	e := cfg.Email
+	if e == nil || len(e.Addr) == 0 || len(e.To) == 0 {
+		return fmt.Errorf("no Email settings in the config")
+	}
+	stateFile := filepath.Join(cacheDir, digestStateFilename)
+	var state digestState
+	if b, err := os.ReadFile(stateFile); err == nil {
+		json.Unmarshal(b, &state)
+	}
+	now := time.Now()
+	if !force && now.Sub(state.Sent) < e.every() {
+		return nil
+	}
+	charts, err := readCache(cfg)
+	if err != nil {
+		return err
+	}
+	msg, err := newDigest(cfg.Title, charts, charts.slugs(), now).message(e)
+	if err != nil {
+		return err
+	}
+	err = e.send(msg)
+	if err != nil {
+		return err
+	}
+	state.Sent = now
+	return writeFileAtomic(stateFile, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(state)
+	})
+}
+
+// email sends the digest now.
+func email(ctx context.Context) error {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	return sendDigest(cfg, true)
+}
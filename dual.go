@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var dualPairs = flag.String("dual", "", "comma separated metric pairs, such as commits:authors, each rendered as one chart with the second metric on a right Y axis")
+
+// dualPair is two metrics charted on the left and right Y axis.
+type dualPair struct {
+	left, right string
+}
+
+// parseDualPairs parses -dual.
+func parseDualPairs(s string) ([]dualPair, error) {
+	var pairs []dualPair
+	for _, v := range splitList(s) {
+		i := strings.IndexByte(v, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("-dual %q: want left:right", v)
+		}
+		dp := dualPair{left: v[:i], right: v[i+1:]}
+		for _, m := range []string{dp.left, dp.right} {
+			if history.New(m) == nil {
+				return nil, fmt.Errorf("-dual: unknown metric %q, have %s", m, strings.Join(history.Names(), ", "))
+			}
+		}
+		pairs = append(pairs, dp)
+	}
+	return pairs, nil
+}
+
+func (dp dualPair) String() string { return dp.left + " and " + dp.right }
+
+func dualFilename(slug string, dp dualPair) string {
+	return slug + "--" + slugify(dp.left) + "--" + slugify(dp.right) + ".png"
+}
+
+// dualRightColor draws the right metric, dashed, whatever the palette.
+var dualRightColor = color.RGBA{R: 0x60, G: 0x60, B: 0x60, A: 0xff}
+
+// displayDual charts two metrics of ch with different scales. The right
+// metric is scaled to the left axis for drawing, and a right axis shows
+// its own values.
+func displayDual(ch *chart, dp dualPair, opt chartOptions, filename string) error {
+	_, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	now := renderTime()
+	left, err := seriesPoints(ch, dp.left, w, opt.cal, now)
+	if err != nil {
+		return err
+	}
+	right, err := seriesPoints(ch, dp.right, w, opt.cal, now)
+	if err != nil {
+		return err
+	}
+	maxLeft, maxRight := maxValue(left), maxValue(right)
+	scale := 1.0
+	if maxLeft > 0 && maxRight > 0 {
+		scale = maxLeft / maxRight
+	}
+	scaled := make(plotter.XYs, len(right))
+	for i, pt := range right {
+		scaled[i] = plotter.XY{X: pt.X, Y: pt.Y * scale}
+	}
+
+	p := plot.New()
+	addProvenance(p, ch)
+	p.Title.Text, err = chartTitle(ch, dp.String(), opt, ch.Name+" ("+dp.String()+")")
+	if err != nil {
+		return err
+	}
+	p.X.Tick.Marker = plot.TimeTicks{Format: opt.loc.DateFormat}
+	p.Y.Label.Text = dp.left
+	p.Add(plotter.NewGrid())
+	p.Legend.Top = true
+	p.Legend.Left = true
+	if len(left) > 0 {
+		l, err := plotter.NewLine(left)
+		if err != nil {
+			return err
+		}
+		l.Color = color.RGBA{G: 0x80, B: 0xc0, A: 0xff}
+		if opt.style.Color != nil {
+			l.Color = opt.style.Color
+		}
+		p.Add(l)
+		p.Legend.Add(dp.left+" (left)", l)
+	}
+	if len(scaled) > 0 {
+		l, err := plotter.NewLine(scaled)
+		if err != nil {
+			return err
+		}
+		l.Color = dualRightColor
+		l.Dashes = dashes(6, 3)
+		p.Add(l)
+		p.Legend.Add(dp.right+" (right)", l)
+	}
+	setYRange(p, maxLeft, 0)
+
+	c, err := draw.NewFormattedCanvas(40*vg.Centimeter, 20*vg.Centimeter, "png")
+	if err != nil {
+		return err
+	}
+	inner := draw.Crop(draw.New(c), 0, -2*vg.Centimeter, 0, 0)
+	p.Draw(inner)
+	da := p.DataCanvas(inner)
+	y := func(v float64) vg.Length {
+		return da.Y((v*scale - p.Y.Min) / (p.Y.Max - p.Y.Min))
+	}
+	x := da.Max.X
+	da.StrokeLine2(p.Y.LineStyle, x, da.Min.Y, x, da.Max.Y)
+	label := p.Y.Tick.Label
+	label.XAlign, label.YAlign = draw.XLeft, draw.YCenter
+	for _, t := range (plot.DefaultTicks{}).Ticks(p.Y.Min/scale, p.Y.Max/scale) {
+		if t.IsMinor() {
+			continue
+		}
+		ty := y(t.Value)
+		da.StrokeLine2(p.Y.LineStyle, x, ty, x+vg.Points(4), ty)
+		da.FillText(label, vg.Point{X: x + vg.Points(6), Y: ty}, t.Label)
+	}
+	axis := p.Y.Label.TextStyle
+	axis.Rotation = math.Pi / 2
+	axis.XAlign, axis.YAlign = draw.XCenter, draw.YBottom
+	da.FillText(axis, vg.Point{X: inner.Max.X + 2*vg.Centimeter - vg.Points(4), Y: (da.Min.Y + da.Max.Y) / 2}, dp.right)
+	return writeCanvas(c, filename)
+}
+
+func maxValue(data plotter.XYs) float64 {
+	var max float64
+	for _, pt := range data {
+		max = math.Max(max, pt.Y)
+	}
+	return max
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// hub notifies the event streams of browsers when a run has written new
+// output. Runs of the daemon and of other processes are both noticed by
+// watching the manifest, which is written last.
+type hub struct {
+	subscribe   chan chan []byte
+	unsubscribe chan chan []byte
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribe:   make(chan chan []byte),
+		unsubscribe: make(chan chan []byte),
+	}
+}
+
+// watch polls the manifest and sends the repository list to every
+// subscriber when it changes.
+func (s *server) watch(ctx context.Context, h *hub) {
+	manifest := filepath.Join(outputDir, manifestFilename)
+	modTime := func() time.Time {
+		fi, err := os.Stat(manifest)
+		if err != nil {
+			return time.Time{}
+		}
+		return fi.ModTime()
+	}
+	last := modTime()
+	subs := map[chan []byte]bool{}
+	tick := time.NewTicker(2 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case c := <-h.subscribe:
+			subs[c] = true
+		case c := <-h.unsubscribe:
+			delete(subs, c)
+		case <-tick.C:
+			mt := modTime()
+			if mt.Equal(last) {
+				continue
+			}
+			last = mt
+			list, err := s.apiRepos()
+			if err != nil {
+				continue
+			}
+			b, err := json.Marshal(list)
+			if err != nil {
+				continue
+			}
+			for c := range subs {
+				select {
+				case c <- b:
+				default:
+					// The subscriber has an update pending already.
+				}
+			}
+		}
+	}
+}
+
+// events streams server-sent "update" events with the repository list of
+// /api/repos after each run.
+func (s *server) events(h *hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fl, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		c := make(chan []byte, 1)
+		select {
+		case h.subscribe <- c:
+		case <-r.Context().Done():
+			return
+		}
+		defer func() {
+			select {
+			case h.unsubscribe <- c:
+			case <-r.Context().Done():
+			}
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprint(w, "retry: 5000\n\n")
+		fl.Flush()
+		ping := time.NewTicker(30 * time.Second)
+		defer ping.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case b := <-c:
+				fmt.Fprintf(w, "event: update\ndata: %s\n\n", b)
+			case <-ping.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			fl.Flush()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+type exporter struct {
+	// File is the default output file, relative to the output directory.
+	File  string
+	Write func(ctx context.Context, d *exportData, out string) error
+}
+
+// exportData is the input of an exporter.
+type exportData struct {
+	Charts  FileType
+	Slugs   map[string]string
+	Metrics []string
+}
+
+var exporters = map[string]exporter{
+	"gnuplot": {File: "gnuplot", Write: exportGnuplot},
+	"influx":  {File: "metrics.lp", Write: exportInflux},
+	"mermaid": {File: "activity.md", Write: exportMermaid},
+	"parquet": {File: "commits.parquet", Write: exportParquet},
+	"xlsx":    {File: "commits.xlsx", Write: exportXLSX},
+}
+
+// export writes cached data in another format:
+//
+//	gitgraph export FORMAT [FILE]
+func export(ctx context.Context) error {
+	args := flag.Args()[1:]
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: export FORMAT [FILE], formats: %s", strings.Join(exportFormats(), ", "))
+	}
+	ex, ok := exporters[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown export format %q, have %s", args[0], strings.Join(exportFormats(), ", "))
+	}
+	out := filepath.Join(outputDir, ex.File)
+	if len(args) == 2 {
+		out = args[1]
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	err = cfg.selectRepos(nil)
+	if err != nil {
+		return err
+	}
+	names, err := cfg.metrics(splitList(*metrics))
+	if err != nil {
+		return err
+	}
+	charts, err := readCache(cfg)
+	if err != nil {
+		return err
+	}
+	d := &exportData{
+		Charts:  charts,
+		Slugs:   charts.slugs(),
+		Metrics: names,
+	}
+	err = ex.Write(ctx, d, out)
+	if err != nil {
+		return err
+	}
+	fmt.Println("wrote", out)
+	return nil
+}
+
+func exportFormats() []string {
+	list := make([]string, 0, len(exporters))
+	for name := range exporters {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// exportParquet writes one row per commit.
+func exportParquet(ctx context.Context, d *exportData, out string) error {
+	charts := d.Charts
+	repo := &parquetColumn{Name: "repo", Type: parquetByteArray, Converted: parquetUTF8}
+	hash := &parquetColumn{Name: "hash", Type: parquetByteArray, Converted: parquetUTF8}
+	author := &parquetColumn{Name: "author", Type: parquetByteArray, Converted: parquetUTF8}
+	email := &parquetColumn{Name: "email", Type: parquetByteArray, Converted: parquetUTF8}
+	ts := &parquetColumn{Name: "timestamp", Type: parquetInt64, Converted: parquetTimestampMillis}
+	ins := &parquetColumn{Name: "insertions", Type: parquetInt64, Converted: parquetNoConverted}
+	del := &parquetColumn{Name: "deletions", Type: parquetInt64, Converted: parquetNoConverted}
+
+	for _, u := range charts.urls() {
+		for _, c := range history.ExcludeFiles(charts[u].Commits, charts[u].excluded()) {
+			var add, rm int64
+			for _, f := range c.Files {
+				add += int64(f.Add)
+				rm += int64(f.Del)
+			}
+			repo.strings = append(repo.strings, u)
+			hash.strings = append(hash.strings, c.Hash)
+			author.strings = append(author.strings, c.Author)
+			email.strings = append(email.strings, c.Email)
+			ts.ints = append(ts.ints, c.When.UnixNano()/int64(time.Millisecond))
+			ins.ints = append(ins.ints, add)
+			del.ints = append(del.ints, rm)
+		}
+	}
+	return writeFileAtomic(out, func(w io.Writer) error {
+		return writeParquet(w, []*parquetColumn{repo, hash, author, email, ts, ins, del})
+	})
+}
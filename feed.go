@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// feedWeeks is the number of past weeks published in a feed.
+const feedWeeks = 12
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// feed handles /feed.atom for all repositories and /feed/{slug}.atom for
+// one. Each entry summarizes one completed week of one repository.
+func (s *server) feed(w http.ResponseWriter, r *http.Request) {
+	d, err := s.data()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	f := &atomFeed{
+		ID:    "urn:gitgraph:feed",
+		Title: s.cfg.Title,
+		Link:  atomLink{Href: r.URL.Path, Rel: "self"},
+	}
+	urls := d.charts.urls()
+	if r.URL.Path != "/feed.atom" {
+		slug := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/feed/"), ".atom")
+		u, ok := d.bySlug[slug]
+		if !ok || !strings.HasSuffix(r.URL.Path, ".atom") {
+			http.NotFound(w, r)
+			return
+		}
+		urls = []string{u}
+		f.ID = "urn:gitgraph:feed:" + slug
+		f.Title = d.charts[u].Name
+	}
+	end := history.Weekly.Start(time.Now())
+	for _, u := range urls {
+		f.Entries = append(f.Entries, weekEntries(u, d.slugs[u], d.charts[u], end)...)
+	}
+	sort.SliceStable(f.Entries, func(i, j int) bool {
+		return f.Entries[i].Updated > f.Entries[j].Updated
+	})
+	f.Updated = end.UTC().Format(time.RFC3339)
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	e := xml.NewEncoder(w)
+	e.Indent("", "\t")
+	e.Encode(f)
+}
+
+type feedWeek struct {
+	commits int
+	authors map[string]int
+}
+
+// weekEntries returns entries for the weeks with commits among the
+// feedWeeks before end. A week is noted as a spike if it has at least
+// twice the average of the weeks before it.
+func weekEntries(u, slug string, ch *chart, end time.Time) []atomEntry {
+	const week = 7 * 24 * time.Hour
+	// Twice as many weeks are counted so the first published ones have an average.
+	start := end.Add(-2 * feedWeeks * week)
+	weeks := make([]feedWeek, 2*feedWeeks)
+	for _, c := range ch.Commits {
+		if c.When.Before(start) || !c.When.Before(end) {
+			continue
+		}
+		fw := &weeks[int(c.When.Sub(start)/week)]
+		fw.commits++
+		if fw.authors == nil {
+			fw.authors = map[string]int{}
+		}
+		for _, p := range c.Authors() {
+			fw.authors[p.Name]++
+		}
+	}
+	var list []atomEntry
+	for i := feedWeeks; i < len(weeks); i++ {
+		fw := weeks[i]
+		if fw.commits == 0 {
+			continue
+		}
+		var prior int
+		for _, p := range weeks[i-feedWeeks : i] {
+			prior += p.commits
+		}
+		avg := float64(prior) / feedWeeks
+		from := start.Add(time.Duration(i) * week)
+		to := from.Add(week)
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "<p>%d commits in the week of %s.</p>", fw.commits, from.Format("2006-01-02"))
+		if avg > 0 && float64(fw.commits) >= 2*avg {
+			fmt.Fprintf(&b, "<p>Spike: %.1f times the %d week average of %.1f.</p>", float64(fw.commits)/avg, feedWeeks, avg)
+		}
+		b.WriteString("<p>Top authors:</p><ul>")
+		for _, a := range topAuthors(fw.authors, 3) {
+			fmt.Fprintf(&b, "<li>%s (%d)</li>", xmlEscape(a), fw.authors[a])
+		}
+		b.WriteString("</ul>")
+
+		list = append(list, atomEntry{
+			ID:      "urn:gitgraph:" + slug + ":" + from.Format("2006-01-02"),
+			Title:   fmt.Sprintf("%s: %d commits, week of %s", ch.Name, fw.commits, from.Format("2006-01-02")),
+			Updated: to.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: u},
+			Author:  atomAuthor{Name: "gitgraph"},
+			Content: atomContent{Type: "html", Text: b.String()},
+		})
+	}
+	return list
+}
+
+// topAuthors returns up to n authors with the most commits.
+func topAuthors(counts map[string]int, n int) []string {
+	list := make([]string, 0, len(counts))
+	for a := range counts {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if counts[list[i]] != counts[list[j]] {
+			return counts[list[i]] > counts[list[j]]
+		}
+		return list[i] < list[j]
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/kardianos/gitgraph/history"
+)
+
+var batch = flag.Int("batch", 0, "fetch at most this many repositories per run, least recently fetched first; zero fetches all that are due")
+
+// progress receives the name of each repository as it is fetched.
+var progress io.Writer = os.Stdout
+
+// indexFlush is the number of fetched repositories after which the cache
+// index is written. An interrupted run fetches at most this many again.
+const indexFlush = 25
+
+// fetch clones the repositories that are not cached, expired, or selected
+// by -refresh; in a run started by push webhooks, those pushed instead of
+// the expired ones. Each repository is saved to the cache as it is
+// fetched, so an interrupted run resumes where it stopped. The caller must
+// hold the cache lock.
+func fetch(ctx context.Context, cfg *config, ix cacheIndex, sum *summary) error {
+	force := splitList(*refresh)
+	now := time.Now()
+	due := cfg.dueRepos(ctx, ix, sum, now)
+	quota := newCacheQuota(cfg.quota().MaxCacheSize)
+	limitRate(cfg.quota().RateLimit)
+
+	up := newUpstream()
+	pending := 0
+	for _, u := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		ch := cfg.chart(u)
+		rs := sum.repo(u, ch.Name)
+		before := rs.Commits
+		start := time.Now()
+
+		var state apiState
+		if *useAPI {
+			var prev apiState
+			e := ix[u]
+			if e != nil {
+				prev = e.API
+			}
+			var unchanged bool
+			var err error
+			state, unchanged, err = up.check(ctx, u, prev)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "check %s: %v\n", u, err)
+			}
+			if unchanged && e != nil && !force.match(u, ch.Name) {
+				e.Fetched = now
+				e.API = state
+				pending++
+				continue
+			}
+		}
+
+		err := quota.check()
+		if err != nil {
+			rs.skip(err)
+			continue
+		}
+		var st fetchStats
+		err = fetchOne(withFetchStats(ctx, &st), cfg, u, ch, now)
+		rs.FetchSeconds = time.Since(start).Seconds()
+		rs.addFetchStats(st)
+		quota.add(st)
+		var qe *quotaError
+		if errors.As(err, &qe) {
+			rs.skip(err)
+			continue
+		}
+		rs.Fetched = true
+		if err != nil {
+			rs.fail(err)
+			fmt.Fprintf(os.Stderr, "fetch %s: %v\n", u, err)
+			continue
+		}
+		err = saveShard(u, ch)
+		if err != nil {
+			return err
+		}
+		ix.set(u, ch)
+		ix[u].API = state
+		rs.Commits = len(ch.Commits)
+		if rs.Commits > before {
+			rs.NewCommits = rs.Commits - before
+		}
+		pending++
+		if pending >= indexFlush {
+			err = ix.write()
+			if err != nil {
+				return err
+			}
+			pending = 0
+		}
+	}
+	if pending > 0 {
+		return ix.write()
+	}
+	return nil
+}
+
+// dueRepos returns the repositories to fetch, least recently fetched
+// first, at most -batch of them. It records the cached commit counts in
+// sum.
+func (cfg *config) dueRepos(ctx context.Context, ix cacheIndex, sum *summary, now time.Time) []string {
+	force := splitList(*refresh)
+	pushed := pushedRepos(ctx)
+	var due []string
+	for _, u := range cfg.urls() {
+		name := cfg.Repos[u].Name
+		rs := sum.repo(u, name)
+		if e := ix[u]; e != nil && e.Commits > 0 {
+			rs.Commits = e.Commits
+			switch {
+			case pushed != nil:
+				if !pushed[u] {
+					continue
+				}
+			case !force.match(u, name):
+				maxAge := cfg.ttl(u)
+				if maxAge <= 0 || now.Sub(e.Fetched) < maxAge {
+					continue
+				}
+			}
+		}
+		due = append(due, u)
+	}
+	fetched := func(u string) time.Time {
+		if e := ix[u]; e != nil {
+			return e.Fetched
+		}
+		return time.Time{}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return fetched(due[i]).Before(fetched(due[j]))
+	})
+	if *batch > 0 && len(due) > *batch {
+		fmt.Printf("fetching %d of %d due repositories\n", *batch, len(due))
+		due = due[:*batch]
+	}
+	return due
+}
+
+// fetchOne reads the cached commits of u into ch and fetches the new
+// ones, after the pre hook, within the timeout and size limit of u.
+func fetchOne(ctx context.Context, cfg *config, u string, ch *chart, now time.Time) error {
+	err := loadShard(u, ch)
+	if err != nil {
+		return err
+	}
+	if st := statsOf(ctx); st != nil && *useMirrors && !isBundle(u) {
+		before := dirSize(mirrorPath(u))
+		defer func() { st.Bytes = dirSize(mirrorPath(u)) - before }()
+	}
+	return cfg.withTimeout(ctx, u, func(ctx context.Context) error {
+		err := runHook(ctx, "pre", cfg.hooks(u).Pre, hookEnv{URL: u, Name: ch.Name})
+		if err != nil {
+			return err
+		}
+		return withSizeLimit(ctx, u, cfg.maxSize(u), func(ctx context.Context) error {
+			return fetchRebuilding(ctx, cfg, u, ch, now)
+		})
+	})
+}
+
+// fetchRepo updates the mirror of u, or clones it into memory with
+// -mirror=false, and replaces the commits of ch. Commits already in ch
+// are not read again. The cli backend runs the system git instead.
+func fetchRepo(ctx context.Context, cfg *config, u string, ch *chart, now time.Time) error {
+	var r *git.Repository
+	var err error
+	switch backend := cfg.backend(u); {
+	case backend == "cli":
+		return fetchCLI(ctx, cfg, u, ch, now)
+	case backend != "go-git":
+		return fmt.Errorf("unknown backend %q, use go-git or cli", backend)
+	case cfg.blobless(u):
+		return fmt.Errorf("blobless clones need the cli backend")
+	case isBundle(u):
+		r, err = openBundle(u)
+	case *useMirrors:
+		r, err = openMirror(ctx, u)
+	default:
+		fmt.Fprintln(progress, "clone", u)
+		start := time.Now()
+		r, err = git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+			URL: u,
+		})
+		noteStep(ctx, "clone", start)
+	}
+	if err != nil {
+		return err
+	}
+	defer noteStep(ctx, "walk", time.Now())
+	if branch := cfg.branch(u); len(branch) > 0 {
+		err = useBranch(r, branch)
+		if err != nil {
+			return err
+		}
+	}
+	known := make(map[string]int, len(ch.Commits))
+	for i, c := range ch.Commits {
+		if len(c.Hash) > 0 {
+			known[c.Hash] = i
+		}
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return err
+	}
+	noteRef(u, ch, ref.Name().String())
+	cIter, err := r.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return err
+	}
+
+	// Sizes recorded with another -large-file, or none, are read again.
+	resize := ch.LargeFile != *largeFileSize
+	var commits []history.Commit
+	err = cIter.ForEach(func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i, ok := known[c.Hash.String()]; ok {
+			// Details that are cheap to read are refreshed, so caches
+			// written before they were recorded fill in.
+			hc := ch.Commits[i]
+			describe(&hc, c)
+			if resize {
+				hc.Files = append([]history.File(nil), hc.Files...)
+				for j := range hc.Files {
+					hc.Files[j].Size, hc.Files[j].LFS = 0, false
+				}
+				err := largeFiles(&hc, c)
+				if err != nil {
+					return fmt.Errorf("files of %s: %w", c.Hash, err)
+				}
+			}
+			commits = append(commits, hc)
+			return nil
+		}
+		hc, err := record(c)
+		if err != nil {
+			return err
+		}
+		commits = append(commits, hc)
+		return nil
+	})
+	truncated := false
+	if errors.Is(err, plumbing.ErrObjectNotFound) && len(commits) > 0 {
+		// The upstream is shallow or grafted; keep the history there is.
+		truncated = true
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if !truncated {
+		shallow, err := r.Storer.Shallow()
+		if err != nil {
+			return err
+		}
+		truncated = len(shallow) > 0
+	}
+	ch.Generated, err = headAttributes(r)
+	if err != nil {
+		return err
+	}
+	onHead := make(map[string]bool, len(commits))
+	for _, c := range commits {
+		onHead[c.Hash] = true
+	}
+	ch.Branches, err = readBranches(r, onHead)
+	if err != nil {
+		return err
+	}
+	ch.Backports = nil
+	if patterns := cfg.backportBranches(u); len(patterns) > 0 {
+		ch.Backports, err = readBackports(r, commits, ch.Branches, patterns)
+		if err != nil {
+			return err
+		}
+	}
+	ch.Commits = commits
+	ch.LargeFile = *largeFileSize
+	noteFetch(ch, ref.Hash().String(), now)
+	noteTruncated(u, ch, truncated)
+	return nil
+}
+
+// record converts a commit to its cached form. Merge commits are recorded
+// without file statistics.
+func record(c *object.Commit) (history.Commit, error) {
+	hc := history.Commit{
+		Hash:   c.Hash.String(),
+		When:   c.Committer.When,
+		Author: c.Author.Name,
+		Email:  c.Author.Email,
+	}
+	describe(&hc, c)
+	if c.NumParents() > 1 {
+		return hc, nil
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		return hc, fmt.Errorf("stats of %s: %w", c.Hash, err)
+	}
+	for _, st := range stats {
+		hc.Files = append(hc.Files, history.File{
+			Name: st.Name,
+			Add:  st.Addition,
+			Del:  st.Deletion,
+		})
+	}
+	err = largeFiles(&hc, c)
+	if err != nil {
+		return hc, fmt.Errorf("files of %s: %w", c.Hash, err)
+	}
+	return hc, nil
+}
+
+// describe sets the details of hc read from the commit object itself.
+func describe(hc *history.Commit, c *object.Commit) {
+	hc.Signed = len(c.PGPSignature) > 0
+	hc.Message = c.Message
+}
+
+// noteRef records the branch analyzed in ch, reporting when it changed,
+// such as when upstream renamed master to main. Commits are matched by
+// hash, so those on both branches are not read again.
+func noteRef(u string, ch *chart, ref string) {
+	if len(ch.Ref) > 0 && ch.Ref != ref {
+		fmt.Fprintf(progress, "%s: default branch changed from %s to %s\n", u, ch.Ref, ref)
+	}
+	ch.Ref = ref
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var backendName = flag.String("backend", "", "how repositories are read: go-git, the default, or cli to run the system git for repositories go-git handles poorly; overrides the config Backend")
+
+var blobless = flag.Bool("blobless", false, "clone new mirrors with the cli backend as blobless partial clones, fetching file contents only when diff stats need them")
+
+// backend returns the backend of u: the -backend flag if set, else the
+// repository or global config.
+func (cfg *config) backend(u string) string {
+	switch {
+	case len(*backendName) > 0:
+		return *backendName
+	case cfg.Repos[u] != nil && len(cfg.Repos[u].Backend) > 0:
+		return cfg.Repos[u].Backend
+	case len(cfg.Backend) > 0:
+		return cfg.Backend
+	}
+	if cfg.blobless(u) {
+		return "cli"
+	}
+	return "go-git"
+}
+
+// blobless reports if u is cloned without file contents, by -blobless or
+// the repository config.
+func (cfg *config) blobless(u string) bool {
+	return *blobless || (cfg.Repos[u] != nil && cfg.Repos[u].Blobless)
+}
+
+// gitCommand runs git with the arguments, sending its messages to
+// progress. It never prompts for credentials.
+func gitCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stderr = progress
+	return cmd
+}
+
+// fetchCLI updates the mirror of u with the system git, or clones it into
+// a temporary directory with -mirror=false, and replaces the commits of
+// ch with those printed by git log. The mirror is the same bare
+// repository the go-git backend uses. A blobless clone only downloads
+// commits and trees; git log fetches the file contents it needs for the
+// diff stats on demand and keeps them.
+func fetchCLI(ctx context.Context, cfg *config, u string, ch *chart, now time.Time) error {
+	dir := mirrorPath(u)
+	if !*useMirrors {
+		tmp, err := os.MkdirTemp("", "gitgraph-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		dir = filepath.Join(tmp, "repo.git")
+	}
+	_, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		fmt.Fprintln(progress, "git clone", u)
+		err = os.MkdirAll(filepath.Dir(dir), 0777)
+		if err != nil {
+			return err
+		}
+		args := []string{"clone", "--bare", "--quiet"}
+		if cfg.blobless(u) {
+			args = append(args, "--filter=blob:none")
+		}
+		start := time.Now()
+		err = gitCommand(ctx, append(args, u, dir)...).Run()
+		noteStep(ctx, "clone", start)
+		if err != nil {
+			os.RemoveAll(dir)
+			return fmt.Errorf("git clone: %w", err)
+		}
+	case err != nil:
+		return err
+	default:
+		fmt.Fprintln(progress, "git fetch", u)
+	}
+	args := []string{"-C", dir, "fetch", "--quiet", "--prune", "--force", "--no-tags", u}
+	for _, rs := range mirrorRefSpecs {
+		args = append(args, string(rs))
+	}
+	start := time.Now()
+	err = gitCommand(ctx, args...).Run()
+	noteStep(ctx, "update", start)
+	if err != nil {
+		return fmt.Errorf("git fetch: %w", err)
+	}
+	defer noteStep(ctx, "walk", time.Now())
+	err = setHeadCLI(ctx, u, dir)
+	if err != nil {
+		return err
+	}
+	if branch := cfg.branch(u); len(branch) > 0 {
+		err = useBranchCLI(ctx, dir, branch)
+		if err != nil {
+			return err
+		}
+	}
+	ref, err := gitCommand(ctx, "-C", dir, "symbolic-ref", "HEAD").Output()
+	if err != nil {
+		return fmt.Errorf("git symbolic-ref: %w", err)
+	}
+	noteRef(u, ch, strings.TrimSpace(string(ref)))
+	tip, err := gitCommand(ctx, "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return fmt.Errorf("git rev-parse: %w", err)
+	}
+	shallow, err := isShallowCLI(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if shallow && *unshallow {
+		fmt.Fprintln(progress, "git fetch --unshallow", u)
+		err = gitCommand(ctx, "-C", dir, "fetch", "--quiet", "--unshallow", u).Run()
+		if err != nil {
+			return fmt.Errorf("git fetch --unshallow: %w", err)
+		}
+		shallow = false
+	}
+
+	cmd := gitCommand(ctx, "-C", dir, "log", "--numstat", "--no-renames", "--pretty=format:"+gitLogFormat, "HEAD")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	commits, perr := parseGitLog(out)
+	err = cmd.Wait()
+	if perr != nil {
+		return perr
+	}
+	if err != nil {
+		return fmt.Errorf("git log: %w", err)
+	}
+	messages, err := gitMessages(ctx, dir)
+	if err != nil {
+		return err
+	}
+	signed, err := gitSigned(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for i := range commits {
+		commits[i].Message = messages[commits[i].Hash]
+		commits[i].Signed = signed[commits[i].Hash]
+	}
+	err = largeFilesCLI(ctx, dir, commits)
+	if err != nil {
+		return err
+	}
+	ch.Generated = headAttributesCLI(ctx, dir)
+	ch.Branches, err = readBranchesCLI(ctx, dir)
+	if err != nil {
+		return err
+	}
+	ch.Backports = nil
+	if patterns := cfg.backportBranches(u); len(patterns) > 0 {
+		ch.Backports, err = readBackportsCLI(ctx, dir, ch.Branches, patterns)
+		if err != nil {
+			return err
+		}
+	}
+	ch.Commits = commits
+	ch.LargeFile = *largeFileSize
+	noteFetch(ch, strings.TrimSpace(string(tip)), now)
+	noteTruncated(u, ch, shallow)
+	return nil
+}
+
+// gitSigned returns the commits of HEAD in dir that carry a signature,
+// from the gpgsig headers of the raw commits. Unlike %G? it does not run
+// GPG, so it is fast and does not depend on the keys at hand.
+func gitSigned(ctx context.Context, dir string) (map[string]bool, error) {
+	cmd := gitCommand(ctx, "-C", dir, "log", "--format=raw", "HEAD")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	signed := map[string]bool{}
+	var hash string
+	headers := false
+	sc := bufio.NewScanner(out)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "commit "):
+			hash, headers = strings.Fields(line)[1], true
+		case len(line) == 0:
+			// The message follows, indented.
+			headers = false
+		case headers && (strings.HasPrefix(line, "gpgsig ") || strings.HasPrefix(line, "gpgsig-sha256 ")):
+			signed[hash] = true
+		}
+	}
+	serr := sc.Err()
+	err = cmd.Wait()
+	if serr != nil {
+		return nil, serr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	return signed, nil
+}
+
+// gitMessages returns the message of each commit by hash. Messages span
+// lines, so they are read separately from the tab separated log.
+func gitMessages(ctx context.Context, dir string) (map[string]string, error) {
+	cmd := gitCommand(ctx, "-C", dir, "log", "-z", "--format=%H%n%B", "HEAD")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	messages := map[string]string{}
+	for _, rec := range strings.Split(string(out), "\x00") {
+		i := strings.IndexByte(rec, '\n')
+		if i < 0 {
+			continue
+		}
+		messages[rec[:i]] = rec[i+1:]
+	}
+	return messages, nil
+}
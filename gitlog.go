@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// importLog reads commits printed by git into the cache:
+//
+//	gitgraph import URL [FILE]
+//
+// FILE defaults to standard input. See parseGitLog for the formats.
+func importLog(ctx context.Context) error {
+	args := flag.Args()[1:]
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: import URL [FILE]")
+	}
+	u := args[0]
+	var in io.Reader = os.Stdin
+	if len(args) == 2 && args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	commits, err := parseGitLog(in)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits in input")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	charts := cfg.charts()
+	ch, ok := charts[u]
+	if !ok {
+		return fmt.Errorf("repository %q is not in the config", u)
+	}
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlockCache(lock)
+
+	ch.Commits = commits
+	// git log lists the newest commit first.
+	noteFetch(ch, commits[0].Hash, time.Now())
+	err = charts.Save(u)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d commits for %s\n", len(commits), u)
+	return nil
+}
+
+// gitLogFormat is the git log format read by parseGitLog. It leaves out
+// %G?, which verifies each signature with GPG; gitSigned reads whether
+// commits are signed instead.
+const gitLogFormat = "%H%x09%ct%x09%an%x09%ae"
+
+// parseGitLog reads the output of either
+//
+//	git rev-list --timestamp HEAD
+//	git log --numstat --pretty=format:'%H%x09%ct%x09%an%x09%ae%x09%G?'
+//
+// The --numstat option, the author fields and the signature status are
+// optional, and the commit time may also be given in ISO format with %cI.
+func parseGitLog(r io.Reader) ([]history.Commit, error) {
+	var list []history.Commit
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimRight(sc.Text(), "\r")
+		if len(strings.TrimSpace(text)) == 0 {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) == 1 {
+			fields = strings.Fields(text)
+		}
+		switch {
+		case len(fields) == 2 && isHash(fields[1]):
+			// rev-list: timestamp hash.
+			when, err := parseGitTime(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			list = append(list, history.Commit{Hash: fields[1], When: when})
+		case isHash(fields[0]) && len(fields) >= 2:
+			c := history.Commit{Hash: fields[0]}
+			var err error
+			c.When, err = parseGitTime(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if len(fields) > 2 {
+				c.Author = fields[2]
+			}
+			if len(fields) > 3 {
+				c.Email = fields[3]
+			}
+			if len(fields) > 4 {
+				// %G? is N for commits without a signature.
+				c.Signed = fields[4] != "N" && len(fields[4]) > 0
+			}
+			list = append(list, c)
+		case len(fields) == 3 && len(list) > 0:
+			// numstat: added deleted path, "-" for binary files.
+			add, aerr := parseStat(fields[0])
+			del, derr := parseStat(fields[1])
+			if aerr != nil || derr != nil {
+				return nil, fmt.Errorf("line %d: invalid numstat %q", line, text)
+			}
+			c := &list[len(list)-1]
+			c.Files = append(c.Files, history.File{Name: fields[2], Add: add, Del: del})
+		default:
+			return nil, fmt.Errorf("line %d: unrecognized %q", line, text)
+		}
+	}
+	return list, sc.Err()
+}
+
+func isHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseGitTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid commit time %q", s)
+	}
+	return t, nil
+}
+
+func parseStat(s string) (int, error) {
+	if s == "-" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+func TestParseGitLog(t *testing.T) {
+	const h1, h2 = "1111111111111111111111111111111111111111", "2222222222222222222222222222222222222222"
+	t1, t2 := time.Unix(1600000000, 0).UTC(), time.Unix(1500000000, 0).UTC()
+	tests := []struct {
+		name string
+		in   string
+		want []history.Commit
+		err  bool
+	}{
+		{
+			name: "rev-list",
+			in:   "1600000000 " + h1 + "\n1500000000 " + h2 + "\n",
+			want: []history.Commit{{Hash: h1, When: t1}, {Hash: h2, When: t2}},
+		},
+		{
+			name: "numstat",
+			in: h1 + "\t1600000000\tAnn\tann@example.com\n" +
+				"3\t1\tmain.go\n" +
+				"-\t-\tlogo.png\n" +
+				"\n" +
+				h2 + "\t1500000000\tBob\tbob@example.com\n" +
+				"10\t0\tREADME\n",
+			want: []history.Commit{
+				{Hash: h1, When: t1, Author: "Ann", Email: "ann@example.com", Files: []history.File{{Name: "main.go", Add: 3, Del: 1}, {Name: "logo.png"}}},
+				{Hash: h2, When: t2, Author: "Bob", Email: "bob@example.com", Files: []history.File{{Name: "README", Add: 10}}},
+			},
+		},
+		{
+			name: "signature status",
+			in:   h1 + "\t1600000000\tAnn\tann@example.com\tG\n" + h2 + "\t1500000000\tBob\tbob@example.com\tN\n",
+			want: []history.Commit{
+				{Hash: h1, When: t1, Author: "Ann", Email: "ann@example.com", Signed: true},
+				{Hash: h2, When: t2, Author: "Bob", Email: "bob@example.com"},
+			},
+		},
+		{
+			name: "iso time and crlf",
+			in:   h1 + "\t2020-09-13T12:26:40Z\tAnn\r\n",
+			want: []history.Commit{{Hash: h1, When: t1, Author: "Ann"}},
+		},
+		{name: "empty", in: "\n\n"},
+		{name: "bad time", in: h1 + "\tyesterday\n", err: true},
+		{name: "numstat first", in: "1\t2\tmain.go\n", err: true},
+		{name: "bad numstat", in: h1 + "\t1600000000\n" + "x\t2\tmain.go\n", err: true},
+		{name: "garbage", in: "not a log\n", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGitLog(strings.NewReader(tt.in))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parsed %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gnuplotScript is the name of the script in the gnuplot export.
+const gnuplotScript = "plot.gp"
+
+// exportGnuplot writes a directory with a tab separated data file of the
+// weekly metrics per repository and a gnuplot script that draws each
+// metric as a PDF with a line per repository. The script is meant as a
+// starting point to edit for print.
+func exportGnuplot(ctx context.Context, d *exportData, out string) error {
+	type dataFile struct {
+		Name, Title string
+	}
+	var list []dataFile
+	for _, u := range d.Charts.urls() {
+		ch := d.Charts[u]
+		if len(ch.Commits) == 0 {
+			continue
+		}
+		sh := newXLSXSheet(ch, d.Metrics, ch.Name)
+		name := d.Slugs[u] + ".dat"
+		err := writeFileAtomic(filepath.Join(out, name), func(w io.Writer) error {
+			bw := bufio.NewWriter(w)
+			fmt.Fprintf(bw, "# %s\n# week\t%s\n", ch.Name, strings.Join(d.Metrics, "\t"))
+			for i, t := range sh.Weeks {
+				bw.WriteString(t.Format("2006-01-02"))
+				for _, col := range sh.Values {
+					v := "?"
+					if !math.IsNaN(col[i]) {
+						v = strconv.FormatFloat(col[i], 'f', -1, 64)
+					}
+					bw.WriteString("\t" + v)
+				}
+				bw.WriteString("\n")
+			}
+			return bw.Flush()
+		})
+		if err != nil {
+			return err
+		}
+		list = append(list, dataFile{Name: name, Title: ch.Name})
+	}
+
+	return writeFileAtomic(filepath.Join(out, gnuplotScript), func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		bw.WriteString(`# Written by gitgraph export gnuplot. Run it in this directory with:
+#	gnuplot ` + gnuplotScript + `
+set terminal pdfcairo size 6in,3in font "Helvetica,10" noenhanced
+set datafile separator "\t"
+set datafile missing "?"
+set xdata time
+set timefmt "%Y-%m-%d"
+set format x "%Y"
+set key top left
+set grid
+`)
+		for i, metric := range d.Metrics {
+			fmt.Fprintf(bw, "\nset output %s\n", gnuplotQuote(slugify(metric)+".pdf"))
+			fmt.Fprintf(bw, "set ylabel %s\n", gnuplotQuote(metric+" per week"))
+			for j, f := range list {
+				sep := ", \\\n"
+				if j == 0 {
+					bw.WriteString("plot ")
+				} else {
+					bw.WriteString("     ")
+				}
+				if j == len(list)-1 {
+					sep = "\n"
+				}
+				fmt.Fprintf(bw, "%s using 1:%d with lines title %s%s", gnuplotQuote(f.Name), i+2, gnuplotQuote(f.Title), sep)
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+var gnuplotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// gnuplotQuote returns s as a double quoted gnuplot string.
+func gnuplotQuote(s string) string {
+	return `"` + gnuplotEscaper.Replace(s) + `"`
+}
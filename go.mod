@@ -5,7 +5,9 @@ go 1.16
 require (
 	github.com/go-git/go-git v4.7.0+incompatible // indirect
 	github.com/go-git/go-git/v5 v5.4.2 // indirect
+	github.com/jackc/pgx/v5 v5.0.0 // indirect
 	github.com/kardianos/task v0.0.0-20210112221240-c03b31243e29 // indirect
 	gonum.org/v1/plot v0.9.0 // indirect
 	gopkg.in/src-d/go-git.v4 v4.13.1 // indirect
+	modernc.org/sqlite v1.14.0 // indirect
 )
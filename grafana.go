@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// Grafana JSON datasource endpoints, mounted under /grafana/.
+//
+// Targets are "slug", "slug:metric" or "slug:metric:window". The window may
+// also be set with the query payload {"window": "monthly"}.
+
+func (s *server) grafana(mux *http.ServeMux) {
+	mux.HandleFunc("/grafana/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/grafana/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/grafana/search", s.grafanaSearch)
+	mux.HandleFunc("/grafana/metrics", s.grafanaMetrics)
+	mux.HandleFunc("/grafana/query", s.grafanaQuery)
+	mux.HandleFunc("/grafana/annotations", s.grafanaAnnotations)
+}
+
+func (s *server) grafanaTargets() ([]string, error) {
+	d, err := s.data()
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, u := range d.charts.urls() {
+		for _, metric := range history.Names() {
+			list = append(list, d.slugs[u]+":"+metric)
+		}
+	}
+	return list, nil
+}
+
+func (s *server) grafanaSearch(w http.ResponseWriter, r *http.Request) {
+	list, err := s.grafanaTargets()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, list)
+}
+
+func (s *server) grafanaMetrics(w http.ResponseWriter, r *http.Request) {
+	list, err := s.grafanaTargets()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	type option struct {
+		Label string `json:"label"`
+		Value string `json:"value"`
+	}
+	opts := make([]option, len(list))
+	for i, t := range list {
+		opts[i] = option{Label: t, Value: t}
+	}
+	writeJSON(w, opts)
+}
+
+type grafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target  string `json:"target"`
+		RefID   string `json:"refId"`
+		Hide    bool   `json:"hide"`
+		Payload struct {
+			Window string `json:"window"`
+		} `json:"payload"`
+	} `json:"targets"`
+}
+
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+func (s *server) grafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req grafanaQueryRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	d, err := s.data()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp := []grafanaSeries{}
+	for _, t := range req.Targets {
+		if t.Hide || len(t.Target) == 0 {
+			continue
+		}
+		parts := strings.SplitN(t.Target, ":", 3)
+		v := map[string][]string{}
+		if len(parts) > 1 {
+			v["metric"] = parts[1:2]
+		}
+		if len(parts) > 2 {
+			v["window"] = parts[2:3]
+		}
+		if len(t.Payload.Window) > 0 {
+			v["window"] = []string{t.Payload.Window}
+		}
+		q, err := parseSeriesQuery(v)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		q.since = req.Range.From
+		if !req.Range.To.IsZero() {
+			q.until = req.Range.To
+		}
+		u, ok := d.bySlug[parts[0]]
+		if !ok {
+			continue
+		}
+		gs := grafanaSeries{Target: t.Target, Datapoints: [][2]float64{}}
+		for _, pt := range q.points(d.charts[u]) {
+			ms := float64(pt.Time.UnixNano() / int64(time.Millisecond))
+			gs.Datapoints = append(gs.Datapoints, [2]float64{pt.Value, ms})
+		}
+		resp = append(resp, gs)
+	}
+	writeJSON(w, resp)
+}
+
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation"`
+	Time       int64           `json:"time"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// grafanaAnnotations returns the -annotations events in the range. The
+// annotation query may be a repository slug to select its events.
+func (s *server) grafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Range struct {
+			From time.Time `json:"from"`
+			To   time.Time `json:"to"`
+		} `json:"range"`
+		Annotation json.RawMessage `json:"annotation"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	var query struct {
+		Query string `json:"query"`
+	}
+	json.Unmarshal(req.Annotation, &query)
+	notes := s.notes
+	if slug := strings.TrimSpace(query.Query); len(slug) > 0 {
+		d, err := s.data()
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		u, ok := d.bySlug[slug]
+		if !ok {
+			httpError(w, http.StatusBadRequest, errors.New("unknown repository "+slug))
+			return
+		}
+		notes = annotationsFor(notes, u, d.charts[u].Name)
+	}
+	resp := []grafanaAnnotation{}
+	for _, a := range notes {
+		if a.Time.Before(req.Range.From) || (!req.Range.To.IsZero() && a.Time.After(req.Range.To)) {
+			continue
+		}
+		tags := []string{}
+		if len(a.Repo) > 0 {
+			tags = append(tags, a.Repo)
+		}
+		resp = append(resp, grafanaAnnotation{
+			Annotation: req.Annotation,
+			Time:       a.Time.UnixNano() / int64(time.Millisecond),
+			Title:      a.Label,
+			Text:       a.Label,
+			Tags:       tags,
+		})
+	}
+	writeJSON(w, resp)
+}
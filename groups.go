@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// groupConfig charts several repositories as one, such as a project and
+// its forks.
+type groupConfig struct {
+	Name string
+	// Repos are repository URLs or names.
+	Repos []string
+	// SharedY gives the charts of the members the same Y range per
+	// metric, so they can be compared at a glance.
+	SharedY bool `json:",omitempty"`
+}
+
+// groupURLs returns the repository URLs of g.
+func (cfg *config) groupURLs(g groupConfig) ([]string, error) {
+	var urls []string
+	for _, r := range g.Repos {
+		u, err := cfg.lookup(r)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %w", g.Name, err)
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// mergeCommits combines the commits of several repositories, keeping one
+// commit of each hash so shared history is counted once. Commits without
+// a hash, from old caches, are all kept.
+func mergeCommits(charts []*chart) (merged []history.Commit, dups int) {
+	seen := map[string]bool{}
+	for _, ch := range charts {
+		for _, c := range ch.Commits {
+			if len(c.Hash) > 0 {
+				if seen[c.Hash] {
+					dups++
+					continue
+				}
+				seen[c.Hash] = true
+			}
+			merged = append(merged, c)
+		}
+	}
+	return merged, dups
+}
+
+// renderGroups renders the charts of each group, with the same variants
+// as a repository, and adds them to the report. slugs are the repository
+// slugs, which group slugs must not reuse.
+func renderGroups(cfg *config, metricNames []string, duals []dualPair, opt chartOptions, slugs map[string]string, rep *report, sum *summary) []manifestEntry {
+	sg := newSlugger()
+	for _, s := range slugs {
+		sg[strings.ToLower(s)] = true
+	}
+	var manifest []manifestEntry
+	for _, g := range cfg.Groups {
+		urls, err := cfg.groupURLs(g)
+		if err != nil {
+			sum.Errors = append(sum.Errors, err.Error())
+			continue
+		}
+		members := make([]*chart, 0, len(urls))
+		for _, u := range urls {
+			ch := cfg.chart(u)
+			err = loadShard(u, ch)
+			if err != nil {
+				break
+			}
+			cutOff(ch)
+			cfg.sanitizeDates(ch, time.Now())
+			members = append(members, ch)
+		}
+		if err != nil {
+			sum.Errors = append(sum.Errors, fmt.Sprintf("group %q: %v", g.Name, err))
+			continue
+		}
+		ch := &chart{Name: g.Name, newMetric: cfg.newMetric}
+		var dups int
+		ch.Commits, dups = mergeCommits(members)
+		for i, m := range members {
+			// The group is as current as its least recently fetched member.
+			if i == 0 || m.Fetched.Before(ch.Fetched) {
+				ch.Fetched = m.Fetched
+			}
+			ch.Generated = append(ch.Generated, m.Generated...)
+			ch.ignore = append(ch.ignore, m.ignore...)
+			for p, l := range m.licenses {
+				if ch.licenses == nil {
+					ch.licenses = map[string]string{}
+				}
+				if _, ok := ch.licenses[p]; !ok {
+					ch.licenses[p] = l
+				}
+			}
+		}
+		if len(ch.Commits) == 0 {
+			continue
+		}
+		fmt.Printf("group %s: %d commits, %d shared commits counted once\n", g.Name, len(ch.Commits), dups)
+		overlap, multi, authors := contributorOverlap(members)
+		fmt.Printf("group %s: %d of %d authors contribute to more than one repository\n", g.Name, multi, authors)
+
+		slug := sg.unique(g.Name)
+		rr := newReportRepo("", slug, ch)
+		gopt := opt
+		gopt.notes = append(annotationsFor(opt.notes, "", g.Name), handoffAnnotations(rr)...)
+		gopt.style = cfg.repoStyle("", slug)
+		gopt.chartStyle = cfg.chartStyle("")
+		files, _ := renderCharts(ch, slug, strings.Join(g.Repos, ", "), metricNames, duals, gopt, nil, rr, func(err error) {
+			sum.Errors = append(sum.Errors, fmt.Sprintf("group %q: %v", g.Name, err))
+		})
+		if len(members) > 1 {
+			base := overlapFilename(slug)
+			err = writeOverlap(members, overlap, filepath.Join(outputDir, base+".csv"), filepath.Join(outputDir, base+".png"))
+			if err != nil {
+				sum.Errors = append(sum.Errors, fmt.Sprintf("group %q: %v", g.Name, err))
+			} else {
+				files = append(files, base+".csv", base+".png")
+				rr.Charts = append(rr.Charts, reportChart{Metric: "contributor overlap", File: base + ".png"})
+			}
+		}
+		if len(rr.Charts) > 0 {
+			rep.Repos = append(rep.Repos, rr)
+		}
+		manifest = append(manifest, manifestEntry{
+			Name:  g.Name,
+			Slug:  slug,
+			Files: append(files, rr.Page),
+		})
+	}
+	return manifest
+}
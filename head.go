@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// defaultBranch picks the branch to analyze from the references the
+// remote advertised: the target of its symbolic HEAD, else the branch
+// HEAD points at, else main or master. It returns "" if the remote has no
+// branches.
+func defaultBranch(refs []*plumbing.Reference) plumbing.ReferenceName {
+	branches := map[plumbing.ReferenceName]plumbing.Hash{}
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		switch {
+		case ref.Name() == plumbing.HEAD:
+			head = ref
+		case ref.Name().IsBranch() && ref.Type() == plumbing.HashReference:
+			branches[ref.Name()] = ref.Hash()
+		}
+	}
+	if head != nil && head.Type() == plumbing.SymbolicReference {
+		if _, ok := branches[head.Target()]; ok {
+			return head.Target()
+		}
+	}
+	preferred := []plumbing.ReferenceName{"refs/heads/main", "refs/heads/master"}
+	if head != nil && head.Type() == plumbing.HashReference {
+		for _, name := range preferred {
+			if h, ok := branches[name]; ok && h == head.Hash() {
+				return name
+			}
+		}
+		var match plumbing.ReferenceName
+		for name, h := range branches {
+			if h == head.Hash() && (len(match) == 0 || name < match) {
+				match = name
+			}
+		}
+		if len(match) > 0 {
+			return match
+		}
+	}
+	for _, name := range preferred {
+		if _, ok := branches[name]; ok {
+			return name
+		}
+	}
+	var first plumbing.ReferenceName
+	for name := range branches {
+		if len(first) == 0 || name < first {
+			first = name
+		}
+	}
+	return first
+}
+
+// setHead points HEAD of the mirror r at the default branch of the
+// remote, following renames such as master to main. A branch configured
+// for the repository is set afterwards by useBranch.
+func setHead(r *git.Repository, refs []*plumbing.Reference) error {
+	name := defaultBranch(refs)
+	if len(name) == 0 {
+		return fmt.Errorf("remote has no branches")
+	}
+	return r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, name))
+}
+
+// setHeadCLI is setHead for the cli backend.
+func setHeadCLI(ctx context.Context, u, dir string) error {
+	out, err := gitCommand(ctx, "ls-remote", "--symref", u, "HEAD").Output()
+	if err != nil {
+		return fmt.Errorf("git ls-remote: %w", err)
+	}
+	// The symbolic HEAD is listed as "ref: refs/heads/main\tHEAD".
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, "ref: ") || !strings.HasSuffix(line, "\tHEAD") {
+			continue
+		}
+		target := strings.TrimSuffix(strings.TrimPrefix(line, "ref: "), "\tHEAD")
+		err = gitCommand(ctx, "-C", dir, "symbolic-ref", "HEAD", target).Run()
+		if err != nil {
+			return fmt.Errorf("git symbolic-ref: %w", err)
+		}
+		return nil
+	}
+	// Without a symbolic HEAD the one set at clone time is kept.
+	return nil
+}
+
+// useBranch points HEAD of r at branch, the branch configured to analyze
+// instead of the default one. Clones into memory only have it as a
+// remote branch.
+func useBranch(r *git.Repository, branch string) error {
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(branch),
+		plumbing.NewRemoteReferenceName("origin", branch),
+	} {
+		_, err := r.Reference(name, false)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, name))
+	}
+	return fmt.Errorf("branch %q not found", branch)
+}
+
+// useBranchCLI is useBranch for the cli backend.
+func useBranchCLI(ctx context.Context, dir, branch string) error {
+	name := string(plumbing.NewBranchReferenceName(branch))
+	err := gitCommand(ctx, "-C", dir, "rev-parse", "--verify", "--quiet", name).Run()
+	if err != nil {
+		return fmt.Errorf("branch %q not found", branch)
+	}
+	err = gitCommand(ctx, "-C", dir, "symbolic-ref", "HEAD", name).Run()
+	if err != nil {
+		return fmt.Errorf("git symbolic-ref: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var heatmapMonths = flag.Int("heatmap", 0, "render "+heatmapFilename+" with a row per repository and a column for each of the last N months; zero disables it")
+
+const heatmapFilename = "heatmap.png"
+
+// heatmap counts the commits of each repository per calendar month.
+type heatmap struct {
+	start  time.Time // First day of the first month.
+	months int
+	rows   []heatmapRow
+}
+
+type heatmapRow struct {
+	Name   string
+	Counts []int
+}
+
+// newHeatmap returns a heatmap of the months up to and including the
+// month of now.
+func newHeatmap(now time.Time, months int) *heatmap {
+	now = now.UTC()
+	return &heatmap{
+		start:  time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, time.UTC),
+		months: months,
+	}
+}
+
+// month returns the column of t, or -1 if it is outside the heatmap.
+func (h *heatmap) month(t time.Time) int {
+	t = t.UTC()
+	i := (t.Year()-h.start.Year())*12 + int(t.Month()-h.start.Month())
+	if i < 0 || i >= h.months {
+		return -1
+	}
+	return i
+}
+
+func (h *heatmap) add(name string, commits []history.Commit) {
+	row := heatmapRow{Name: name, Counts: make([]int, h.months)}
+	for _, c := range commits {
+		if i := h.month(c.When); i >= 0 {
+			row.Counts[i]++
+		}
+	}
+	h.rows = append(h.rows, row)
+}
+
+var (
+	heatmapEmpty = color.RGBA{R: 0xeb, G: 0xed, B: 0xf0, A: 0xff}
+	heatmapFull  = color.RGBA{R: 0x21, G: 0x6e, B: 0x39, A: 0xff}
+)
+
+// shade returns the cell color of n commits. The scale is logarithmic so
+// a few busy repositories do not wash out the rest.
+func (h *heatmap) shade(n, max int) color.Color {
+	if n == 0 || max == 0 {
+		return heatmapEmpty
+	}
+	f := math.Log1p(float64(n)) / math.Log1p(float64(max))
+	// Start a quarter of the way in so a single commit is visible.
+	f = 0.25 + 0.75*f
+	mix := func(a, b uint8) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*f) }
+	return color.RGBA{
+		R: mix(heatmapEmpty.R, heatmapFull.R),
+		G: mix(heatmapEmpty.G, heatmapFull.G),
+		B: mix(heatmapEmpty.B, heatmapFull.B),
+		A: 0xff,
+	}
+}
+
+// render draws the heatmap with repository names on the left and month
+// labels along the bottom.
+func (h *heatmap) render(filename string) error {
+	const (
+		cell   = vg.Length(16)
+		gap    = vg.Length(2)
+		margin = vg.Length(12)
+	)
+	labelFont := plot.DefaultFont
+	labelFont.Size = 10
+	labelFace := font.DefaultCache.Lookup(labelFont, labelFont.Size)
+	var labelWidth vg.Length
+	for _, r := range h.rows {
+		if w := labelFace.Width(r.Name); w > labelWidth {
+			labelWidth = w
+		}
+	}
+	max := 0
+	for _, r := range h.rows {
+		for _, n := range r.Counts {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	left := margin + labelWidth + margin
+	bottom := margin + 3*cell
+	width := left + vg.Length(h.months)*(cell+gap) + margin
+	height := bottom + vg.Length(len(h.rows))*(cell+gap) + margin
+
+	c, err := draw.NewFormattedCanvas(width, height, "png")
+	if err != nil {
+		return err
+	}
+	dc := draw.New(c)
+	dc.FillPolygon(color.White, []vg.Point{{X: 0, Y: 0}, {X: width, Y: 0}, {X: width, Y: height}, {X: 0, Y: height}})
+	text := draw.TextStyle{Color: color.Black, Font: labelFont, XAlign: draw.XRight, YAlign: draw.YCenter, Handler: plot.DefaultTextHandler}
+	for i, r := range h.rows {
+		y := height - margin - vg.Length(i+1)*(cell+gap)
+		dc.FillText(text, vg.Point{X: left - margin, Y: y + cell/2}, r.Name)
+		for m, n := range r.Counts {
+			x := left + vg.Length(m)*(cell+gap)
+			dc.FillPolygon(h.shade(n, max), []vg.Point{{X: x, Y: y}, {X: x + cell, Y: y}, {X: x + cell, Y: y + cell}, {X: x, Y: y + cell}})
+		}
+	}
+	// Label January and the first column, rotated to fit.
+	text.XAlign, text.YAlign, text.Rotation = draw.XRight, draw.YCenter, math.Pi/2
+	for m := 0; m < h.months; m++ {
+		t := h.start.AddDate(0, m, 0)
+		if m != 0 && t.Month() != time.January {
+			continue
+		}
+		x := left + vg.Length(m)*(cell+gap) + cell/2
+		dc.FillText(text, vg.Point{X: x, Y: bottom - gap}, t.Format("2006-01"))
+	}
+
+	return writeCanvas(c, filename)
+}
@@ -0,0 +1,54 @@
+package history
+
+import "time"
+
+// Calendar tells business days from weekends and holidays.
+type Calendar struct {
+	// Holidays are dates, as 2006-01-02, that are not business days.
+	Holidays map[string]bool
+}
+
+// IsBusinessDay reports whether t falls on a weekday that is not a
+// holiday, in the time zone of t.
+func (cal *Calendar) IsBusinessDay(t time.Time) bool {
+	switch t.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return !cal.Holidays[t.Format("2006-01-02")]
+}
+
+// BusinessSeries computes m over the commits made on business days and
+// divides each value by the number of business days in its window, giving
+// a rate per business day, unless m is a Ratio. Windows without business
+// days are left out.
+func BusinessSeries(commits []Commit, w Window, m Metric, cal *Calendar) []Point {
+	ratio := false
+	if r, ok := m.(Ratio); ok {
+		ratio = r.Ratio()
+	}
+	list := make([]Commit, 0, len(commits))
+	for _, c := range commits {
+		if cal.IsBusinessDay(c.When) {
+			list = append(list, c)
+		}
+	}
+	points := Series(list, w, m)
+	out := points[:0]
+	for _, pt := range points {
+		days := 0
+		for d := pt.Time; w.Start(d).Equal(pt.Time); d = d.AddDate(0, 0, 1) {
+			if cal.IsBusinessDay(d) {
+				days++
+			}
+		}
+		if days == 0 {
+			continue
+		}
+		if !ratio {
+			pt.Value /= float64(days)
+		}
+		out = append(out, pt)
+	}
+	return out
+}
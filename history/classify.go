@@ -0,0 +1,118 @@
+package history
+
+import "regexp"
+
+func init() {
+	Register(func() Metric { return Classifier{}.New("fixes") })
+	Register(func() Metric { return Classifier{}.New("features") })
+	Register(func() Metric { return Classifier{}.New("fix-ratio") })
+}
+
+// FixPatterns and FeaturePatterns classify commits by their subject.
+// A Classifier uses other patterns.
+var (
+	FixPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^fix(\(.*\))?!?:`),
+		regexp.MustCompile(`(?i)\b(fix(es|ed)?|bug(fix)?|hotfix|regression)\b`),
+	}
+	FeaturePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^feat(\(.*\))?!?:`),
+		regexp.MustCompile(`(?i)^(\S+: )?(add|implement|introduce|support|new)\b`),
+	}
+)
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFix reports if the subject of c matches FixPatterns.
+func IsFix(c *Commit) bool {
+	return Classifier{}.IsFix(c)
+}
+
+// IsFeature reports if the subject of c matches FeaturePatterns and not
+// FixPatterns.
+func IsFeature(c *Commit) bool {
+	return Classifier{}.IsFeature(c)
+}
+
+// Classifier tells fixes from features by the subject of commits. A nil
+// Fix or Feature list stands for FixPatterns or FeaturePatterns.
+type Classifier struct {
+	Fix     []*regexp.Regexp
+	Feature []*regexp.Regexp
+}
+
+func (cl Classifier) fix() []*regexp.Regexp {
+	if cl.Fix == nil {
+		return FixPatterns
+	}
+	return cl.Fix
+}
+
+func (cl Classifier) feature() []*regexp.Regexp {
+	if cl.Feature == nil {
+		return FeaturePatterns
+	}
+	return cl.Feature
+}
+
+// IsFix reports if the subject of c matches the fix patterns of cl.
+func (cl Classifier) IsFix(c *Commit) bool {
+	return matchAny(cl.fix(), c.Subject())
+}
+
+// IsFeature reports if the subject of c matches the feature patterns of
+// cl and not the fix ones.
+func (cl Classifier) IsFeature(c *Commit) bool {
+	s := c.Subject()
+	return matchAny(cl.feature(), s) && !matchAny(cl.fix(), s)
+}
+
+// New returns a new instance of the fixes, features or fix-ratio metric
+// using cl, or nil for any other name.
+func (cl Classifier) New(name string) Metric {
+	switch name {
+	case "fixes":
+		return NewCount(name, cl.IsFix)
+	case "features":
+		return NewCount(name, cl.IsFeature)
+	case "fix-ratio":
+		return &fixRatio{cl: cl}
+	}
+	return nil
+}
+
+// fixRatio is the percentage of fixes among the commits of a window that
+// are either a fix or a feature.
+type fixRatio struct {
+	cl       Classifier
+	fixes    int
+	features int
+}
+
+func (m *fixRatio) Name() string { return "fix-ratio" }
+func (m *fixRatio) Ratio() bool  { return true }
+
+func (m *fixRatio) Accumulate(c *Commit) {
+	switch {
+	case m.cl.IsFix(c):
+		m.fixes++
+	case m.cl.IsFeature(c):
+		m.features++
+	}
+}
+
+func (m *fixRatio) Finalize() float64 {
+	var v float64
+	if n := m.fixes + m.features; n > 0 {
+		v = 100 * float64(m.fixes) / float64(n)
+	}
+	m.fixes, m.features = 0, 0
+	return v
+}
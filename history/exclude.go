@@ -0,0 +1,81 @@
+package history
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchAttributes reports if name matches a pattern in the syntax of
+// .gitattributes: a pattern without a slash matches the base name in any
+// directory, others match from the repository root, and "**" matches
+// any number of directories.
+func MatchAttributes(pattern, name string) bool {
+	p := strings.TrimPrefix(pattern, "/")
+	switch {
+	case strings.HasPrefix(p, "**/"):
+		rest := p[3:]
+		for n := name; ; {
+			if MatchAttributes(rest, n) {
+				return true
+			}
+			i := strings.IndexByte(n, '/')
+			if i < 0 {
+				return false
+			}
+			n = n[i+1:]
+		}
+	case strings.HasSuffix(p, "/**"):
+		return strings.HasPrefix(name, p[:len(p)-2])
+	case !strings.Contains(strings.TrimSuffix(p, "/"), "/") && !strings.HasPrefix(pattern, "/"):
+		for _, elem := range strings.Split(name, "/") {
+			if ok, _ := path.Match(strings.TrimSuffix(p, "/"), elem); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if ok, _ := path.Match(p, name); ok {
+		return true
+	}
+	// A directory pattern covers the files below it.
+	dir := strings.TrimSuffix(p, "/")
+	for d := path.Dir(name); d != "."; d = path.Dir(d) {
+		if ok, _ := path.Match(dir, d); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludeFiles returns commits with the files matching any of the
+// patterns, in the syntax of MatchAttributes, left out. Commits are
+// copied only if they change.
+func ExcludeFiles(commits []Commit, patterns []string) []Commit {
+	if len(patterns) == 0 {
+		return commits
+	}
+	out := make([]Commit, len(commits))
+	for i, c := range commits {
+		out[i] = c
+		var files []File
+		for j, f := range c.Files {
+			skip := false
+			for _, p := range patterns {
+				if MatchAttributes(p, f.Name) {
+					skip = true
+					break
+				}
+			}
+			if skip && files == nil {
+				files = append(make([]File, 0, len(c.Files)), c.Files[:j]...)
+			}
+			if files != nil && !skip {
+				files = append(files, f)
+			}
+		}
+		if files != nil {
+			out[i].Files = files
+		}
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Handoff is a change of the author with the most commits.
+type Handoff struct {
+	// Time is the start of the first window the new author led.
+	Time     time.Time
+	From, To Person
+	// Share is the percentage of commits by To over the windows it took
+	// the lead in.
+	Share float64
+}
+
+const (
+	// handoffMinCommits is the number of commits a window needs to have a
+	// leading author.
+	handoffMinCommits = 5
+	// handoffMinShare is the percentage of the commits of a window the
+	// leading author must have.
+	handoffMinShare = 30
+	// handoffWindows is the number of windows in a row a new author must
+	// lead, so one busy window is not a handoff.
+	handoffWindows = 2
+)
+
+// Handoffs returns the changes of the dominant author of commits over the
+// windows of w, oldest first.
+func Handoffs(commits []Commit, w Window) []Handoff {
+	type window struct {
+		start  time.Time
+		total  int
+		counts map[string]int
+	}
+	people := map[string]Person{}
+	byStart := map[time.Time]*window{}
+	var list []*window
+	for i := range commits {
+		c := &commits[i]
+		p := Person{Name: c.Author, Email: c.Email}
+		if _, ok := people[p.Key()]; !ok {
+			people[p.Key()] = p
+		}
+		start := w.Start(c.When)
+		win := byStart[start]
+		if win == nil {
+			win = &window{start: start, counts: map[string]int{}}
+			byStart[start] = win
+			list = append(list, win)
+		}
+		win.total++
+		win.counts[p.Key()]++
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].start.Before(list[j].start) })
+
+	var handoffs []Handoff
+	var current, candidate string
+	var since time.Time
+	var run, runTotal, runCommits int
+	for _, win := range list {
+		if win.total < handoffMinCommits {
+			continue
+		}
+		leader, most := current, win.counts[current]
+		for k, n := range win.counts {
+			if n > most || (n == most && k < leader && leader != current) {
+				leader, most = k, n
+			}
+		}
+		if 100*most < handoffMinShare*win.total {
+			leader = ""
+		}
+		switch {
+		case len(leader) == 0 || leader == current:
+			candidate, run = "", 0
+			continue
+		case leader != candidate:
+			candidate, since, run, runTotal, runCommits = leader, win.start, 0, 0, 0
+		}
+		run++
+		runTotal += win.total
+		runCommits += most
+		if len(current) == 0 {
+			// The first leader is not a handoff.
+			current, candidate, run = leader, "", 0
+			continue
+		}
+		if run < handoffWindows {
+			continue
+		}
+		handoffs = append(handoffs, Handoff{
+			Time:  since,
+			From:  people[current],
+			To:    people[leader],
+			Share: 100 * float64(runCommits) / float64(runTotal),
+		})
+		current, candidate, run = leader, "", 0
+	}
+	return handoffs
+}
@@ -0,0 +1,94 @@
+package history
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Health is a 0 to 100 score of how actively a project is maintained,
+// the sum of four parts computed over the commits before a time now:
+//
+//	Recency       30 * max(0, 1 - days since the last commit / 180)
+//	Trend         25 * min(1, commits in the last 12 weeks / commits in the 12 weeks before)
+//	Contributors  20 * min(1, authors in the last year / 10)
+//	BusFactor     25 * min(1, bus factor of the last year / 4)
+//
+// The bus factor is the fewest authors that together made half of the
+// commits. Trend is full when there were no commits in the earlier 12
+// weeks but some in the last 12, and zero when there were none in either.
+type Health struct {
+	Score        int
+	Recency      float64
+	Trend        float64
+	Contributors float64
+	BusFactor    float64
+}
+
+// NewHealth scores commits as of now. Commits after now are ignored.
+func NewHealth(commits []Commit, now time.Time) Health {
+	const day = 24 * time.Hour
+	var last time.Time
+	var recent, earlier int
+	yearAuthors := map[string]int{}
+	for _, c := range commits {
+		if c.When.After(now) {
+			continue
+		}
+		if c.When.After(last) {
+			last = c.When
+		}
+		age := now.Sub(c.When)
+		switch {
+		case age < 12*7*day:
+			recent++
+		case age < 24*7*day:
+			earlier++
+		}
+		if age < 365*day {
+			for _, p := range c.Authors() {
+				yearAuthors[p.Key()]++
+			}
+		}
+	}
+	var h Health
+	if !last.IsZero() {
+		days := now.Sub(last).Hours() / 24
+		h.Recency = 30 * math.Max(0, 1-days/180)
+	}
+	switch {
+	case earlier > 0:
+		h.Trend = 25 * math.Min(1, float64(recent)/float64(earlier))
+	case recent > 0:
+		h.Trend = 25
+	}
+	h.Contributors = 20 * math.Min(1, float64(len(yearAuthors))/10)
+	h.BusFactor = 25 * math.Min(1, float64(busFactor(yearAuthors))/4)
+	h.Score = int(math.Round(h.Recency + h.Trend + h.Contributors + h.BusFactor))
+	return h
+}
+
+// AuthorKey identifies the author of c, by email if known. Co-authors
+// are not included; see Commit.Authors.
+func AuthorKey(c Commit) string {
+	return Person{Name: c.Author, Email: c.Email}.Key()
+}
+
+// busFactor returns the fewest authors covering half of the commits.
+func busFactor(authors map[string]int) int {
+	counts := make([]int, 0, len(authors))
+	total := 0
+	for _, n := range authors {
+		counts = append(counts, n)
+		total += n
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	sum := 0
+	for i, n := range counts {
+		sum += n
+		if 2*sum >= total {
+			return i + 1
+		}
+	}
+	return 0
+}
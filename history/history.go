@@ -0,0 +1,281 @@
+// Package history holds commit records and the metrics computed from them.
+//
+// Metrics are registered by name with Register. The gitgraph command
+// computes every selected metric over the cached commits of each
+// repository and renders one chart per metric:
+//
+//	func init() {
+//		history.Register(func() history.Metric {
+//			return history.NewPathMetric("proto", "*.proto")
+//		})
+//	}
+package history
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Commit is the cached record of a single commit.
+type Commit struct {
+	Hash   string `json:",omitempty"`
+	When   time.Time
+	Author string `json:",omitempty"`
+	Email  string `json:",omitempty"`
+	// Signed is set if the commit carries a GPG or SSH signature. It is
+	// not verified.
+	Signed bool `json:",omitempty"`
+	// Message is the full commit message.
+	Message string `json:",omitempty"`
+	Files   []File `json:",omitempty"`
+}
+
+// File is a file changed by a commit, with the number of lines added and
+// deleted.
+type File struct {
+	Name string
+	Add  int `json:",omitempty"`
+	Del  int `json:",omitempty"`
+	// Size is the size in bytes after the commit, recorded only for large
+	// files.
+	Size int64 `json:",omitempty"`
+	// LFS is set if the file is a Git LFS pointer.
+	LFS bool `json:",omitempty"`
+}
+
+// UnmarshalJSON also accepts a bare timestamp, as written by older caches.
+func (c *Commit) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		*c = Commit{}
+		return json.Unmarshal(b, &c.When)
+	}
+	type plain Commit
+	return json.Unmarshal(b, (*plain)(c))
+}
+
+// Metric computes a value for each window of commits.
+type Metric interface {
+	// Name identifies the metric in configuration and file names.
+	Name() string
+	// Accumulate adds a commit to the current window.
+	Accumulate(c *Commit)
+	// Finalize returns the value of the current window and resets the
+	// metric for the next one.
+	Finalize() float64
+}
+
+// Ratio is implemented by metrics whose value is a share of the commits
+// of a window rather than a count, such as the percentage of signed
+// commits. Their values are not divided into rates per business day.
+type Ratio interface {
+	Ratio() bool
+}
+
+// Rolling is implemented by metrics whose value carries over windows
+// without commits. Series reports those windows too, with the value Idle
+// returns given the end of the window.
+type Rolling interface {
+	Idle(end time.Time) float64
+}
+
+// mu guards registry and windows, which a server may change on reload
+// while requests look them up.
+var (
+	mu       sync.RWMutex
+	registry = map[string]func() Metric{}
+)
+
+// Register makes a metric available by name. It panics if the name is
+// already registered.
+func Register(fn func() Metric) {
+	name := fn().Name()
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("history: metric registered twice: " + name)
+	}
+	registry[name] = fn
+}
+
+// Replace registers a metric, replacing any registered under the same
+// name.
+func Replace(fn func() Metric) {
+	name := fn().Name()
+	mu.Lock()
+	registry[name] = fn
+	mu.Unlock()
+}
+
+// New returns a new instance of the named metric, or nil if the metric is
+// not registered.
+func New(name string) Metric {
+	mu.RLock()
+	fn, ok := registry[name]
+	mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return fn()
+}
+
+// Names returns the sorted names of the registered metrics.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]string, 0, len(registry))
+	for name := range registry {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Window splits time into consecutive periods.
+type Window interface {
+	// Start returns the start of the period containing t.
+	Start(t time.Time) time.Time
+}
+
+// Weekly groups by weeks of the Unix epoch.
+var Weekly Window = fixedWindow(7 * 24 * time.Hour)
+
+type fixedWindow time.Duration
+
+func (w fixedWindow) Start(t time.Time) time.Time {
+	size := int64(time.Duration(w) / time.Second)
+	s := t.Unix()
+	b := s / size
+	if s%size < 0 {
+		b--
+	}
+	return time.Unix(b*size, 0).UTC()
+}
+
+// Point is the value of a metric for the window starting at Time.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series computes m for each window of w that has commits.
+func Series(commits []Commit, w Window, m Metric) []Point {
+	order := make([]int, len(commits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return commits[order[i]].When.Before(commits[order[j]].When)
+	})
+
+	rolling, _ := m.(Rolling)
+	var list []Point
+	var current time.Time
+	for n, i := range order {
+		c := &commits[i]
+		start := w.Start(c.When)
+		if n > 0 && !start.Equal(current) {
+			list = append(list, Point{Time: current, Value: m.Finalize()})
+			if rolling != nil {
+				for t := nextWindow(w, current); t.Before(start); t = nextWindow(w, t) {
+					list = append(list, Point{Time: t, Value: rolling.Idle(nextWindow(w, t))})
+				}
+			}
+		}
+		current = start
+		m.Accumulate(c)
+	}
+	if len(order) > 0 {
+		list = append(list, Point{Time: current, Value: m.Finalize()})
+	}
+	return list
+}
+
+// nextWindow returns the start of the window after the one that starts
+// at start.
+func nextWindow(w Window, start time.Time) time.Time {
+	d := start.AddDate(0, 0, 1)
+	for w.Start(d).Equal(start) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return w.Start(d)
+}
+
+type calendarWindow func(t time.Time) time.Time
+
+func (w calendarWindow) Start(t time.Time) time.Time { return w(t.UTC()) }
+
+// Calendar windows start at midnight UTC.
+var (
+	Daily Window = calendarWindow(func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	})
+	Monthly Window = calendarWindow(func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	})
+	Quarterly Window = calendarWindow(func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	})
+	Yearly Window = calendarWindow(func(t time.Time) time.Time {
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	})
+)
+
+// FiscalYear returns a window of years starting on the first day of the
+// month start.
+func FiscalYear(start time.Month) Window {
+	return calendarWindow(func(t time.Time) time.Time {
+		y := t.Year()
+		if t.Month() < start {
+			y--
+		}
+		return time.Date(y, start, 1, 0, 0, 0, 0, time.UTC)
+	})
+}
+
+// FiscalQuarter returns a window of the quarters of a fiscal year that
+// starts in the month start.
+func FiscalQuarter(start time.Month) Window {
+	return calendarWindow(func(t time.Time) time.Time {
+		into := (int(t.Month()) - int(start) + 12) % 3
+		return time.Date(t.Year(), t.Month()-time.Month(into), 1, 0, 0, 0, 0, time.UTC)
+	})
+}
+
+var windows = map[string]Window{
+	"daily":          Daily,
+	"weekly":         Weekly,
+	"monthly":        Monthly,
+	"quarterly":      Quarterly,
+	"yearly":         Yearly,
+	"fiscal-quarter": FiscalQuarter(time.January),
+	"fiscal-year":    FiscalYear(time.January),
+}
+
+// RegisterWindow makes w available by name, replacing any window of that
+// name.
+func RegisterWindow(name string, w Window) {
+	mu.Lock()
+	windows[name] = w
+	mu.Unlock()
+}
+
+// LookupWindow returns the named window, or nil if there is none.
+func LookupWindow(name string) Window {
+	mu.RLock()
+	defer mu.RUnlock()
+	return windows[name]
+}
+
+// WindowNames returns the sorted names of the windows.
+func WindowNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]string, 0, len(windows))
+	for name := range windows {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	Register(func() Metric { return NewShare("dco", SignedOff) })
+	Register(func() Metric {
+		return NewShare("coauthored", func(c *Commit) bool { return len(c.Trailers("Co-authored-by")) > 0 })
+	})
+	Register(func() Metric { return NewCount("reverts", IsRevert) })
+	Register(func() Metric { return NewShare("revert-rate", IsRevert) })
+	Register(func() Metric { return NewShare("issue-refs", ReferencesIssue) })
+	Register(func() Metric {
+		return NewAverage("subject-length", func(c *Commit) float64 { return float64(utf8.RuneCountInString(c.Subject())) })
+	})
+	Register(func() Metric {
+		return NewAverage("body-length", func(c *Commit) float64 { return float64(utf8.RuneCountInString(c.Body())) })
+	})
+	Register(func() Metric {
+		return NewShare("with-body", func(c *Commit) bool { return len(c.Body()) > 0 })
+	})
+}
+
+// Person is an author of a commit.
+type Person struct {
+	Name  string
+	Email string
+}
+
+// Key identifies the person, by email if known.
+func (p Person) Key() string {
+	if len(p.Email) > 0 {
+		return p.Email
+	}
+	return p.Name
+}
+
+// parsePerson reads "Name <email>".
+func parsePerson(s string) Person {
+	i := strings.LastIndexByte(s, '<')
+	j := strings.LastIndexByte(s, '>')
+	if i < 0 || j < i {
+		return Person{Name: strings.TrimSpace(s)}
+	}
+	return Person{Name: strings.TrimSpace(s[:i]), Email: strings.TrimSpace(s[i+1 : j])}
+}
+
+// Authors returns the author of c followed by the co-authors credited with
+// Co-authored-by trailers, each once.
+func (c *Commit) Authors() []Person {
+	list := []Person{{Name: c.Author, Email: c.Email}}
+	seen := map[string]bool{list[0].Key(): true}
+	for _, v := range c.Trailers("Co-authored-by") {
+		p := parsePerson(v)
+		if len(p.Key()) == 0 || seen[p.Key()] {
+			continue
+		}
+		seen[p.Key()] = true
+		list = append(list, p)
+	}
+	return list
+}
+
+// Subject returns the first line of the commit message.
+func (c *Commit) Subject() string {
+	s := strings.TrimSpace(c.Message)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// Body returns the commit message after the subject, without a final
+// paragraph of trailers such as Signed-off-by.
+func (c *Commit) Body() string {
+	s := strings.TrimSpace(c.Message)
+	i := strings.IndexByte(s, '\n')
+	if i < 0 {
+		return ""
+	}
+	s = strings.TrimSpace(s[i+1:])
+	last := s
+	j := strings.LastIndex(s, "\n\n")
+	if j >= 0 {
+		last = s[j+2:]
+	}
+	for _, line := range strings.Split(last, "\n") {
+		if !isTrailer(line) {
+			return s
+		}
+	}
+	if j < 0 {
+		return ""
+	}
+	return strings.TrimSpace(s[:j])
+}
+
+// isTrailer reports if line looks like "Key: value" with a key of letters,
+// digits and dashes.
+func isTrailer(line string) bool {
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 || !strings.HasPrefix(line[colon+1:], " ") {
+		return false
+	}
+	for _, r := range line[:colon] {
+		if !(r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Trailers returns the values of the trailers with key, such as
+// "Signed-off-by", in the last paragraph of the commit message. Keys are
+// matched without regard to case.
+func (c *Commit) Trailers(key string) []string {
+	msg := strings.TrimSpace(c.Message)
+	i := strings.LastIndex(msg, "\n\n")
+	if i < 0 {
+		// A message of only a subject has no trailers.
+		return nil
+	}
+	var values []string
+	for _, line := range strings.Split(msg[i+2:], "\n") {
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 || !strings.EqualFold(strings.TrimSpace(line[:colon]), key) {
+			continue
+		}
+		if v := strings.TrimSpace(line[colon+1:]); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// SignedOff reports if c has a Signed-off-by trailer, as required by the
+// Developer Certificate of Origin.
+func SignedOff(c *Commit) bool {
+	return len(c.Trailers("Signed-off-by")) > 0
+}
+
+// IsRevert reports if c reverts another commit, by the subject and body
+// git revert writes: Revert "subject" and "This reverts commit <hash>".
+func IsRevert(c *Commit) bool {
+	return strings.HasPrefix(c.Subject(), `Revert "`) || strings.Contains(c.Message, "This reverts commit ")
+}
+
+// issueRef matches references such as #123, GH-123, org/repo#123 and
+// links to issues, pull requests and merge requests.
+var issueRef = regexp.MustCompile(`(?m)(^|[\s(\[,;:])([\w.-]+/[\w.-]+)?#\d+\b|\bGH-\d+\b|/(issues|pull|merge_requests)/\d+`)
+
+// issueTrailers are trailers that name an issue by any means.
+var issueTrailers = []string{"Fixes", "Closes", "Resolves", "Refs", "Bug", "Issue"}
+
+// ReferencesIssue reports if the message of c refers to an issue or pull
+// request.
+func ReferencesIssue(c *Commit) bool {
+	if issueRef.MatchString(c.Message) {
+		return true
+	}
+	for _, key := range issueTrailers {
+		if len(c.Trailers(key)) > 0 {
+			return true
+		}
+	}
+	return false
+}
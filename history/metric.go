@@ -0,0 +1,150 @@
+package history
+
+import (
+	"path"
+	"time"
+)
+
+func init() {
+	Register(func() Metric { return &commitCount{} })
+	Register(func() Metric { return NewActiveContributors("active-30d", 30*24*time.Hour) })
+	Register(func() Metric { return NewActiveContributors("active-90d", 90*24*time.Hour) })
+	Register(func() Metric { return NewPathMetric("ci", CIPaths...) })
+	Register(func() Metric { return &largeFiles{} })
+}
+
+// largeFiles counts the changes to large files and LFS pointers.
+type largeFiles struct {
+	n int
+}
+
+func (m *largeFiles) Name() string { return "large-files" }
+
+func (m *largeFiles) Accumulate(c *Commit) {
+	for _, f := range c.Files {
+		if f.Size > 0 || f.LFS {
+			m.n++
+		}
+	}
+}
+
+func (m *largeFiles) Finalize() float64 {
+	v := float64(m.n)
+	m.n = 0
+	return v
+}
+
+// CIPaths are the patterns of CI and build files counted by the ci
+// metric. NewPathMetric counts other patterns under the same name.
+var CIPaths = []string{
+	".github/workflows/*", ".gitlab-ci.yml", ".travis.yml", ".circleci/*",
+	"azure-pipelines.yml", "appveyor.yml", ".drone.yml", "Jenkinsfile",
+	"Makefile", "*.mk", "CMakeLists.txt", "*.cmake", "meson.build",
+	"BUILD", "BUILD.bazel", "WORKSPACE", "build.gradle", "pom.xml",
+	"Dockerfile",
+}
+
+// commitCount counts commits.
+type commitCount struct {
+	n int
+}
+
+func (m *commitCount) Name() string         { return "commits" }
+func (m *commitCount) Accumulate(c *Commit) { m.n++ }
+func (m *commitCount) Finalize() float64 {
+	v := float64(m.n)
+	m.n = 0
+	return v
+}
+
+// NewPathMetric returns a metric that counts commits touching a file that
+// matches any of the patterns. Patterns use path.Match syntax and are
+// matched against both the full path and the base name, so "*.proto"
+// matches in any directory.
+func NewPathMetric(name string, patterns ...string) Metric {
+	return &pathMetric{name: name, patterns: patterns}
+}
+
+type pathMetric struct {
+	name     string
+	patterns []string
+	n        int
+}
+
+func (m *pathMetric) Name() string { return m.name }
+
+func (m *pathMetric) Accumulate(c *Commit) {
+	for _, f := range c.Files {
+		if MatchPath(m.patterns, f.Name) {
+			m.n++
+			return
+		}
+	}
+}
+
+func (m *pathMetric) Finalize() float64 {
+	v := float64(m.n)
+	m.n = 0
+	return v
+}
+
+// NewActiveContributors returns a metric that counts the distinct authors
+// with a commit in the span before the last commit of each window, or
+// before the end of a window without commits. Unlike other metrics it
+// remembers authors across windows, so it must see the windows in order,
+// as Series does.
+func NewActiveContributors(name string, span time.Duration) Metric {
+	return &activeContributors{name: name, span: span, last: map[string]time.Time{}}
+}
+
+type activeContributors struct {
+	name string
+	span time.Duration
+	last map[string]time.Time // Latest commit of each author.
+	end  time.Time            // Latest commit of the window.
+}
+
+func (m *activeContributors) Name() string { return m.name }
+
+func (m *activeContributors) Accumulate(c *Commit) {
+	for _, p := range c.Authors() {
+		if key := p.Key(); c.When.After(m.last[key]) {
+			m.last[key] = c.When
+		}
+	}
+	if c.When.After(m.end) {
+		m.end = c.When
+	}
+}
+
+func (m *activeContributors) Idle(end time.Time) float64 {
+	m.end = end
+	return m.Finalize()
+}
+
+func (m *activeContributors) Finalize() float64 {
+	since := m.end.Add(-m.span)
+	n := 0
+	for key, t := range m.last {
+		if t.After(since) {
+			n++
+			continue
+		}
+		delete(m.last, key)
+	}
+	return float64(n)
+}
+
+// MatchPath reports if name, or its base name, matches any of the patterns.
+func MatchPath(patterns []string, name string) bool {
+	base := path.Base(name)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package history
+
+func init() {
+	Register(func() Metric {
+		return NewShare("signed", func(c *Commit) bool { return c.Signed })
+	})
+}
+
+// NewCount returns a metric of the number of commits in each window for
+// which match is true.
+func NewCount(name string, match func(c *Commit) bool) Metric {
+	return &count{name: name, match: match}
+}
+
+type count struct {
+	name  string
+	match func(c *Commit) bool
+	n     int
+}
+
+func (m *count) Name() string { return m.name }
+
+func (m *count) Accumulate(c *Commit) {
+	if m.match(c) {
+		m.n++
+	}
+}
+
+func (m *count) Finalize() float64 {
+	v := float64(m.n)
+	m.n = 0
+	return v
+}
+
+// NewShare returns a metric of the percentage of commits in each window
+// for which match is true.
+func NewShare(name string, match func(c *Commit) bool) Metric {
+	return &share{name: name, match: match}
+}
+
+type share struct {
+	name  string
+	match func(c *Commit) bool
+	n     int
+	total int
+}
+
+func (m *share) Name() string { return m.name }
+func (m *share) Ratio() bool  { return true }
+
+func (m *share) Accumulate(c *Commit) {
+	m.total++
+	if m.match(c) {
+		m.n++
+	}
+}
+
+func (m *share) Finalize() float64 {
+	var v float64
+	if m.total > 0 {
+		v = 100 * float64(m.n) / float64(m.total)
+	}
+	m.n, m.total = 0, 0
+	return v
+}
+
+// NewAverage returns a metric of the mean of value over the commits of
+// each window.
+func NewAverage(name string, value func(c *Commit) float64) Metric {
+	return &average{name: name, value: value}
+}
+
+type average struct {
+	name  string
+	value func(c *Commit) float64
+	sum   float64
+	n     int
+}
+
+func (m *average) Name() string { return m.name }
+func (m *average) Ratio() bool  { return true }
+
+func (m *average) Accumulate(c *Commit) {
+	m.sum += m.value(c)
+	m.n++
+}
+
+func (m *average) Finalize() float64 {
+	var v float64
+	if m.n > 0 {
+		v = m.sum / float64(m.n)
+	}
+	m.sum, m.n = 0, 0
+	return v
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// hooks are shell commands run for each repository. Pre runs before the
+// repository is fetched, Post after its charts are rendered.
+type hooks struct {
+	Pre  string `json:",omitempty"`
+	Post string `json:",omitempty"`
+}
+
+// hookEnv is added to the environment of hook commands.
+type hookEnv struct {
+	URL    string
+	Name   string
+	Slug   string
+	Charts []string
+	Change *change
+}
+
+func (e hookEnv) environ() []string {
+	env := append(os.Environ(),
+		"REPO_URL="+e.URL,
+		"REPO_NAME="+e.Name,
+		"CACHE_PATH="+shardPath(e.URL),
+	)
+	if len(e.Slug) > 0 {
+		env = append(env, "REPO_SLUG="+e.Slug)
+	}
+	if len(e.Charts) > 0 {
+		env = append(env,
+			"CHART_PATH="+e.Charts[0],
+			"CHART_PATHS="+strings.Join(e.Charts, string(os.PathListSeparator)),
+		)
+	}
+	if e.Change != nil {
+		env = append(env,
+			"REPO_CHANGE="+e.Change.Kind,
+			"REPO_CHANGE_MESSAGE="+e.Change.Message,
+		)
+	}
+	return env
+}
+
+// runHook runs command with the system shell.
+func runHook(ctx context.Context, kind, command string, env hookEnv) error {
+	if len(command) == 0 {
+		return nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = env.environ()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s hook: %w", kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// exportInflux writes weekly metric values in InfluxDB line protocol. If out
+// is an http or https URL the points are posted to it, such as
+// http://localhost:8086/api/v2/write?org=o&bucket=b. INFLUX_TOKEN is sent as
+// the authorization token when set.
+func exportInflux(ctx context.Context, d *exportData, out string) error {
+	var buf bytes.Buffer
+	for _, u := range d.Charts.urls() {
+		ch := d.Charts[u]
+		for _, metric := range d.Metrics {
+			for _, pt := range history.Series(ch.Commits, history.Weekly, ch.metric(metric)) {
+				fmt.Fprintf(&buf, "gitgraph,repo=%s,metric=%s value=%s %d\n",
+					escapeTag(d.Slugs[u]),
+					escapeTag(metric),
+					strconv.FormatFloat(pt.Value, 'f', -1, 64),
+					pt.Time.UnixNano(),
+				)
+			}
+		}
+	}
+	if !strings.HasPrefix(out, "http://") && !strings.HasPrefix(out, "https://") {
+		return writeFileAtomic(out, func(w io.Writer) error {
+			_, err := w.Write(buf.Bytes())
+			return err
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, out, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token := credential("INFLUX_TOKEN"); len(token) > 0 {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("post %s: %s: %s", out, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+var tagEscaper = strings.NewReplacer(
+	",", "\\,",
+	"=", "\\=",
+	" ", "\\ ",
+)
+
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
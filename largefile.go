@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/kardianos/gitgraph/history"
+)
+
+var largeFileSize = flag.Int64("large-file", 5<<20, "record the size of changed files of at least this many bytes when fetching, to track large files")
+
+// lfsPointer starts the content of a Git LFS pointer file.
+const lfsPointer = "version https://git-lfs.github.com/spec/v1"
+
+// largeFiles sets the size of the large files of hc, and marks LFS
+// pointers, reading the files from the tree of c. Pointers are small and
+// change in at most three lines, so only such files are read.
+func largeFiles(hc *history.Commit, c *object.Commit) error {
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+	for i := range hc.Files {
+		hf := &hc.Files[i]
+		f, err := tree.File(hf.Name)
+		if err != nil {
+			// Deleted by the commit.
+			continue
+		}
+		if f.Size >= *largeFileSize {
+			hf.Size = f.Size
+			continue
+		}
+		if f.Size > 1024 || hf.Add > 3 {
+			continue
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		hf.LFS = strings.HasPrefix(content, lfsPointer)
+	}
+	return nil
+}
+
+// largeFilesCLI sets the sizes of the large files of commits and marks
+// LFS pointers, as largeFiles does, for the repository in dir: git log
+// --raw gives the blob each commit wrote to each path, git cat-file their
+// sizes and the content of the small ones.
+func largeFilesCLI(ctx context.Context, dir string, commits []history.Commit) error {
+	blobs, err := changedBlobsCLI(ctx, dir)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, byPath := range blobs {
+		for _, id := range byPath {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	out, err := catFileCLI(ctx, dir, "--batch-check=%(objectname) %(objectsize)", ids)
+	if err != nil {
+		return err
+	}
+	sizes := make(map[string]int64, len(ids))
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			// "<id> missing", such as blobs a blobless clone did not fetch.
+			continue
+		}
+		n, err := strconv.ParseInt(f[1], 10, 64)
+		if err == nil {
+			sizes[f[0]] = n
+		}
+	}
+
+	// Pointers are small and change in at most three lines, so only such
+	// files are read.
+	var small []string
+	pointers := map[string][]*history.File{}
+	for i := range commits {
+		byPath := blobs[commits[i].Hash]
+		for j := range commits[i].Files {
+			hf := &commits[i].Files[j]
+			hf.Size, hf.LFS = 0, false
+			id := byPath[hf.Name]
+			size, ok := sizes[id]
+			switch {
+			case !ok:
+			case size >= *largeFileSize:
+				hf.Size = size
+			case size <= 1024 && hf.Add <= 3:
+				if pointers[id] == nil {
+					small = append(small, id)
+				}
+				pointers[id] = append(pointers[id], hf)
+			}
+		}
+	}
+	if len(small) == 0 {
+		return nil
+	}
+	out, err = catFileCLI(ctx, dir, "--batch", small)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f := strings.Fields(header)
+		if len(f) != 3 {
+			continue
+		}
+		n, err := strconv.Atoi(f[2])
+		if err != nil {
+			return fmt.Errorf("git cat-file: %q", header)
+		}
+		content := make([]byte, n+1) // With the newline after it.
+		_, err = io.ReadFull(r, content)
+		if err != nil {
+			return fmt.Errorf("git cat-file: %w", err)
+		}
+		if bytes.HasPrefix(content, []byte(lfsPointer)) {
+			for _, hf := range pointers[f[0]] {
+				hf.LFS = true
+			}
+		}
+	}
+}
+
+// changedBlobsCLI returns the blob each commit of HEAD in dir wrote to
+// each path it changed, by commit hash and path. Merges, deletions and
+// submodules have none.
+func changedBlobsCLI(ctx context.Context, dir string) (map[string]map[string]string, error) {
+	cmd := gitCommand(ctx, "-C", dir, "log", "--raw", "--no-renames", "--no-abbrev", "--format=%H", "HEAD")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	blobs := map[string]map[string]string{}
+	var current map[string]string
+	sc := bufio.NewScanner(out)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case isHash(line):
+			current = map[string]string{}
+			blobs[line] = current
+		case strings.HasPrefix(line, ":") && current != nil:
+			// :100644 100644 <old> <new> M<tab>path
+			tab := strings.IndexByte(line, '\t')
+			if tab < 0 {
+				continue
+			}
+			f := strings.Fields(line[:tab])
+			if len(f) < 5 || f[1] == "160000" || strings.Trim(f[3], "0") == "" {
+				continue
+			}
+			current[line[tab+1:]] = f[3]
+		}
+	}
+	serr := sc.Err()
+	err = cmd.Wait()
+	if serr != nil {
+		return nil, serr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("git log --raw: %w", err)
+	}
+	return blobs, nil
+}
+
+// catFileCLI runs git cat-file in mode on the objects ids of the
+// repository in dir and returns its output.
+func catFileCLI(ctx context.Context, dir, mode string, ids []string) ([]byte, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	cmd := gitCommand(ctx, "-C", dir, "cat-file", mode)
+	cmd.Stdin = strings.NewReader(strings.Join(ids, "\n") + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return out, nil
+}
+
+// largeFile is a large or LFS file, as first added to the repository.
+type largeFile struct {
+	Path string
+	Size int64 // Largest size seen, zero for LFS pointers.
+	LFS  bool
+	When time.Time // First commit with the file.
+	Hash string
+}
+
+// MiB formats the size in mebibytes.
+func (lf largeFile) MiB() string {
+	return strconv.FormatFloat(float64(lf.Size)/(1<<20), 'f', 1, 64)
+}
+
+// largeFileList returns the large and LFS files of commits, each by its
+// first appearance, largest first and LFS pointers last.
+func largeFileList(commits []history.Commit) []largeFile {
+	byPath := map[string]*largeFile{}
+	for _, c := range commits {
+		for _, f := range c.Files {
+			if f.Size == 0 && !f.LFS {
+				continue
+			}
+			lf := byPath[f.Name]
+			if lf == nil {
+				lf = &largeFile{Path: f.Name, When: c.When, Hash: c.Hash}
+				byPath[f.Name] = lf
+			}
+			if c.When.Before(lf.When) {
+				lf.When, lf.Hash = c.When, c.Hash
+			}
+			if f.Size > lf.Size {
+				lf.Size = f.Size
+			}
+			lf.LFS = lf.LFS || f.LFS
+		}
+	}
+	list := make([]largeFile, 0, len(byPath))
+	for _, lf := range byPath {
+		list = append(list, *lf)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Path < b.Path
+	})
+	return list
+}
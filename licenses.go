@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+// licenses returns the license of each path pattern of u, those of the
+// repository replacing the global ones.
+func (cfg *config) licenses(u string) map[string]string {
+	if rc := cfg.Repos[u]; rc != nil && len(rc.Licenses) > 0 {
+		return rc.Licenses
+	}
+	return cfg.Licenses
+}
+
+// checkLicenses validates the patterns of a Licenses map.
+func checkLicenses(where string, licenses map[string]string) error {
+	for p, l := range licenses {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil {
+			return fmt.Errorf("%s: Licenses %q: %w", where, p, err)
+		}
+		if len(l) == 0 {
+			return fmt.Errorf("%s: Licenses %q: no license", where, p)
+		}
+	}
+	return nil
+}
+
+// licenseOf returns the license of the file name, or "" if no pattern of
+// licenses matches it. A pattern ending in / matches the files under that
+// directory, others match as metric paths do. The longest matching
+// pattern wins, so a subtree can differ from the rest of the repository.
+func licenseOf(licenses map[string]string, name string) string {
+	var best, license string
+	for p, l := range licenses {
+		var ok bool
+		if strings.HasSuffix(p, "/") {
+			ok = strings.HasPrefix(name, p)
+		} else {
+			ok = history.MatchPath([]string{p}, name)
+		}
+		if ok && (len(p) > len(best) || (len(p) == len(best) && p < best)) {
+			best, license = p, l
+		}
+	}
+	return license
+}
+
+func licenseFilename(slug string) string {
+	return slug + "--licenses.png"
+}
+
+// displayLicenses charts the commits of ch touching the files of each
+// license, a line per license. A commit touching files of two licenses
+// counts for both.
+func displayLicenses(ch *chart, opt chartOptions, filename string) error {
+	wname, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	byLicense := map[string]*chart{}
+	for _, c := range history.ExcludeFiles(ch.Commits, ch.excluded()) {
+		seen := map[string]bool{}
+		for _, f := range c.Files {
+			l := licenseOf(ch.licenses, f.Name)
+			if len(l) == 0 || seen[l] {
+				continue
+			}
+			seen[l] = true
+			sub := byLicense[l]
+			if sub == nil {
+				sub = &chart{Name: ch.Name}
+				byLicense[l] = sub
+			}
+			sub.Commits = append(sub.Commits, c)
+		}
+	}
+	names := make([]string, 0, len(byLicense))
+	for l := range byLicense {
+		names = append(names, l)
+	}
+	sort.Strings(names)
+
+	now := renderTime()
+	p := plot.New()
+	addProvenance(p, ch)
+	p.Title.Text, err = chartTitle(ch, "licenses", opt, ch.Name+" (commits by license)")
+	if err != nil {
+		return err
+	}
+	p.Y.Label.Text = fmt.Sprintf("commits (%s)", wname)
+	p.X.Tick.Marker = plot.TimeTicks{Format: opt.loc.DateFormat}
+	p.Add(plotter.NewGrid())
+	p.Legend.Top = true
+	p.Legend.Left = true
+	for i, l := range names {
+		data, err := seriesPoints(byLicense[l], "commits", w, opt.cal, now)
+		if err != nil {
+			return err
+		}
+		line, err := plotter.NewLine(data)
+		if err != nil {
+			return err
+		}
+		st := palette[i%len(palette)]
+		line.Color = st.Color
+		line.Dashes = st.Dashes
+		p.Add(line)
+		p.Legend.Add(fmt.Sprintf("%s (%d)", l, len(byLicense[l].Commits)), line)
+	}
+	p.Y.Min = 0
+	return savePlot(p, filename)
+}
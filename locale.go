@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font/opentype"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+)
+
+type locale struct {
+	DateFormat string
+	YLabel     string
+	// Weekly formats the axis label of other metrics.
+	Weekly string
+	// Window formats the axis label of a metric and a window other than
+	// weekly, and Business that of a rate per business day.
+	Window   string
+	Business string
+}
+
+var locales = map[string]locale{
+	"en": {
+		DateFormat: "2006-01-02",
+		YLabel:     "Number of Commits (weekly)",
+		Weekly:     "%s (weekly)",
+		Window:     "%s (%s)",
+		Business:   "%s per business day (%s)",
+	},
+	"de": {
+		DateFormat: "02.01.2006",
+		YLabel:     "Anzahl der Commits (wöchentlich)",
+		Weekly:     "%s (wöchentlich)",
+		Window:     "%s (%s)",
+		Business:   "%s pro Arbeitstag (%s)",
+	},
+	"es": {
+		DateFormat: "02/01/2006",
+		YLabel:     "Número de commits (semanal)",
+		Weekly:     "%s (semanal)",
+		Window:     "%s (%s)",
+		Business:   "%s por día hábil (%s)",
+	},
+	"fr": {
+		DateFormat: "02/01/2006",
+		YLabel:     "Nombre de commits (hebdomadaire)",
+		Weekly:     "%s (hebdomadaire)",
+		Window:     "%s (%s)",
+		Business:   "%s par jour ouvré (%s)",
+	},
+	"ru": {
+		DateFormat: "02.01.2006",
+		YLabel:     "Количество коммитов (в неделю)",
+		Weekly:     "%s (в неделю)",
+		Window:     "%s (%s)",
+		Business:   "%s за рабочий день (%s)",
+	},
+	"ja": {
+		DateFormat: "2006年01月02日",
+		YLabel:     "コミット数（週ごと）",
+		Weekly:     "%s（週ごと）",
+		Window:     "%s（%s）",
+		Business:   "%s（営業日あたり、%s）",
+	},
+	"zh": {
+		DateFormat: "2006年01月02日",
+		YLabel:     "提交次数（每周）",
+		Weekly:     "%s（每周）",
+		Window:     "%s（%s）",
+		Business:   "%s（每个工作日，%s）",
+	},
+}
+
+// lookupLocale accepts names such as "de", "zh-CN" or "zh_CN.UTF-8".
+func lookupLocale(name string) (locale, error) {
+	key := strings.ToLower(name)
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		key = key[:i]
+	}
+	key = strings.ReplaceAll(key, "_", "-")
+	if l, ok := locales[key]; ok {
+		return l, nil
+	}
+	if i := strings.IndexByte(key, '-'); i >= 0 {
+		if l, ok := locales[key[:i]]; ok {
+			return l, nil
+		}
+	}
+	return locale{}, fmt.Errorf("unknown locale %q", name)
+}
+
+const customTypeface font.Typeface = "Custom"
+
+// loadFont registers a TrueType or OpenType font and makes it the default
+// for all chart text. The built-in fonts have no CJK glyphs.
+func loadFont(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	f, err := opentype.Parse(b)
+	if err != nil {
+		return fmt.Errorf("parse font %q: %w", filename, err)
+	}
+	fnt := font.Font{Typeface: customTypeface}
+	font.DefaultCache.Add(font.Collection{{Font: fnt, Face: f}})
+	plot.DefaultFont = fnt
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package main
+
+import "os"
+
+// Advisory locks are not available; concurrent runs are not guarded.
+
+func lockFile(f *os.File) error    { return nil }
+func tryLockFile(f *os.File) error { return nil }
+func unlockFile(f *os.File) error  { return nil }
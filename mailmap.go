@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+var mailmapFile = flag.String("mailmap", "", "file in git .mailmap format mapping the names and emails of commits to one identity per person; overrides the config Mailmap")
+
+// mailmap maps the names and emails of commits to canonical ones, as git
+// does with .mailmap.
+type mailmap struct {
+	byEmail     map[string]history.Person
+	byNameEmail map[string]history.Person
+}
+
+// loadMailmap reads the -mailmap file, else the config Mailmap relative to
+// the config file in dir. Without either every identity is kept.
+func (cfg *config) loadMailmap(dir string) (*mailmap, error) {
+	fn := *mailmapFile
+	if len(fn) == 0 && len(cfg.Mailmap) > 0 {
+		fn = cfg.Mailmap
+		if !filepath.IsAbs(fn) {
+			fn = filepath.Join(dir, fn)
+		}
+	}
+	mm := &mailmap{byEmail: map[string]history.Person{}, byNameEmail: map[string]history.Person{}}
+	if len(fn) == 0 {
+		return mm, nil
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	err = mm.parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	return mm, nil
+}
+
+// parse reads lines of the forms
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+func (mm *mailmap) parse(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	n := 0
+	for s.Scan() {
+		n++
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		var names, emails []string
+		for len(line) > 0 {
+			i := strings.IndexByte(line, '<')
+			j := strings.IndexByte(line, '>')
+			if i < 0 || j < i {
+				break
+			}
+			names = append(names, strings.TrimSpace(line[:i]))
+			emails = append(emails, strings.TrimSpace(line[i+1:j]))
+			line = line[j+1:]
+		}
+		switch len(emails) {
+		case 1:
+			mm.byEmail[strings.ToLower(emails[0])] = history.Person{Name: names[0]}
+		case 2:
+			proper := history.Person{Name: names[0], Email: emails[0]}
+			if len(names[1]) == 0 {
+				mm.byEmail[strings.ToLower(emails[1])] = proper
+			} else {
+				mm.byNameEmail[strings.ToLower(names[1])+"\x00"+strings.ToLower(emails[1])] = proper
+			}
+		default:
+			return fmt.Errorf("line %d: want one or two emails in <>", n)
+		}
+	}
+	return s.Err()
+}
+
+// resolve returns the canonical identity of p.
+func (mm *mailmap) resolve(p history.Person) history.Person {
+	email := strings.ToLower(p.Email)
+	m, ok := mm.byNameEmail[strings.ToLower(p.Name)+"\x00"+email]
+	if !ok {
+		m, ok = mm.byEmail[email]
+	}
+	if !ok {
+		return p
+	}
+	if len(m.Name) > 0 {
+		p.Name = m.Name
+	}
+	if len(m.Email) > 0 {
+		p.Email = m.Email
+	}
+	return p
+}
@@ -2,183 +2,773 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"image/color"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/kardianos/gitgraph/history"
 	"github.com/kardianos/task"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
-	"gonum.org/v1/plot/vg"
-	"gonum.org/v1/plot/vg/draw"
 )
 
+var (
+	fontFile     = flag.String("font", "", "TrueType font file for chart text, required for CJK names")
+	localeName   = flag.String("locale", "en", "locale for chart dates and axis labels")
+	dateFormat   = flag.String("date-format", "", "override the locale date format, in Go time layout")
+	configFile   = flag.String("config", "gitgraph.json", "config file; the built-in repository list is used if missing")
+	refresh      = flag.String("refresh", "", "comma separated repository names or URLs to fetch even if cached, or \"all\"")
+	templates    = flag.String("templates", "", "directory with index.html and repo.html templates replacing the built-in ones")
+	metrics      = flag.String("metrics", "commits", "comma separated metrics to chart, or \"all\"")
+	windowName   = flag.String("window", "weekly", "period each chart point covers: "+strings.Join(history.WindowNames(), ", "))
+	summaryOut   = flag.String("summary", "", "write a JSON run summary to this file, or \"-\" for stdout")
+	autoPrune    = flag.Bool("prune", false, "after the run, remove cached data and output files of repositories no longer in the config")
+	offline      = flag.Bool("offline", false, "render from the cache only, without any network access; repositories that are not cached fail")
+	baselineRepo = flag.String("baseline", "", "repository URL or name drawn faintly behind every other chart; overrides the config Baseline")
+	ttl          duration
+)
+
+func init() {
+	flag.Var(&ttl, "ttl", "re-fetch cached commits older than this, such as 24h or 7d; overrides the config TTL")
+}
+
+var commands = map[string]func(ctx context.Context) error{
+	"run":        run,
+	"fetch":      fetchCommand,
+	"serve":      serve,
+	"import":     importLog,
+	"export":     export,
+	"email":      email,
+	"mirror":     updateMirrors,
+	"cache":      cacheCommand,
+	"tui":        tui,
+	"action":     action,
+	"archive":    archive,
+	"author":     authorTimeline,
+	"restore":    restore,
+	"coordinate": coordinate,
+	"work":       work,
+	"passwd":     passwd,
+}
+
 func main() {
-	err := task.Start(context.Background(), time.Second*3, run)
+	flag.Usage = usage
+	flag.Parse()
+	err := flagsFromEnv(flag.CommandLine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	if len(name) == 0 {
+		name = "run"
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+	err = task.Start(context.Background(), *grace, cmd)
 	if err != nil {
+		var ee exitError
+		if errors.As(err, &ee) {
+			log.Print(err)
+			os.Exit(ee.code)
+		}
 		log.Fatal(err)
 	}
 }
 
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: %s [flags] [command]\n\ncommands:\n", filepath.Base(os.Args[0]))
+	fmt.Fprintln(out, "  run [URL... | -]     fetch repositories and render charts (default)")
+	fmt.Fprintln(out, "  fetch [URL... | -]   fetch repositories into the cache without rendering")
+	fmt.Fprintln(out, "  serve                serve the output directory and JSON API; with -interval, also run periodically")
+	fmt.Fprintln(out, "  import URL [FILE]    read git log output into the cache")
+	fmt.Fprintln(out, "  export FORMAT [FILE] write cached data as", strings.Join(exportFormats(), ", "))
+	fmt.Fprintln(out, "  email                send the digest email now")
+	fmt.Fprintln(out, "  mirror [URL... | -]  fetch the mirrors of all repositories")
+	fmt.Fprintln(out, "  tui                  browse repositories in the terminal")
+	fmt.Fprintln(out, "  action               run inside a GitHub Actions job, reading its inputs")
+	fmt.Fprintln(out, "  author EMAIL|NAME    chart the commits of one person across all repositories")
+	fmt.Fprintln(out, "  archive [DIR]        write compressed archives of the cached commits")
+	fmt.Fprintln(out, "  restore [DIR]        read archives back into the cache")
+	fmt.Fprintln(out, "  coordinate [URL...]  hand the due repositories to workers sharing the -store")
+	fmt.Fprintln(out, "  work URL             fetch the repositories the coordinator at URL hands out")
+	fmt.Fprintln(out, "  cache verify         check the cache; -repair fixes or quarantines bad entries")
+	fmt.Fprintln(out, "  cache prune          remove data of repositories no longer in the config; -n lists only")
+	fmt.Fprintln(out, "  passwd               print the Auth Users hash of the password read from stdin")
+	fmt.Fprintln(out, "\nflags, also set by "+envPrefix+"NAME, such as "+envName("cache-dir")+":")
+	flag.PrintDefaults()
+}
+
 type chart struct {
 	Name    string
-	Commits []time.Time
+	Fetched time.Time
+	// Ref is the branch analyzed, the default branch of the remote.
+	Ref string `json:",omitempty"`
+	// Tip is the commit of Ref the commits were read from, and Tool the
+	// version of gitgraph that read them.
+	Tip  string `json:",omitempty"`
+	Tool string `json:",omitempty"`
+	// Truncated is the time of the oldest commit if the history stops
+	// there because the upstream is shallow or grafted.
+	Truncated time.Time `json:",omitempty"`
+	Commits   []history.Commit
+	// Generated are the patterns of files marked generated or vendored
+	// in .gitattributes.
+	Generated []string `json:",omitempty"`
+	// Branches are the branches other than the default one.
+	Branches []branch `json:",omitempty"`
+	// Backports are the cherry-picks between the configured release
+	// branches and the default one.
+	Backports []backport `json:",omitempty"`
+	// LargeFile is the -large-file the sizes of the files of Commits were
+	// recorded with, zero for caches from before sizes were recorded.
+	LargeFile int64 `json:",omitempty"`
+
+	// ignore are the patterns of files the config leaves out of the
+	// metrics of this repository.
+	ignore []string
+	// licenses maps path patterns to licenses for the license chart.
+	licenses map[string]string
+	// newMetric, if set, returns the metrics as configured for this
+	// repository.
+	newMetric func(name string) history.Metric
+}
+
+// metric returns a new instance of the named metric for ch, or nil if
+// there is no such metric.
+func (ch *chart) metric(name string) history.Metric {
+	if ch.newMetric == nil {
+		return history.New(name)
+	}
+	return ch.newMetric(name)
 }
 
-const (
-	dataFilename = "data.js"
-	cacheDir     = "cache"
-	outputDir    = "output"
+const dataFilename = "data.js" // Single file cache of older versions.
+
+var (
+	cacheDir  = "cache"
+	outputDir = "output"
 )
 
-var loadFrom = filepath.Join(cacheDir, dataFilename)
+func init() {
+	flag.StringVar(&cacheDir, "cache-dir", cacheDir, "directory of the cache and mirrors")
+	flag.StringVar(&outputDir, "output-dir", outputDir, "directory the charts and report are written to")
+}
 
-type FileType map[string]*chart
+// loadFrom is the single file cache of older versions.
+func loadFrom() string { return filepath.Join(cacheDir, dataFilename) }
 
-func (ft FileType) Load(location string) error {
-	f, err := os.Open(location)
+func run(ctx context.Context) error {
+	sum := newSummary()
+	err := runSummary(ctx, sum)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		sum.Errors = append(sum.Errors, err.Error())
+	}
+	setLastSummary(sum)
+	if len(*summaryOut) > 0 {
+		serr := sum.write(*summaryOut)
+		if err == nil {
+			err = serr
+		}
+	}
+	return runStatus(sum, err)
+}
+
+// runStatus returns the error the run ends with: err, else failed
+// repositories, else an exitError when alerts were raised.
+func runStatus(sum *summary, err error) error {
+	if err == nil {
+		if n := sum.failed(); n > 0 {
+			err = fmt.Errorf("%d of %d repositories failed", n, len(sum.Repos))
 		}
-		return err
 	}
-	defer f.Close()
+	if err == nil && len(sum.Alerts) > 0 {
+		err = exitError{code: 3, err: fmt.Errorf("%d alerts raised", len(sum.Alerts))}
+	}
+	return err
+}
 
-	d := json.NewDecoder(f)
-	store := FileType{}
-	err = d.Decode(&store)
+// exitError sets the exit status of the program.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string { return e.err.Error() }
+
+func runSummary(ctx context.Context, sum *summary) error {
+	loc, err := lookupLocale(*localeName)
 	if err != nil {
 		return err
 	}
-	for key, ch := range ft {
-		v, ok := store[key]
-		if !ok {
-			continue
+	if len(*dateFormat) > 0 {
+		loc.DateFormat = *dateFormat
+	}
+	if len(*fontFile) > 0 {
+		err = loadFont(*fontFile)
+		if err != nil {
+			return err
 		}
-		ch.Commits = v.Commits
 	}
-	return nil
-}
-func (ft FileType) Save(location string) error {
-	f, err := os.Create(location)
+	cfg, err := loadConfig(*configFile)
 	if err != nil {
 		return err
 	}
-	c := json.NewEncoder(f)
-	err = c.Encode(ft)
-	cerr := f.Close()
+	if ttl > 0 {
+		cfg.TTL = ttl
+	}
+	err = parseTitles(cfg.ChartTitle, cfg.ChartSubtitle)
 	if err != nil {
 		return err
 	}
-	if cerr != nil {
-		return cerr
+	err = loadWatermark(cfg.Watermark, filepath.Dir(*configFile))
+	if err != nil {
+		return err
+	}
+	err = usePalette(cfg.Palette)
+	if err != nil {
+		return err
+	}
+	err = cfg.selectRepos(commandArgs())
+	if err != nil {
+		return err
+	}
+	metricNames, err := cfg.metrics(splitList(*metrics))
+	if err != nil {
+		return err
+	}
+	duals, err := parseDualPairs(*dualPairs)
+	if err != nil {
+		return err
+	}
+	err = checkChartStyle(*styleFlag)
+	if err != nil {
+		return err
+	}
+	err = checkVegaMode(*vegaMode)
+	if err != nil {
+		return err
+	}
+	err = parseAsOf(*asOfFlag)
+	if err != nil {
+		return err
+	}
+	err = loadRenderCache(cfg)
+	if err != nil {
+		return err
+	}
+	if history.LookupWindow(*windowName) == nil {
+		return fmt.Errorf("unknown window %q, have %s", *windowName, strings.Join(history.WindowNames(), ", "))
+	}
+	notes, err := loadAnnotations(*annotationsFile)
+	if err != nil {
+		return err
+	}
+	cal, err := loadCalendar()
+	if err != nil {
+		return err
+	}
+	baselineURL, baseline, err := cfg.loadBaseline()
+	if err != nil {
+		return err
+	}
+	if len(*termChart) > 0 && *termChart != "blocks" && *termChart != "braille" {
+		return fmt.Errorf("unknown -term %q, use blocks or braille", *termChart)
+	}
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	ix, err := openIndex()
+	if err == nil && !*offline {
+		err = fetch(ctx, cfg, ix, sum)
+	}
+	uerr := unlockCache(lock)
+	if err != nil {
+		return err
+	}
+	if uerr != nil {
+		return uerr
 	}
-	return nil
-}
 
-var urlLookup = FileType{
-	"https://github.com/linuxdeepin/dde-daemon": {
-		Name: "DDE Daemon",
-	},
-	"https://github.com/linuxdeepin/dde-dock": {
-		Name: "DDE Dock",
-	},
-	"https://github.com/linuxdeepin/dde-session-shell": {
-		Name: "DDE Session Shell",
-	},
-}
+	// A run -as-of a past date is not compared with the previous run, nor
+	// becomes the previous run of the next.
+	var prev aggregates
+	if asOf.IsZero() {
+		prev, err = readAggregates(previousPath())
+		if err != nil {
+			return err
+		}
+	}
+	agg := newAggregates(renderTime())
 
-func run(ctx context.Context) error {
-	err := urlLookup.Load(loadFrom)
+	st, err := stageOutput()
+	if err != nil {
+		return err
+	}
+	defer st.close()
+	err = os.MkdirAll(outputDir, 0777)
+	if err != nil {
+		return err
+	}
+	urls := cfg.urls()
+	slugs := cfg.slugs()
+	manifest := make([]manifestEntry, 0, len(urls))
+	rep := &report{
+		Title:     cfg.Title,
+		Generated: time.Now(),
+	}
+	var hm *heatmap
+	if *heatmapMonths > 0 {
+		hm = newHeatmap(renderTime(), *heatmapMonths)
+	}
+	var co *correlation
+	if *correlateWeeks > 0 {
+		co = newCorrelation(renderTime(), *correlateWeeks)
+	}
+	shared, err := sharedYMax(cfg, metricNames, cal, renderTime())
 	if err != nil {
 		return err
 	}
-	updated := false
-	for u, ch := range urlLookup {
-		if len(ch.Commits) > 0 {
+	var cb *combined
+	if *combinedCharts {
+		cb = newCombined(cfg.window(""), metricNames)
+	}
+	// Repositories are read from the cache one at a time.
+	for _, u := range urls {
+		ch := cfg.chart(u)
+		rs := sum.repo(u, ch.Name)
+		err = loadShard(u, ch)
+		if err != nil {
+			rs.fail(err)
+			continue
+		}
+		cutOff(ch)
+		if rep := cfg.sanitizeDates(ch, time.Now()); rep != nil {
+			rs.BadDates = rep
+			fmt.Printf("%s: %s\n", ch.Name, rep)
+		}
+		agg.add(u, ch)
+		c := agg.change(prev, u, *changeThreshold)
+		if c != nil {
+			rs.Change = c
+			fmt.Printf("%s: %s\n", ch.Name, c.Message)
+		}
+		for _, a := range cfg.checkAlerts(u, ch, agg.Time) {
+			fmt.Printf("alert %s: %s: %s\n", a.Rule, ch.Name, a.Message)
+			sum.Alerts = append(sum.Alerts, a)
+		}
+		if len(ch.Commits) == 0 {
+			if *offline {
+				rs.fail(errors.New("not cached; fetch it before running with -offline"))
+			}
 			continue
 		}
-		fmt.Println("clone", u)
-		r, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
-			URL: u,
+		rs.Commits = len(ch.Commits)
+		if hm != nil {
+			hm.add(ch.Name, ch.Commits)
+		}
+		if co != nil {
+			co.add(ch)
+		}
+		if len(*termChart) > 0 {
+			fmt.Println(termLine(ch, agg.Time))
+		}
+		slug := slugs[u]
+		if cb != nil {
+			err = cb.add(cfg, u, slug, ch, chartOptions{cal: cal})
+			if err != nil {
+				rs.fail(err)
+			}
+		}
+		rr := newReportRepo(u, slug, ch)
+		start := time.Now()
+		opt := chartOptions{
+			loc:        loc,
+			url:        u,
+			notes:      append(annotationsFor(notes, u, ch.Name), handoffAnnotations(rr)...),
+			baseline:   baseline,
+			cal:        cal,
+			window:     cfg.window(u),
+			style:      cfg.repoStyle(u, slug),
+			chartStyle: cfg.chartStyle(u),
+		}
+		if u == baselineURL {
+			opt.baseline = nil
+		}
+		files, paths := renderCharts(ch, slug, u, metricNames, duals, opt, shared[u], rr, rs.fail)
+		rs.Anomalies, err = recentAnomalies(ch, metricNames, opt)
+		if err != nil {
+			rs.fail(err)
+		}
+		rs.RenderSeconds = time.Since(start).Seconds()
+		rs.Charts = append(rs.Charts, files...)
+		if len(rr.Charts) > 0 {
+			rep.Repos = append(rep.Repos, rr)
+		}
+		manifest = append(manifest, manifestEntry{
+			URL:     u,
+			Name:    ch.Name,
+			Slug:    slug,
+			Files:   append(files, rr.Page),
+			Ref:     ch.Ref,
+			Tip:     ch.Tip,
+			Fetched: ch.Fetched,
+			Tool:    ch.Tool,
+		})
+		err = runHook(ctx, "post", cfg.hooks(u).Post, hookEnv{
+			URL:    u,
+			Name:   ch.Name,
+			Slug:   slug,
+			Charts: paths,
+			Change: c,
 		})
+		if err != nil {
+			rs.fail(err)
+		}
+	}
+	gopt := chartOptions{loc: loc, notes: notes, baseline: baseline, cal: cal, window: cfg.window("")}
+	manifest = append(manifest, renderGroups(cfg, metricNames, duals, gopt, slugs, rep, sum)...)
+	if hm != nil {
+		err = hm.render(filepath.Join(outputDir, heatmapFilename))
 		if err != nil {
 			return err
 		}
-		ref, err := r.Head()
+	}
+	if cb != nil {
+		files, err := cb.render(outputDir, loc)
 		if err != nil {
 			return err
 		}
-		cIter, err := r.Log(&git.LogOptions{From: ref.Hash()})
+		fmt.Println("combined charts:", strings.Join(files, ", "))
+		err = cb.writeHTML(outputDir, *templates, cfg.Title, rep.Generated)
 		if err != nil {
 			return err
 		}
-
-		err = cIter.ForEach(func(c *object.Commit) error {
-			ch.Commits = append(ch.Commits, c.Committer.When)
-			return nil
-		})
+		rep.Combined = combinedSlug + ".html"
+	}
+	if co != nil {
+		err = co.write(filepath.Join(outputDir, correlationCSV), filepath.Join(outputDir, correlationImage))
 		if err != nil {
 			return err
 		}
-		updated = true
 	}
-	if updated {
-		err = urlLookup.Save(loadFrom)
+	err = rep.write(outputDir, *templates)
+	if err != nil {
+		return err
+	}
+	if asOf.IsZero() {
+		err = agg.write(previousPath())
 		if err != nil {
 			return err
 		}
 	}
-
-	for _, ch := range urlLookup {
-		err = display(ch)
+	err = writeManifest(filepath.Join(outputDir, manifestFilename), manifest)
+	if err != nil {
+		return err
+	}
+	err = renders.save()
+	if err != nil {
+		return err
+	}
+	if *autoPrune && !cfg.partial {
+		err = pruneCache(cfg, false)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	err = st.publish()
+	if err != nil {
+		return err
+	}
+	if *offline || !asOf.IsZero() {
+		return nil
+	}
+	return notify(ctx, cfg.Webhook, cfg.Title, sum)
 }
 
-func display(ch *chart) error {
-	const GroupSize = 60 * 60 * 24 * 7
-	agg := map[int64]int64{}
-	now := time.Now()
+// flagSet reports whether the flag name was given, on the command line or
+// by its environment variable.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// list is a set of repository names or URLs given on the command line.
+type list []string
+
+func splitList(s string) list {
+	var l list
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (l list) match(u, name string) bool {
+	for _, v := range l {
+		if v == "all" || v == u || strings.EqualFold(v, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// chartFilename returns the file name of a metric chart. The commits chart
+// keeps the plain repository slug. Slugs never hold "--", so it separates
+// the slug from the metric and the other charts of the repository, and
+// the name of one repository cannot spell a chart of another.
+func chartFilename(slug, metric string) string {
+	if metric == "commits" {
+		return slug + ".png"
+	}
+	return slug + "--" + slugify(metric) + ".png"
+}
+
+// derivedCharts are the names the charts other than metric charts add to
+// the slug. Metrics may not take them.
+var derivedCharts = []string{"card", "branches", "licenses", "backports", "overlap", "delta", "yoy"}
+
+// chartOptions are the settings of a chart beyond its data.
+type chartOptions struct {
+	loc   locale
+	notes []annotation
+	// baseline is drawn faintly behind the chart for comparison.
+	baseline *chart
+	// cal limits the chart to business days if set.
+	cal *history.Calendar
+	// url is the repository charted, empty for groups.
+	url string
+	// window overrides -window if set.
+	window string
+	// style is the style of the line and points if its color is set.
+	style lineStyle
+	// chartStyle is line, bar, step or area; line if empty.
+	chartStyle string
+	// yMax is the top of a Y range shared with other charts, if set.
+	yMax float64
+}
+
+// lookupWindow returns the window the chart covers.
+func (opt chartOptions) lookupWindow() (string, history.Window, error) {
+	name := opt.window
+	if len(name) == 0 {
+		name = *windowName
+	}
+	w := history.LookupWindow(name)
+	if w == nil {
+		return "", nil, fmt.Errorf("unknown window %q, have %s", name, strings.Join(history.WindowNames(), ", "))
+	}
+	return name, w, nil
+}
 
-	for _, dt := range ch.Commits {
-		if now.Before(dt) {
+// renderCharts renders the charts of ch with the file name prefix slug:
+// each metric with its variants, then the -dual, -vega, -branches,
+// backport, license and -cards charts. sub is the subtitle of the card
+// and yMax the shared Y range of each metric. The charts are added to rr
+// and errors reported to fail. It returns the files written, relative to
+// the output directory, and the paths of the chart images.
+func renderCharts(ch *chart, slug, sub string, metricNames []string, duals []dualPair, base chartOptions, yMax map[string]float64, rr *reportRepo, fail func(error)) (files, paths []string) {
+	for _, metric := range metricNames {
+		fn := chartFilename(slug, metric)
+		opt := base
+		opt.yMax = yMax[metric]
+		err := renders.render(ch, filepath.Join(outputDir, fn), opt, func() error {
+			return display(ch, metric, opt, filepath.Join(outputDir, fn))
+		})
+		if err != nil {
+			fail(err)
 			continue
 		}
-		a := dt.Unix() / GroupSize
-		b := a * GroupSize
-		agg[b]++
+		files = append(files, fn)
+		paths = append(paths, filepath.Join(outputDir, fn))
+		rr.Charts = append(rr.Charts, reportChart{Metric: metric, File: fn})
+		for _, v := range chartVariants {
+			if !*v.enabled {
+				continue
+			}
+			fn := v.filename(slug, metric)
+			err = renders.render(ch, filepath.Join(outputDir, fn), opt, func() error {
+				return v.render(ch, metric, opt, filepath.Join(outputDir, fn))
+			})
+			if err != nil {
+				fail(err)
+				continue
+			}
+			files = append(files, fn)
+			paths = append(paths, filepath.Join(outputDir, fn))
+			rr.Charts = append(rr.Charts, reportChart{Metric: metric + " (" + v.label + ")", File: fn})
+		}
+		if len(*vegaMode) > 0 {
+			var dataFile string
+			if *vegaMode == "url" {
+				dataFile = vegaDataFilename(slug, metric)
+			}
+			vf, err := writeVega(ch, metric, opt, outputDir, vegaFilename(slug, metric), dataFile)
+			if err != nil {
+				fail(err)
+			} else {
+				files = append(files, vf...)
+			}
+		}
 	}
-	var maxY float64
-	data := make(plotter.XYs, 0, len(agg))
-	for x, y := range agg {
-		fy := float64(y)
-		if fy > maxY {
-			maxY = fy
+	for _, dp := range duals {
+		fn := dualFilename(slug, dp)
+		opt := chartOptions{loc: base.loc, url: base.url, cal: base.cal, window: base.window, style: base.style}
+		err := renders.render(ch, filepath.Join(outputDir, fn), opt, func() error {
+			return displayDual(ch, dp, opt, filepath.Join(outputDir, fn))
+		})
+		if err != nil {
+			fail(err)
+			continue
+		}
+		files = append(files, fn)
+		paths = append(paths, filepath.Join(outputDir, fn))
+		rr.Charts = append(rr.Charts, reportChart{Metric: dp.String(), File: fn})
+	}
+	if *branchCharts && len(ch.Branches) > 0 {
+		fn := branchFilename(slug)
+		opt := chartOptions{loc: base.loc, url: base.url, window: base.window}
+		err := renders.render(ch, filepath.Join(outputDir, fn), opt, func() error {
+			return displayBranches(ch, opt, filepath.Join(outputDir, fn))
+		})
+		if err != nil {
+			fail(err)
+		} else {
+			files = append(files, fn)
+			paths = append(paths, filepath.Join(outputDir, fn))
+			rr.Charts = append(rr.Charts, reportChart{Metric: "active branches", File: fn})
+		}
+	}
+	if len(ch.Backports) > 0 {
+		fn := backportFilename(slug)
+		opt := chartOptions{loc: base.loc, url: base.url, window: base.window}
+		err := renders.render(ch, filepath.Join(outputDir, fn), opt, func() error {
+			return displayBackports(ch, opt, filepath.Join(outputDir, fn))
+		})
+		if err != nil {
+			fail(err)
+		} else {
+			files = append(files, fn)
+			paths = append(paths, filepath.Join(outputDir, fn))
+			rr.Charts = append(rr.Charts, reportChart{Metric: "backports", File: fn})
+		}
+	}
+	if len(ch.licenses) > 0 {
+		fn := licenseFilename(slug)
+		opt := chartOptions{loc: base.loc, url: base.url, cal: base.cal, window: base.window}
+		err := renders.render(ch, filepath.Join(outputDir, fn), opt, func() error {
+			return displayLicenses(ch, opt, filepath.Join(outputDir, fn))
+		})
+		if err != nil {
+			fail(err)
+		} else {
+			files = append(files, fn)
+			paths = append(paths, filepath.Join(outputDir, fn))
+			rr.Charts = append(rr.Charts, reportChart{Metric: "commits by license", File: fn})
+		}
+	}
+	if *cards {
+		fn := cardFilename(slug)
+		err := renders.render(ch, filepath.Join(outputDir, fn), chartOptions{url: sub}, func() error {
+			return renderCard(sub, ch, filepath.Join(outputDir, fn))
+		})
+		if err != nil {
+			fail(err)
+		} else {
+			files = append(files, fn)
+			rr.Card = fn
+		}
+	}
+	return files, paths
+}
+
+// chartVariant is an additional chart of a metric rendered when its flag
+// is set.
+type chartVariant struct {
+	enabled  *bool
+	label    string
+	filename func(slug, metric string) string
+	render   func(ch *chart, metric string, opt chartOptions, filename string) error
+}
+
+var chartVariants = []chartVariant{
+	{enabled: yoyCharts, label: "year over year", filename: yoyFilename, render: displayYOY},
+	{enabled: deltaCharts, label: "change", filename: deltaFilename, render: displayDelta},
+}
+
+// seriesPoints returns the values of metric for each window over the
+// commits of ch before now, with X in Unix seconds. With cal the values
+// are rates per business day.
+func seriesPoints(ch *chart, metric string, w history.Window, cal *history.Calendar, now time.Time) (plotter.XYs, error) {
+	commits := make([]history.Commit, 0, len(ch.Commits))
+	for _, c := range ch.Commits {
+		if now.Before(c.When) {
+			continue
 		}
+		commits = append(commits, c)
+	}
+	commits = history.ExcludeFiles(commits, ch.excluded())
+	m := ch.metric(metric)
+	if m == nil {
+		return nil, fmt.Errorf("unknown metric %q", metric)
+	}
+	var series []history.Point
+	if cal != nil {
+		series = history.BusinessSeries(commits, w, m, cal)
+	} else {
+		series = history.Series(commits, w, m)
+	}
+	data := make(plotter.XYs, 0, len(series))
+	for _, pt := range series {
 		data = append(data, plotter.XY{
-			X: float64(x),
-			Y: fy,
+			X: float64(pt.Time.Unix()),
+			Y: pt.Value,
 		})
 	}
-	sort.Slice(data, func(i, j int) bool {
-		a, b := data[i], data[j]
-		return a.X < b.X
-	})
+	return data, nil
+}
 
+func display(ch *chart, metric string, opt chartOptions, filename string) error {
+	const GroupSize = 60 * 60 * 24 * 7
+	now := renderTime()
+	loc := opt.loc
+	wname, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+
+	data, err := seriesPoints(ch, metric, w, opt.cal, now)
+	if err != nil {
+		return err
+	}
+	anomalies := findAnomalies(metric, data, w)
+	data, bursts, burstY := flattenBursts(ch.Name, metric, data)
+	var maxY float64
+	for _, pt := range data {
+		if pt.Y > maxY {
+			maxY = pt.Y
+		}
+	}
 	xticks := plot.TimeTicks{
 		Ticker: plot.TickerFunc(func(min, max float64) []plot.Tick {
 			list := make([]plot.Tick, int((max-min)/GroupSize))
@@ -194,41 +784,87 @@ func display(ch *chart) error {
 			}
 			return list
 		}),
-		Format: "2006-01-02",
+		Format: loc.DateFormat,
 	}
 
 	p := plot.New()
-	p.Title.Text = ch.Name
+	addProvenance(p, ch)
+	p.Title.Text, err = chartTitle(ch, metric, opt, fmt.Sprintf("%s (health %d)", ch.Name, history.NewHealth(ch.Commits, now).Score))
+	if err != nil {
+		return err
+	}
 	p.X.Tick.Marker = xticks
-	p.Y.Label.Text = "Number of Commits (weekly)"
+	p.Y.Label.Text = loc.YLabel
+	if metric != "commits" {
+		p.Y.Label.Text = fmt.Sprintf(loc.Weekly, metric)
+	}
+	if wname != "weekly" {
+		p.Y.Label.Text = fmt.Sprintf(loc.Window, metric, wname)
+	}
+	if opt.cal != nil {
+		p.Y.Label.Text = fmt.Sprintf(loc.Business, metric, wname)
+	}
 	p.Add(plotter.NewGrid())
 
-	line, points, err := plotter.NewLinePoints(data)
-	if err != nil {
-		return err
+	if *iqrBand {
+		err = addBand(p, data, w)
+		if err != nil {
+			return err
+		}
+		p.Legend.Top = true
 	}
-	line.Color = color.RGBA{G: 255, A: 255}
-	points.Shape = draw.CircleGlyph{}
-	points.Color = color.RGBA{R: 255, A: 255}
 
-	p.Add(line, points)
-	p.Y.Max = maxY
+	if opt.baseline != nil && opt.baseline != ch && len(data) > 0 {
+		base, err := seriesPoints(opt.baseline, metric, w, opt.cal, now)
+		if err != nil {
+			return err
+		}
+		inRange := make(plotter.XYs, 0, len(base))
+		for _, pt := range base {
+			if pt.X < data[0].X || pt.X > data[len(data)-1].X {
+				continue
+			}
+			inRange = append(inRange, pt)
+			if pt.Y > maxY {
+				maxY = pt.Y
+			}
+		}
+		if len(inRange) > 0 {
+			bl, err := plotter.NewLine(inRange)
+			if err != nil {
+				return err
+			}
+			bl.Color = color.RGBA{R: 0x90, G: 0x90, B: 0xc0, A: 0x80}
+			p.Add(bl)
+			p.Legend.Add(opt.baseline.Name+" (baseline)", bl)
+			p.Legend.Top = true
+		}
+	}
 
-	fn := cleanFilename(ch.Name) + ".png"
-	err = p.Save(40*vg.Centimeter, 20*vg.Centimeter, filepath.Join(outputDir, fn))
+	thumbs, err := addSeries(p, data, w, opt, color.RGBA{G: 255, A: 255})
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-var cleaner = strings.NewReplacer(
-	" ", "_",
-	":", "-",
-	"\\", "-",
-	"/", "-",
-)
+	if opt.baseline != nil && opt.baseline != ch {
+		p.Legend.Add(ch.Name, thumbs...)
+	}
+	if len(bursts) > 0 {
+		err = addBurstLabels(p, bursts, burstY)
+		if err != nil {
+			return err
+		}
+	}
+	err = addAnomalies(p, anomalies, burstY)
+	if err != nil {
+		return err
+	}
+	setYRange(p, maxY, opt.yMax)
+	if len(data) > 0 {
+		err = addAnnotations(p, append(truncatedNote(ch), opt.notes...), data[0].X, data[len(data)-1].X, p.Y.Max)
+		if err != nil {
+			return err
+		}
+	}
 
-func cleanFilename(name string) string {
-	return cleaner.Replace(name)
+	return savePlot(p, filename)
 }
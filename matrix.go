@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/csv"
+	"image/color"
+	"io"
+	"math"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// writeMatrixCSV writes a square matrix of values with the names as the
+// first row and column. NaN values are left empty.
+func writeMatrixCSV(names []string, m [][]float64, format func(float64) string, filename string) error {
+	return writeFileAtomic(filename, func(f io.Writer) error {
+		w := csv.NewWriter(f)
+		w.Write(append([]string{""}, names...))
+		for i, row := range m {
+			rec := []string{names[i]}
+			for _, v := range row {
+				s := ""
+				if !math.IsNaN(v) {
+					s = format(v)
+				}
+				rec = append(rec, s)
+			}
+			w.Write(rec)
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
+
+// renderMatrix draws a square matrix of values with the names along the
+// left and the bottom. NaN values are drawn by shade but not labeled.
+func renderMatrix(names []string, m [][]float64, shade func(float64) color.Color, format func(float64) string, filename string) error {
+	const (
+		cell   = vg.Length(24)
+		margin = vg.Length(12)
+	)
+	labelFont, valueFont := plot.DefaultFont, plot.DefaultFont
+	labelFont.Size = 10
+	valueFont.Size = 7
+	labelFace := font.DefaultCache.Lookup(labelFont, labelFont.Size)
+	var labelWidth vg.Length
+	for _, name := range names {
+		if w := labelFace.Width(name); w > labelWidth {
+			labelWidth = w
+		}
+	}
+	left := margin + labelWidth + margin
+	bottom := margin + labelWidth + margin
+	size := vg.Length(len(names)) * cell
+	width := left + size + margin
+	height := bottom + size + margin
+
+	c, err := draw.NewFormattedCanvas(width, height, "png")
+	if err != nil {
+		return err
+	}
+	dc := draw.New(c)
+	dc.FillPolygon(color.White, []vg.Point{{X: 0, Y: 0}, {X: width, Y: 0}, {X: width, Y: height}, {X: 0, Y: height}})
+	label := draw.TextStyle{Color: color.Black, Font: labelFont, XAlign: draw.XRight, YAlign: draw.YCenter, Handler: plot.DefaultTextHandler}
+	value := draw.TextStyle{Color: color.Black, Font: valueFont, XAlign: draw.XCenter, YAlign: draw.YCenter, Handler: plot.DefaultTextHandler}
+	for i, row := range m {
+		y := bottom + size - vg.Length(i+1)*cell
+		dc.FillText(label, vg.Point{X: left - margin/2, Y: y + cell/2}, names[i])
+		for j, v := range row {
+			x := left + vg.Length(j)*cell
+			dc.FillPolygon(shade(v), []vg.Point{{X: x, Y: y}, {X: x + cell, Y: y}, {X: x + cell, Y: y + cell}, {X: x, Y: y + cell}})
+			if !math.IsNaN(v) {
+				dc.FillText(value, vg.Point{X: x + cell/2, Y: y + cell/2}, format(v))
+			}
+		}
+	}
+	label.Rotation = math.Pi / 2
+	for j, name := range names {
+		dc.FillText(label, vg.Point{X: left + vg.Length(j)*cell + cell/2, Y: bottom - margin/2}, name)
+	}
+	return writeCanvas(c, filename)
+}
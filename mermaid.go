@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// mermaidMonths is the number of months a Mermaid chart covers. Mermaid
+// labels every point, so longer spans become unreadable.
+const mermaidMonths = 24
+
+// exportMermaid writes a Markdown file with a section per repository and
+// a Mermaid xychart of each metric over the last two years by month, to
+// render in GitHub and other Markdown viewers without hosting images.
+func exportMermaid(ctx context.Context, d *exportData, out string) error {
+	now := time.Now()
+	from := history.Monthly.Start(now).AddDate(0, 1-mermaidMonths, 0)
+	return writeFileAtomic(out, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		bw.WriteString("# Repository activity\n")
+		for _, u := range d.Charts.urls() {
+			ch := d.Charts[u]
+			if len(ch.Commits) == 0 {
+				continue
+			}
+			fmt.Fprintf(bw, "\n## %s\n", ch.Name)
+			for _, metric := range d.Metrics {
+				data, err := seriesPoints(ch, metric, history.Monthly, nil, now)
+				if err != nil {
+					return err
+				}
+				values := map[int64]float64{}
+				for _, pt := range data {
+					values[int64(pt.X)] = pt.Y
+				}
+				var labels, ys []string
+				for t := from; !t.After(now); t = t.AddDate(0, 1, 0) {
+					labels = append(labels, mermaidQuote(t.Format("Jan 06")))
+					ys = append(ys, strconv.FormatFloat(values[t.Unix()], 'f', -1, 64))
+				}
+				fmt.Fprintf(bw, "\n```mermaid\nxychart-beta\n")
+				fmt.Fprintf(bw, "\ttitle %s\n", mermaidQuote(ch.Name+" "+metric+" per month"))
+				fmt.Fprintf(bw, "\tx-axis [%s]\n", strings.Join(labels, ", "))
+				fmt.Fprintf(bw, "\ty-axis %s\n", mermaidQuote(metric))
+				fmt.Fprintf(bw, "\tline [%s]\n```\n", strings.Join(ys, ", "))
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+// mermaidQuote returns s as a quoted Mermaid string. Mermaid has no
+// escapes, so double quotes become single.
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
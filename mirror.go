@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var useMirrors = flag.Bool("mirror", true, "keep bare mirrors of the repositories in the cache directory and fetch into them; false clones into memory on every fetch")
+
+const mirrorDir = "mirrors"
+
+// mirrorRefSpecs fetch all branches and tags as local refs, as git clone
+// --mirror does.
+var mirrorRefSpecs = []gitconfig.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// mirrorPath returns the directory of the bare mirror of u.
+func mirrorPath(u string) string {
+	return filepath.Join(cacheDir, mirrorDir, urlHash(u)+".git")
+}
+
+// openMirror returns the bare mirror of u, cloning it the first time and
+// fetching it with prune afterwards.
+func openMirror(ctx context.Context, u string) (*git.Repository, error) {
+	dir := mirrorPath(u)
+	r, err := git.PlainOpen(dir)
+	if err == git.ErrRepositoryNotExists {
+		fmt.Fprintln(progress, "mirror", u)
+		err = os.MkdirAll(filepath.Dir(dir), 0777)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		r, err = git.PlainCloneContext(ctx, dir, true, &git.CloneOptions{URL: u})
+		noteStep(ctx, "clone", start)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("mirror %s: %w", dir, err)
+	} else {
+		fmt.Fprintln(progress, "fetch", u)
+	}
+	start := time.Now()
+	err = r.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   mirrorRefSpecs,
+		Tags:       git.NoTags,
+		Force:      true,
+	})
+	noteStep(ctx, "update", start)
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+	remote, err := r.Remote("origin")
+	if err != nil {
+		return nil, err
+	}
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	err = pruneMirror(r, refs)
+	if err != nil {
+		return nil, err
+	}
+	return r, setHead(r, refs)
+}
+
+// pruneMirror removes the branches and tags deleted upstream, given the
+// references the remote lists.
+func pruneMirror(r *git.Repository, refs []*plumbing.Reference) error {
+	upstream := make(map[plumbing.ReferenceName]bool, len(refs))
+	for _, ref := range refs {
+		upstream[ref.Name()] = true
+	}
+	iter, err := r.References()
+	if err != nil {
+		return err
+	}
+	var stale []plumbing.ReferenceName
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		if (name.IsBranch() || name.IsTag()) && !upstream[name] {
+			stale = append(stale, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		err = r.Storer.RemoveReference(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateMirrors fetches the mirror of every configured repository without
+// reading commits.
+func updateMirrors(ctx context.Context) error {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	err = cfg.selectRepos(commandArgs())
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, u := range cfg.urls() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Bundles have no mirror, and the cli backend updates its own.
+		if isBundle(u) || cfg.backend(u) == "cli" {
+			continue
+		}
+		err = cfg.withTimeout(ctx, u, func(ctx context.Context) error {
+			_, err := openMirror(ctx, u)
+			return err
+		})
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "mirror %s: %v\n", u, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d mirrors failed", failed, len(cfg.Repos))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// overlapFilename returns the base file name, without extension, of the
+// contributor overlap matrix of a group.
+func overlapFilename(slug string) string {
+	return slug + "--overlap"
+}
+
+// contributorOverlap returns the number of authors each pair of members
+// has in common; the diagonal is the number of authors of each member.
+// multi is the number of authors of more than one member and total the
+// number of distinct authors of all members.
+func contributorOverlap(members []*chart) (m [][]float64, multi, total int) {
+	sets := make([]map[string]bool, len(members))
+	repos := map[string]int{}
+	for i, ch := range members {
+		sets[i] = map[string]bool{}
+		for _, c := range ch.Commits {
+			for _, p := range c.Authors() {
+				key := p.Key()
+				if sets[i][key] {
+					continue
+				}
+				sets[i][key] = true
+				repos[key]++
+			}
+		}
+	}
+	for _, n := range repos {
+		if n > 1 {
+			multi++
+		}
+	}
+	m = make([][]float64, len(members))
+	for i := range m {
+		m[i] = make([]float64, len(members))
+		for j := range m[i] {
+			for key := range sets[i] {
+				if sets[j][key] {
+					m[i][j]++
+				}
+			}
+		}
+	}
+	return m, multi, len(repos)
+}
+
+// writeOverlap writes the contributor overlap matrix of the members as
+// CSV and as an image. Cells are shaded by the share of the smaller
+// member's authors they have in common.
+func writeOverlap(members []*chart, m [][]float64, csvFile, imageFile string) error {
+	names := make([]string, len(members))
+	for i, ch := range members {
+		names[i] = ch.Name
+	}
+	format := func(v float64) string { return strconv.Itoa(int(v)) }
+	err := writeMatrixCSV(names, m, format, csvFile)
+	if err != nil {
+		return err
+	}
+	share := make([][]float64, len(m))
+	for i := range m {
+		share[i] = make([]float64, len(m))
+		for j := range m[i] {
+			share[i][j] = m[i][j] / math.Min(m[i][i], m[j][j])
+		}
+	}
+	// The image labels cells with the share, so shade and label match.
+	format = func(v float64) string { return strconv.Itoa(int(math.Round(v*100))) + "%" }
+	return renderMatrix(names, share, overlapShade, format, imageFile)
+}
+
+// overlapShade mixes white with purple by the shared fraction v.
+func overlapShade(v float64) color.Color {
+	if math.IsNaN(v) {
+		return correlationNone
+	}
+	to := color.RGBA{R: 0x6f, G: 0x42, B: 0xc1, A: 0xff}
+	mix := func(b uint8) uint8 { return uint8(0xff + (float64(b)-0xff)*v) }
+	return color.RGBA{R: mix(to.R), G: mix(to.G), B: mix(to.B), A: 0xff}
+}
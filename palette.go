@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/plot/vg"
+)
+
+var paletteName = flag.String("palette", "", "colors of the repository lines: "+strings.Join(paletteNames(), ", ")+"; overrides the config Palette")
+
+// lineStyle is an entry of a palette.
+type lineStyle struct {
+	Color  color.Color
+	Dashes []vg.Length // Solid if empty.
+}
+
+func rgb(v uint32) color.Color {
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+func dashes(d ...float64) []vg.Length {
+	list := make([]vg.Length, len(d))
+	for i, v := range d {
+		list[i] = vg.Points(v)
+	}
+	return list
+}
+
+const defaultPalette = "okabe-ito"
+
+// palettes are the built-in palettes. The colored ones stay distinct with
+// common forms of color blindness; mono tells lines apart by dash pattern
+// for print.
+var palettes = map[string][]lineStyle{
+	// Okabe and Ito, without yellow, which is hard to see on white.
+	"okabe-ito": {
+		{Color: rgb(0x0072b2)}, // Blue.
+		{Color: rgb(0xe69f00)}, // Orange.
+		{Color: rgb(0x009e73)}, // Bluish green.
+		{Color: rgb(0xd55e00)}, // Vermillion.
+		{Color: rgb(0x56b4e9)}, // Sky blue.
+		{Color: rgb(0xcc79a7)}, // Reddish purple.
+		{Color: rgb(0x000000)}, // Black.
+	},
+	// Paul Tol's bright scheme.
+	"tol-bright": {
+		{Color: rgb(0x4477aa)},
+		{Color: rgb(0xee6677)},
+		{Color: rgb(0x228833)},
+		{Color: rgb(0xccbb44)},
+		{Color: rgb(0x66ccee)},
+		{Color: rgb(0xaa3377)},
+		{Color: rgb(0xbbbbbb)},
+	},
+	// Paul Tol's high contrast scheme, also readable in greyscale.
+	"high-contrast": {
+		{Color: rgb(0x004488)},
+		{Color: rgb(0xddaa33)},
+		{Color: rgb(0xbb5566)},
+		{Color: rgb(0x000000)},
+	},
+	"mono": {
+		{Color: rgb(0x000000)},
+		{Color: rgb(0x000000), Dashes: dashes(6, 3)},
+		{Color: rgb(0x000000), Dashes: dashes(1, 2)},
+		{Color: rgb(0x000000), Dashes: dashes(6, 2, 1, 2)},
+		{Color: rgb(0x666666)},
+		{Color: rgb(0x666666), Dashes: dashes(10, 3)},
+		{Color: rgb(0x666666), Dashes: dashes(2, 2)},
+	},
+}
+
+// palette is the palette in use, set by usePalette.
+var palette = palettes[defaultPalette]
+
+func paletteNames() []string {
+	var names []string
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// usePalette selects the palette name, -palette taking precedence.
+func usePalette(name string) error {
+	if len(*paletteName) > 0 {
+		name = *paletteName
+	}
+	if len(name) == 0 {
+		name = defaultPalette
+	}
+	p, ok := palettes[name]
+	if !ok {
+		return fmt.Errorf("unknown palette %q, have %s", name, strings.Join(paletteNames(), ", "))
+	}
+	palette = p
+	return nil
+}
+
+// paletteIndex returns the palette entry of a slug. It only depends on
+// the slug, so a repository keeps its style between runs and charts.
+func paletteIndex(slug string) int {
+	h := fnv.New32a()
+	h.Write([]byte(slug))
+	return int(h.Sum32() % uint32(len(palette)))
+}
+
+// repoStyle returns the line style of a repository: the palette entry of
+// its slug, in the configured color if there is one.
+func (cfg *config) repoStyle(u, slug string) lineStyle {
+	st := palette[paletteIndex(slug)]
+	if c := cfg.color(u); c != nil {
+		st = lineStyle{Color: c}
+	}
+	return st
+}
+
+// hexColor writes c as #rrggbb.
+func hexColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// styleSet assigns distinct styles to the lines of one chart. A slug
+// whose palette entry is taken gets the next free one, so styles only
+// differ from the per repository ones on collisions.
+type styleSet struct {
+	used map[int]bool
+}
+
+func (ss *styleSet) pick(cfg *config, u, slug string) lineStyle {
+	if cfg.color(u) != nil {
+		return cfg.repoStyle(u, slug)
+	}
+	if ss.used == nil {
+		ss.used = map[int]bool{}
+	}
+	i := paletteIndex(slug)
+	if len(ss.used) < len(palette) {
+		for ss.used[i] {
+			i = (i + 1) % len(palette)
+		}
+	}
+	ss.used[i] = true
+	return palette[i]
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// A minimal Parquet writer: one row group, one uncompressed PLAIN data page
+// per column, and only required INT64 and BYTE_ARRAY columns.
+
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetNoConverted     = -1
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+type parquetColumn struct {
+	Name      string
+	Type      int32
+	Converted int32
+
+	ints    []int64
+	strings []string
+}
+
+func (c *parquetColumn) len() int {
+	if c.Type == parquetInt64 {
+		return len(c.ints)
+	}
+	return len(c.strings)
+}
+
+func (c *parquetColumn) encode() []byte {
+	var b bytes.Buffer
+	var n [8]byte
+	if c.Type == parquetInt64 {
+		for _, v := range c.ints {
+			binary.LittleEndian.PutUint64(n[:], uint64(v))
+			b.Write(n[:8])
+		}
+		return b.Bytes()
+	}
+	for _, v := range c.strings {
+		binary.LittleEndian.PutUint32(n[:], uint32(len(v)))
+		b.Write(n[:4])
+		b.WriteString(v)
+	}
+	return b.Bytes()
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
+
+var parquetMagic = []byte("PAR1")
+
+// writeParquet writes columns of equal length as a Parquet file.
+func writeParquet(w io.Writer, cols []*parquetColumn) error {
+	bw := bufio.NewWriter(w)
+	cw := &countWriter{w: bw}
+	rows := 0
+	if len(cols) > 0 {
+		rows = cols[0].len()
+	}
+	_, err := cw.Write(parquetMagic)
+	if err != nil {
+		return err
+	}
+
+	offsets := make([]int64, len(cols))
+	sizes := make([]int64, len(cols))
+	for i, c := range cols {
+		data := c.encode()
+		var ph thriftWriter
+		ph.i32(1, 0) // DATA_PAGE
+		ph.i32(2, int32(len(data)))
+		ph.i32(3, int32(len(data)))
+		ph.beginStruct(5)
+		ph.i32(1, int32(rows))
+		ph.i32(2, parquetPlain)
+		ph.i32(3, parquetRLE)
+		ph.i32(4, parquetRLE)
+		ph.endStruct()
+		ph.stop()
+
+		offsets[i] = cw.n
+		sizes[i] = int64(ph.buf.Len() + len(data))
+		_, err = cw.Write(ph.buf.Bytes())
+		if err != nil {
+			return err
+		}
+		_, err = cw.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	var md thriftWriter
+	md.i32(1, 1) // Version.
+	md.beginList(2, thriftStruct, len(cols)+1)
+	md.beginElem()
+	md.binary(4, "schema")
+	md.i32(5, int32(len(cols)))
+	md.endElem()
+	for _, c := range cols {
+		md.beginElem()
+		md.i32(1, c.Type)
+		md.i32(3, 0) // REQUIRED.
+		md.binary(4, c.Name)
+		if c.Converted != parquetNoConverted {
+			md.i32(6, c.Converted)
+		}
+		md.endElem()
+	}
+	md.i64(3, int64(rows))
+	md.beginList(4, thriftStruct, 1)
+	md.beginElem()
+	md.beginList(1, thriftStruct, len(cols))
+	var total int64
+	for i, c := range cols {
+		total += sizes[i]
+		md.beginElem()
+		md.i64(2, offsets[i])
+		md.beginStruct(3)
+		md.i32(1, c.Type)
+		md.beginList(2, thriftI32, 2)
+		md.varint(parquetPlain)
+		md.varint(parquetRLE)
+		md.beginList(3, thriftBinary, 1)
+		md.bytes(c.Name)
+		md.i32(4, 0) // UNCOMPRESSED.
+		md.i64(5, int64(rows))
+		md.i64(6, sizes[i])
+		md.i64(7, sizes[i])
+		md.i64(9, offsets[i])
+		md.endStruct()
+		md.endElem()
+	}
+	md.i64(2, total)
+	md.i64(3, int64(rows))
+	md.endElem()
+	md.binary(6, "gitgraph")
+	md.stop()
+
+	_, err = cw.Write(md.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(md.buf.Len()))
+	_, err = cw.Write(n[:])
+	if err != nil {
+		return err
+	}
+	_, err = cw.Write(parquetMagic)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol.
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) varint(v int64) {
+	u := uint64((v << 1) ^ (v >> 63))
+	t.uvarint(u)
+}
+
+func (t *thriftWriter) uvarint(u uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], u)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	delta := id - t.last
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) bytes(s string) {
+	t.uvarint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.bytes(s)
+}
+
+func (t *thriftWriter) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elem)
+	t.uvarint(uint64(n))
+}
+
+func (t *thriftWriter) beginElem() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endElem() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+func (t *thriftWriter) endStruct() {
+	t.endElem()
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
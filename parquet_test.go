@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with the file name in testdata, first
+// rewriting it with -update-golden.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *updateGolden {
+		err := os.WriteFile(golden, got, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s; run go test -update-golden after checking the change", golden)
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	cols := []*parquetColumn{
+		{Name: "repo", Type: parquetByteArray, Converted: parquetUTF8, strings: []string{"https://example.com/a.git", "https://example.com/a.git", "https://example.com/b.git"}},
+		{Name: "author", Type: parquetByteArray, Converted: parquetUTF8, strings: []string{"Ann", "Bob", "Cé"}},
+		{Name: "timestamp", Type: parquetInt64, Converted: parquetTimestampMillis, ints: []int64{1641202200000, 1641288600000, 1641893400000}},
+		{Name: "insertions", Type: parquetInt64, Converted: parquetNoConverted, ints: []int64{30, 5, 0}},
+	}
+	var b bytes.Buffer
+	err := writeParquet(&b, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := b.Bytes()
+	if !bytes.HasPrefix(got, parquetMagic) || !bytes.HasSuffix(got, parquetMagic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	checkGolden(t, "commits.parquet", got)
+}
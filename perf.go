@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fetchStats are the durations of the steps of fetching a repository and
+// the bytes it added to the mirror.
+type fetchStats struct {
+	// Clone is the time cloning a new mirror or a repository into memory,
+	// Update fetching into an existing mirror, and Walk reading the
+	// commits and branches.
+	Clone, Update, Walk time.Duration
+	// Bytes is the growth of the mirror, close to the bytes transferred.
+	Bytes int64
+	// Rewritten is set if the upstream history was rewritten and the
+	// repository fetched again from scratch.
+	Rewritten bool `json:",omitempty"`
+}
+
+type fetchStatsKey struct{}
+
+// withFetchStats returns ctx recording the steps of a fetch in st.
+func withFetchStats(ctx context.Context, st *fetchStats) context.Context {
+	return context.WithValue(ctx, fetchStatsKey{}, st)
+}
+
+// statsOf returns the statistics recorded for the fetch of ctx, nil if
+// there are none.
+func statsOf(ctx context.Context) *fetchStats {
+	st, _ := ctx.Value(fetchStatsKey{}).(*fetchStats)
+	return st
+}
+
+// noteStep adds the time since start to the step of the fetch of ctx:
+// "clone", "update" or "walk".
+func noteStep(ctx context.Context, step string, start time.Time) {
+	st := statsOf(ctx)
+	if st == nil {
+		return
+	}
+	d := time.Since(start)
+	switch step {
+	case "clone":
+		st.Clone += d
+	case "update":
+		st.Update += d
+	case "walk":
+		st.Walk += d
+	}
+}
+
+// dirSize returns the size of the files under dir, zero if it does not
+// exist.
+func dirSize(dir string) int64 {
+	var n int64
+	filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			n += fi.Size()
+		}
+		return nil
+	})
+	return n
+}
+
+// addFetchStats records st in the summary of a repository.
+func (rs *repoSummary) addFetchStats(st fetchStats) {
+	rs.CloneSeconds = st.Clone.Seconds()
+	rs.UpdateSeconds = st.Update.Seconds()
+	rs.WalkSeconds = st.Walk.Seconds()
+	rs.FetchedBytes = st.Bytes
+	rs.Rewritten = st.Rewritten
+}
+
+var (
+	lastSummaryMu sync.Mutex
+	lastSummary   *summary
+)
+
+// setLastSummary keeps sum, of a finished run, for /metrics.
+func setLastSummary(sum *summary) {
+	sum.Seconds = time.Since(sum.start).Seconds()
+	lastSummaryMu.Lock()
+	lastSummary = sum
+	lastSummaryMu.Unlock()
+}
+
+// metrics handles /metrics, the durations and sizes of the last run of
+// this process in the Prometheus text format. Repositories not fetched
+// in that run have no fetch samples.
+func (s *server) metrics(w http.ResponseWriter, r *http.Request) {
+	lastSummaryMu.Lock()
+	sum := lastSummary
+	lastSummaryMu.Unlock()
+
+	var b strings.Builder
+	family := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	family("gitgraph_up", "gauge", "Whether the cache can be read.")
+	up := 1
+	if _, err := s.data(); err != nil {
+		up = 0
+	}
+	fmt.Fprintf(&b, "gitgraph_up %d\n", up)
+	if sum == nil {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, b.String())
+		return
+	}
+	repos := append([]*repoSummary(nil), sum.Repos...)
+	sort.Slice(repos, func(i, j int) bool { return repos[i].URL < repos[j].URL })
+	// Names need not be unique; URLs are.
+	label := func(rs *repoSummary) string {
+		return `url="` + labelEscaper.Replace(rs.URL) + `"`
+	}
+
+	family("gitgraph_run_start_timestamp_seconds", "gauge", "Start of the last run.")
+	fmt.Fprintf(&b, "gitgraph_run_start_timestamp_seconds %d\n", sum.Start.Unix())
+	family("gitgraph_run_duration_seconds", "gauge", "Duration of the last run.")
+	fmt.Fprintf(&b, "gitgraph_run_duration_seconds %g\n", sum.Seconds)
+	family("gitgraph_run_errors", "gauge", "Errors of the last run, not counting failed repositories.")
+	fmt.Fprintf(&b, "gitgraph_run_errors %d\n", len(sum.Errors))
+
+	family("gitgraph_fetch_step_seconds", "gauge", "Time of each step of fetching a repository in the last run.")
+	for _, rs := range repos {
+		if !rs.Fetched {
+			continue
+		}
+		for _, step := range []struct {
+			name string
+			v    float64
+		}{{"clone", rs.CloneSeconds}, {"update", rs.UpdateSeconds}, {"walk", rs.WalkSeconds}, {"total", rs.FetchSeconds}} {
+			fmt.Fprintf(&b, "gitgraph_fetch_step_seconds{%s,step=\"%s\"} %g\n", label(rs), step.name, step.v)
+		}
+	}
+	family("gitgraph_fetched_bytes", "gauge", "Growth of the mirror of a repository in the last run.")
+	for _, rs := range repos {
+		if rs.Fetched {
+			fmt.Fprintf(&b, "gitgraph_fetched_bytes{%s} %d\n", label(rs), rs.FetchedBytes)
+		}
+	}
+	family("gitgraph_render_seconds", "gauge", "Time rendering the charts of a repository in the last run.")
+	for _, rs := range repos {
+		fmt.Fprintf(&b, "gitgraph_render_seconds{%s} %g\n", label(rs), rs.RenderSeconds)
+	}
+	family("gitgraph_commits", "gauge", "Cached commits of a repository.")
+	for _, rs := range repos {
+		fmt.Fprintf(&b, "gitgraph_commits{%s} %d\n", label(rs), rs.Commits)
+	}
+	family("gitgraph_repo_failed", "gauge", "Whether a repository failed in the last run.")
+	for _, rs := range repos {
+		failed := 0
+		if len(rs.Error) > 0 {
+			failed = 1
+		}
+		fmt.Fprintf(&b, "gitgraph_repo_failed{%s} %d\n", label(rs), failed)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, b.String())
+}
+
+// labelEscaper escapes a label value of the Prometheus text format, which
+// only escapes backslash, double quote and newline.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var profileName = flag.String("profile", "", "use the named profile of the config, replacing the settings it lists")
+
+// decodeConfig decodes the config file b into cfg, with the settings of
+// the -profile replacing those of the file. A profile replaces whole
+// settings: a profile with Repos has only its own repositories.
+func decodeConfig(b []byte, cfg *config) error {
+	if len(*profileName) > 0 {
+		var base map[string]json.RawMessage
+		err := json.Unmarshal(b, &base)
+		if err != nil {
+			return err
+		}
+		var profiles map[string]json.RawMessage
+		if raw, ok := base["Profiles"]; ok {
+			err = json.Unmarshal(raw, &profiles)
+			if err != nil {
+				return fmt.Errorf("Profiles: %w", err)
+			}
+		}
+		raw, ok := profiles[*profileName]
+		if !ok {
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("no profile %q, have %s", *profileName, strings.Join(names, ", "))
+		}
+		var p map[string]json.RawMessage
+		err = json.Unmarshal(raw, &p)
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", *profileName, err)
+		}
+		if _, ok := p["Profiles"]; ok {
+			return fmt.Errorf("profile %q: profiles cannot be nested", *profileName)
+		}
+		delete(base, "Profiles")
+		for k, v := range p {
+			base[k] = v
+		}
+		b, err = json.Marshal(base)
+		if err != nil {
+			return err
+		}
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	return d.Decode(cfg)
+}
+
+// useDirs sets the cache and output directories from the config, relative
+// to the config file in dir, unless given by flags.
+func (cfg *config) useDirs(dir string) {
+	rel := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	if len(cfg.CacheDir) > 0 && !flagSet("cache-dir") {
+		cacheDir = rel(cfg.CacheDir)
+	}
+	if len(cfg.OutputDir) > 0 && !flagSet("output-dir") {
+		outputDir = rel(cfg.OutputDir)
+	}
+}
+
+// credentialEnv maps the variables credentials are read from to those
+// named by the config Credentials.
+var credentialEnv map[string]string
+
+// credential returns the credential usually read from the environment
+// variable name, such as GITHUB_TOKEN, from the variable the config
+// names instead if there is one.
+func credential(name string) string {
+	if v, ok := credentialEnv[name]; ok {
+		name = v
+	}
+	return os.Getenv(name)
+}
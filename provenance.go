@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"runtime/debug"
+	"time"
+
+	"gonum.org/v1/plot"
+)
+
+var provenanceLabel = flag.Bool("provenance", true, "label charts with the commit and fetch date of the data they show")
+
+// toolVersion returns the version of gitgraph, the module version it was
+// built from, or "devel" for a build from a checkout.
+func toolVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok || len(bi.Main.Version) == 0 || bi.Main.Version == "(devel)" {
+		return "devel"
+	}
+	return bi.Main.Version
+}
+
+// noteFetch records that the commits of ch were read at now from the
+// commit tip, with this version of gitgraph.
+func noteFetch(ch *chart, tip string, now time.Time) {
+	ch.Fetched = now
+	ch.Tip = tip
+	ch.Tool = toolVersion()
+}
+
+// provenance describes the data of ch: "data as of <tip> <date>", or
+// without the tip if it is not known.
+func provenance(ch *chart) string {
+	if ch.Fetched.IsZero() {
+		return ""
+	}
+	s := "data as of "
+	if len(ch.Tip) > 0 {
+		tip := ch.Tip
+		if len(tip) > 12 {
+			tip = tip[:12]
+		}
+		s += tip + " "
+	}
+	return s + ch.Fetched.UTC().Format("2006-01-02")
+}
+
+// addProvenance labels the X axis of p with the provenance of ch.
+func addProvenance(p *plot.Plot, ch *chart) {
+	if *provenanceLabel {
+		p.X.Label.Text = provenance(ch)
+	}
+}
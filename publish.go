@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var atomicOutput = flag.Bool("atomic-output", false, "render into a new directory and switch the output directory, then a symlink, to it when the run is done")
+
+// outputVersions is the number of rendered directories kept with
+// -atomic-output: the current one and the one before it, for readers still
+// on it.
+const outputVersions = 2
+
+// outputStage is a run rendering into a new version of the output
+// directory. outputDir points to the version until the run ends.
+type outputStage struct {
+	link, dir string
+	published bool
+}
+
+// stageOutput starts a new version of the output directory if
+// -atomic-output is set, seeded with the files of the current one. An
+// output directory that is not a symlink yet becomes the first version.
+func stageOutput() (*outputStage, error) {
+	if !*atomicOutput {
+		return nil, nil
+	}
+	link := outputDir
+	versions := link + ".versions"
+	err := os.MkdirAll(versions, 0777)
+	if err != nil {
+		return nil, err
+	}
+	var prev string
+	fi, err := os.Lstat(link)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case fi.Mode()&os.ModeSymlink != 0:
+		prev, err = filepath.EvalSymlinks(link)
+		if err != nil {
+			return nil, err
+		}
+	case fi.IsDir():
+		prev = filepath.Join(versions, versionName())
+		err = os.Rename(link, prev)
+		if err != nil {
+			return nil, err
+		}
+		err = switchLink(link, prev)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s is neither a directory nor a symlink", link)
+	}
+
+	st := &outputStage{link: link, dir: filepath.Join(versions, versionName())}
+	err = os.Mkdir(st.dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	if len(prev) > 0 {
+		err = seedOutput(prev, st.dir)
+		if err != nil {
+			os.RemoveAll(st.dir)
+			return nil, err
+		}
+	}
+	outputDir = st.dir
+	return st, nil
+}
+
+// versionName names a version by the time it was started.
+func versionName() string {
+	return time.Now().UTC().Format("20060102T150405.000000000Z")
+}
+
+// seedOutput links the files of the directory from into to, copying those
+// that cannot be linked. Files are always replaced by rename, never
+// written in place, so the versions do not affect each other.
+func seedOutput(from, to string) error {
+	return filepath.Walk(from, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		dst := filepath.Join(to, rel)
+		switch {
+		case fi.IsDir():
+			return os.Mkdir(dst, 0777)
+		case !fi.Mode().IsRegular():
+			return nil
+		}
+		if os.Link(path, dst) == nil {
+			return nil
+		}
+		return writeFileAtomic(dst, func(w io.Writer) error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(w, f)
+			return err
+		})
+	})
+}
+
+// switchLink points link to dir by renaming a new symlink over it, which
+// readers see as one change.
+func switchLink(link, dir string) error {
+	target, err := filepath.Rel(filepath.Dir(link), dir)
+	if err != nil {
+		target = dir
+	}
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	err = os.Symlink(target, tmp)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, link)
+}
+
+// publish switches the output directory to the new version and removes
+// versions older than the last outputVersions.
+func (st *outputStage) publish() error {
+	if st == nil {
+		return nil
+	}
+	err := switchLink(st.link, st.dir)
+	if err != nil {
+		return err
+	}
+	st.published = true
+	versions := filepath.Dir(st.dir)
+	list, err := os.ReadDir(versions)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range list {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names)-outputVersions; i++ {
+		err = os.RemoveAll(filepath.Join(versions, names[i]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close ends the stage: outputDir is the output directory again, and an
+// unpublished version is removed.
+func (st *outputStage) close() {
+	if st == nil {
+		return
+	}
+	outputDir = st.link
+	if !st.published {
+		os.RemoveAll(st.dir)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxPushBody limits the size of a push webhook request.
+const maxPushBody = 25 << 20
+
+// pushPayload holds the repository URLs of GitHub and GitLab push events.
+type pushPayload struct {
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+		HTMLURL  string `json:"html_url"`
+		SSHURL   string `json:"ssh_url"`
+		GitURL   string `json:"git_url"`
+		Homepage string `json:"homepage"`
+	} `json:"repository"`
+	Project struct {
+		HTTPURL string `json:"git_http_url"`
+		SSHURL  string `json:"git_ssh_url"`
+		WebURL  string `json:"web_url"`
+	} `json:"project"`
+}
+
+func (p *pushPayload) urls() []string {
+	return []string{
+		p.Repository.CloneURL, p.Repository.HTMLURL, p.Repository.SSHURL, p.Repository.GitURL, p.Repository.Homepage,
+		p.Project.HTTPURL, p.Project.SSHURL, p.Project.WebURL,
+	}
+}
+
+// normalizeRepoURL returns u without scheme, user, .git suffix, trailing
+// slash and case, so the URLs a host sends match the configured ones.
+func normalizeRepoURL(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	}
+	if i := strings.Index(u, "@"); i >= 0 {
+		u = u[i+1:]
+	}
+	u = strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+	// scp-like SSH URLs separate the host with a colon.
+	return strings.Replace(u, ":", "/", 1)
+}
+
+// pushedRepo returns the configured repository of the push event, or ""
+// if it is not charted.
+func (cfg *config) pushedRepo(p *pushPayload) string {
+	byURL := map[string]string{}
+	for _, u := range cfg.urls() {
+		byURL[normalizeRepoURL(u)] = u
+	}
+	for _, u := range p.urls() {
+		if len(u) == 0 {
+			continue
+		}
+		if cu, ok := byURL[normalizeRepoURL(u)]; ok {
+			return cu
+		}
+	}
+	return ""
+}
+
+// checkPushSecret validates the secret of a GitHub or GitLab webhook
+// request with body b. It returns the event name.
+func checkPushSecret(r *http.Request, b []byte, secret string) (string, error) {
+	if ev := r.Header.Get("X-GitHub-Event"); len(ev) > 0 {
+		sig := strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+		got, err := hex.DecodeString(sig)
+		if err != nil || len(got) == 0 {
+			return "", errors.New("missing or invalid X-Hub-Signature-256")
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(b)
+		if !hmac.Equal(got, mac.Sum(nil)) {
+			return "", errors.New("signature mismatch")
+		}
+		return ev, nil
+	}
+	if ev := r.Header.Get("X-Gitlab-Event"); len(ev) > 0 {
+		token := r.Header.Get("X-Gitlab-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return "", errors.New("token mismatch")
+		}
+		return ev, nil
+	}
+	return "", errors.New("not a GitHub or GitLab webhook")
+}
+
+// pushHook handles /hooks/push: a valid push event of a charted
+// repository queues it to be fetched and rendered by the daemon.
+func (s *server) pushHook(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushBody))
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		ev, err := checkPushSecret(r, b, secret)
+		if err != nil {
+			httpError(w, http.StatusUnauthorized, err)
+			return
+		}
+		switch ev {
+		case "ping":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "push", "Push Hook":
+		default:
+			http.Error(w, fmt.Sprintf("ignoring %q event", ev), http.StatusAccepted)
+			return
+		}
+		var p pushPayload
+		err = json.Unmarshal(b, &p)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		u := s.cfg.pushedRepo(&p)
+		if len(u) == 0 {
+			http.Error(w, "repository is not charted", http.StatusAccepted)
+			return
+		}
+		log.Print("push: ", u)
+		s.push(u)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// push queues the repository u for the next daemon run.
+func (s *server) push(u string) {
+	s.mu.Lock()
+	if s.pending == nil {
+		s.pending = map[string]bool{}
+	}
+	s.pending[u] = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// takePushed returns and clears the queued repositories.
+func (s *server) takePushed() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.pending
+	s.pending = nil
+	return list
+}
+
+type pushedKey struct{}
+
+// withPushed returns ctx for a run fetching only the pushed repositories,
+// whether they are due or not.
+func withPushed(ctx context.Context, pushed map[string]bool) context.Context {
+	return context.WithValue(ctx, pushedKey{}, pushed)
+}
+
+// pushedRepos returns the repositories a run started by push webhooks
+// fetches, or nil for a scheduled run.
+func pushedRepos(ctx context.Context) map[string]bool {
+	pushed, _ := ctx.Value(pushedKey{}).(map[string]bool)
+	return pushed
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCheckPushSecret(t *testing.T) {
+	// The example of the GitHub webhook documentation.
+	const (
+		secret  = "It's a Secret to Everybody"
+		payload = "Hello, World!"
+		sig     = "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		event   string // Empty if the request is refused.
+	}{
+		{"github", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": sig}, "push"},
+		{"github other secret", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=857107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"}, ""},
+		{"github no signature", map[string]string{"X-GitHub-Event": "push"}, ""},
+		{"github sha1 signature", map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha1=01dc10d0c83e72ed246219cdd91669667fe2ca59"}, ""},
+		{"gitlab", map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": secret}, "Push Hook"},
+		{"gitlab other token", map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "guess"}, ""},
+		{"unknown host", map[string]string{"X-Hub-Signature-256": sig}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest("POST", "/hooks/push", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			ev, err := checkPushSecret(r, []byte(payload), secret)
+			if len(tt.event) == 0 {
+				if err == nil {
+					t.Fatalf("accepted as %q", ev)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ev != tt.event {
+				t.Errorf("event %q, want %q", ev, tt.event)
+			}
+		})
+	}
+}
+
+func TestNormalizeRepoURL(t *testing.T) {
+	for _, u := range []string{
+		"https://github.com/Kardianos/GitGraph.git",
+		"https://user@github.com/kardianos/gitgraph/",
+		"git@github.com:kardianos/gitgraph.git",
+		"ssh://git@github.com/kardianos/gitgraph",
+	} {
+		if got := normalizeRepoURL(u); got != "github.com/kardianos/gitgraph" {
+			t.Errorf("normalizeRepoURL(%q) = %q", u, got)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+var maxRepoSize, maxCacheSize, rateLimit byteSize
+
+func init() {
+	flag.Var(&maxRepoSize, "max-repo-size", "skip repositories whose mirror is or grows larger than this, such as 2GB; overrides the config Quota")
+	flag.Var(&maxCacheSize, "max-cache-size", "skip fetching once the cache directory is larger than this, such as 50GB; overrides the config Quota")
+	flag.Var(&rateLimit, "rate-limit", "limit downloads of the go-git backend to this many bytes a second, such as 5MB; overrides the config Quota")
+}
+
+// quotaConfig limits what runs download and keep, so scheduled runs do
+// not fill disks or links.
+type quotaConfig struct {
+	// MaxRepoSize is the largest mirror fetched.
+	MaxRepoSize byteSize `json:",omitempty"`
+	// MaxCacheSize stops fetching when the cache directory, mirrors
+	// included, is larger.
+	MaxCacheSize byteSize `json:",omitempty"`
+	// RateLimit is the download rate of the go-git backend, in bytes a
+	// second.
+	RateLimit byteSize `json:",omitempty"`
+}
+
+// byteSize is a number of bytes, written as 500MB or 2GiB.
+type byteSize int64
+
+var sizeUnits = []struct {
+	suffix string
+	n      int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+	{"B", 1},
+}
+
+func (s byteSize) String() string {
+	for i := 3; i >= 0; i-- {
+		if u := sizeUnits[i]; s >= byteSize(u.n) {
+			return strconv.FormatFloat(float64(s)/float64(u.n), 'f', 1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+func (s *byteSize) Set(v string) error {
+	orig := v
+	v = strings.TrimSpace(v)
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(v), strings.ToUpper(u.suffix)) {
+			v, mult = strings.TrimSpace(v[:len(v)-len(u.suffix)]), u.n
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid size %q", orig)
+	}
+	*s = byteSize(f * float64(mult))
+	return nil
+}
+
+// UnmarshalJSON accepts a number of bytes or a string such as "2GB".
+func (s *byteSize) UnmarshalJSON(b []byte) error {
+	var n int64
+	if json.Unmarshal(b, &n) == nil {
+		*s = byteSize(n)
+		return nil
+	}
+	var v string
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	return s.Set(v)
+}
+
+func (cfg *config) quota() quotaConfig {
+	var q quotaConfig
+	if cfg.Quota != nil {
+		q = *cfg.Quota
+	}
+	if maxRepoSize > 0 {
+		q.MaxRepoSize = maxRepoSize
+	}
+	if maxCacheSize > 0 {
+		q.MaxCacheSize = maxCacheSize
+	}
+	if rateLimit > 0 {
+		q.RateLimit = rateLimit
+	}
+	return q
+}
+
+// maxSize returns the largest mirror of u fetched: -max-repo-size if
+// set, else the repository or global config. Zero is no limit.
+func (cfg *config) maxSize(u string) byteSize {
+	switch {
+	case maxRepoSize > 0:
+		return maxRepoSize
+	case cfg.Repos[u] != nil && cfg.Repos[u].MaxSize != nil:
+		return *cfg.Repos[u].MaxSize
+	}
+	return cfg.quota().MaxRepoSize
+}
+
+// quotaError is a fetch skipped for a limit. It is reported, but does
+// not fail the run.
+type quotaError struct {
+	reason string
+}
+
+func (e *quotaError) Error() string { return e.reason }
+
+// skip records that u was not fetched and why.
+func (rs *repoSummary) skip(err error) {
+	rs.Skipped = err.Error()
+	fmt.Fprintf(progress, "skip %s: %v\n", rs.URL, err)
+}
+
+// withSizeLimit runs fn, fetching u, and stops it with a quotaError if
+// the mirror of u is or grows larger than max. A mirror cloned over the
+// limit is removed by the clone error; one that grew is kept, and
+// skipped by later runs.
+func withSizeLimit(ctx context.Context, u string, max byteSize, fn func(ctx context.Context) error) error {
+	if max <= 0 || !*useMirrors || isBundle(u) {
+		return fn(ctx)
+	}
+	dir := mirrorPath(u)
+	if n := dirSize(dir); n > int64(max) {
+		return &quotaError{fmt.Sprintf("mirror is %v, larger than %v", byteSize(n), max)}
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var over int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(2 * time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-cctx.Done():
+				return
+			case <-t.C:
+			}
+			if n := dirSize(dir); n > int64(max) {
+				over = n
+				cancel()
+				return
+			}
+		}
+	}()
+	err := fn(cctx)
+	cancel()
+	<-done
+	if over > 0 && ctx.Err() == nil {
+		return &quotaError{fmt.Sprintf("mirror grew to %v, larger than %v", byteSize(over), max)}
+	}
+	return err
+}
+
+// cacheQuota tracks the size of the cache directory during a fetch run.
+type cacheQuota struct {
+	max  byteSize
+	used int64
+}
+
+func newCacheQuota(max byteSize) *cacheQuota {
+	q := &cacheQuota{max: max}
+	if max > 0 {
+		q.used = dirSize(cacheDir)
+	}
+	return q
+}
+
+// check returns a quotaError once the cache is larger than the limit.
+func (q *cacheQuota) check() error {
+	if q.max > 0 && q.used > int64(q.max) {
+		return &quotaError{fmt.Sprintf("cache is %v, larger than %v", byteSize(q.used), q.max)}
+	}
+	return nil
+}
+
+// add counts the growth of a mirror.
+func (q *cacheQuota) add(st fetchStats) {
+	q.used += st.Bytes
+}
+
+var installRateLimit sync.Once
+
+// limitRate makes go-git download over HTTP at no more than rate bytes
+// a second, all repositories together. The cli backend and ssh are not
+// limited.
+func limitRate(rate byteSize) {
+	if rate <= 0 {
+		return
+	}
+	installRateLimit.Do(func() {
+		l := &rateLimiter{rate: float64(rate)}
+		c := githttp.NewClient(&http.Client{Transport: rateTransport{l, http.DefaultTransport}})
+		client.InstallProtocol("http", c)
+		client.InstallProtocol("https", c)
+	})
+}
+
+// rateLimiter spaces reads to keep to rate bytes a second.
+type rateLimiter struct {
+	rate float64
+
+	mu   sync.Mutex
+	next time.Time // When the bytes read so far are allowed.
+}
+
+// wait blocks until n more bytes are allowed.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	d := l.next.Sub(now)
+	l.mu.Unlock()
+	time.Sleep(d)
+}
+
+type rateTransport struct {
+	l    *rateLimiter
+	base http.RoundTripper
+}
+
+func (t rateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = rateBody{resp.Body, t.l}
+	return resp, nil
+}
+
+type rateBody struct {
+	io.ReadCloser
+	l *rateLimiter
+}
+
+func (b rateBody) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth.
+	if len(p) > 32<<10 {
+		p = p[:32<<10]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.l.wait(n)
+	return n, err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var renderCacheFlag = flag.Bool("render-cache", true, "skip rendering charts whose data and settings are unchanged since they were last rendered")
+
+// renderCacheFilename records, in the output directory, the key of the
+// inputs each chart file was rendered from.
+const renderCacheFilename = ".render.json"
+
+// renderCache skips rendering a chart if the file exists and was rendered
+// from inputs with the same key: a hash of the data of the chart, its
+// options and the settings of the run. The settings are every flag, the
+// contents of the config file, the size and time of the files named by
+// flags or the config, and the date, since charts show their age.
+type renderCache struct {
+	settings string
+	keys     map[string]string
+	hits     int
+	// data caches the hash of the commits of each chart.
+	data map[*chart]string
+}
+
+// renders is the render cache of the run, nil if disabled.
+var renders *renderCache
+
+// loadRenderCache reads the keys of the last run into renders.
+func loadRenderCache(cfg *config) error {
+	renders = nil
+	if !*renderCacheFlag {
+		return nil
+	}
+	rc := &renderCache{keys: map[string]string{}, data: map[*chart]string{}}
+	b, err := os.ReadFile(filepath.Join(outputDir, renderCacheFilename))
+	switch {
+	case err == nil:
+		// An unreadable file only means rendering everything again.
+		json.Unmarshal(b, &rc.keys)
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	h := sha256.New()
+	fmt.Fprintln(h, toolVersion(), renderTime().Format("2006-01-02"))
+	var files []string
+	flag.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(h, "%s=%s\n", f.Name, f.Value)
+		files = append(files, f.Value.String())
+	})
+	if cfg.Watermark != nil && len(cfg.Watermark.Logo) > 0 {
+		files = append(files, filepath.Join(filepath.Dir(*configFile), cfg.Watermark.Logo))
+	}
+	sort.Strings(files)
+	for _, fn := range files {
+		fi, err := os.Stat(fn)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		fmt.Fprintln(h, fn, fi.Size(), fi.ModTime().UnixNano())
+	}
+	err = hashFile(h, *configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	rc.settings = hex.EncodeToString(h.Sum(nil))
+	renders = rc
+	return nil
+}
+
+func hashFile(h hash.Hash, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// dataKey returns the hash of the data of ch.
+func (rc *renderCache) dataKey(ch *chart) string {
+	if k, ok := rc.data[ch]; ok {
+		return k
+	}
+	h := sha256.New()
+	// Charts only show the date of the fetch, so fetches the same day
+	// that find nothing new keep the key.
+	c := *ch
+	c.Fetched = c.Fetched.UTC().Truncate(24 * time.Hour)
+	// Encoding a chart does not fail.
+	json.NewEncoder(h).Encode(c)
+	fmt.Fprintln(h, ch.ignore, ch.licenses)
+	k := hex.EncodeToString(h.Sum(nil))
+	rc.data[ch] = k
+	return k
+}
+
+// key returns the key of the chart of ch in filename with opt.
+func (rc *renderCache) key(ch *chart, filename string, opt chartOptions) string {
+	h := sha256.New()
+	fmt.Fprintln(h, rc.settings, rc.dataKey(ch), filename)
+	fmt.Fprintf(h, "%q %q %q %v %v %v %v\n", opt.url, opt.window, opt.chartStyle, opt.style.Color, opt.style.Dashes, opt.yMax, opt.notes)
+	if opt.baseline != nil {
+		fmt.Fprintln(h, rc.dataKey(opt.baseline))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// render calls draw to write the chart of ch with opt to filename unless
+// the file was rendered from the same inputs before.
+func (rc *renderCache) render(ch *chart, filename string, opt chartOptions, draw func() error) error {
+	if rc == nil {
+		return draw()
+	}
+	name := filepath.Base(filename)
+	k := rc.key(ch, name, opt)
+	if rc.keys[name] == k {
+		if _, err := os.Stat(filename); err == nil {
+			rc.hits++
+			return nil
+		}
+	}
+	delete(rc.keys, name)
+	err := draw()
+	if err != nil {
+		return err
+	}
+	rc.keys[name] = k
+	return nil
+}
+
+// save records the keys for the next run.
+func (rc *renderCache) save() error {
+	if rc == nil {
+		return nil
+	}
+	if rc.hits > 0 {
+		fmt.Fprintf(progress, "%d charts unchanged, not rendered again\n", rc.hits)
+	}
+	return writeJSONFile(filepath.Join(outputDir, renderCacheFilename), rc.keys)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderCacheHits(t *testing.T) {
+	dir := t.TempDir()
+	rc := &renderCache{settings: "s", keys: map[string]string{}, data: map[*chart]string{}}
+	ch := &chart{Name: "Repo"}
+	fn := filepath.Join(dir, chartFilename("repo", "commits"))
+	draws := 0
+	draw := func() error {
+		draws++
+		return os.WriteFile(fn, []byte("png"), 0666)
+	}
+	for i := 0; i < 2; i++ {
+		err := rc.render(ch, fn, chartOptions{}, draw)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if draws != 1 || rc.hits != 1 {
+		t.Fatalf("drew %d times with %d hits, want 1 and 1", draws, rc.hits)
+	}
+	if _, ok := rc.keys[filepath.Base(fn)]; !ok {
+		t.Errorf("keys %v not by file name", rc.keys)
+	}
+
+	// A removed file is drawn again, as are other options.
+	os.Remove(fn)
+	err := rc.render(ch, fn, chartOptions{}, draw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.render(ch, fn, chartOptions{window: "monthly"}, draw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draws != 3 || rc.hits != 1 {
+		t.Errorf("drew %d times with %d hits, want 3 and 1", draws, rc.hits)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	onlyRepos = flag.String("only", "", "comma separated patterns; only matching repositories are fetched and rendered, such as 'dde-*' or /^deepin-/")
+	skipRepos = flag.String("skip", "", "comma separated patterns of repositories left out, such as '*-mirror'")
+)
+
+// commandArgs returns the arguments after the command name.
+func commandArgs() []string {
+	if flag.NArg() < 2 {
+		return nil
+	}
+	return flag.Args()[1:]
+}
+
+// selectRepos limits the repositories of cfg to those selected on the
+// command line: listed after the command, read from standard input for
+// "-", and filtered by -only and -skip. Settings of selected repositories
+// in the config are kept, while groups and the baseline, which refer to
+// the configured list, are dropped.
+func (cfg *config) selectRepos(args []string) error {
+	only, err := compileRepoPatterns(*onlyRepos)
+	if err != nil {
+		return fmt.Errorf("-only: %w", err)
+	}
+	skip, err := compileRepoPatterns(*skipRepos)
+	if err != nil {
+		return fmt.Errorf("-skip: %w", err)
+	}
+	repos := cfg.Repos
+	if len(args) > 0 {
+		repos, err = cfg.listRepos(args)
+		if err != nil {
+			return err
+		}
+	} else if len(only) == 0 && len(skip) == 0 {
+		return nil
+	}
+	selected := map[string]*repoConfig{}
+	for u, rc := range repos {
+		if len(only) > 0 && !only.match(u, rc.Name) || skip.match(u, rc.Name) {
+			continue
+		}
+		selected[u] = rc
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no repositories selected")
+	}
+	cfg.Repos = selected
+	cfg.Groups = nil
+	if len(cfg.Baseline) > 0 {
+		if _, err := cfg.lookup(cfg.Baseline); err != nil {
+			cfg.Baseline = ""
+		}
+	}
+	cfg.partial = true
+	return nil
+}
+
+// listRepos returns the repositories listed on the command line.
+func (cfg *config) listRepos(args []string) (map[string]*repoConfig, error) {
+	repos := map[string]*repoConfig{}
+	add := func(u, name string) {
+		rc := cfg.Repos[u]
+		if rc == nil {
+			rc = &repoConfig{Name: nameFromURL(u)}
+		}
+		if len(name) > 0 {
+			rc.Name = name
+		}
+		repos[u] = rc
+	}
+	for _, a := range args {
+		if a != "-" {
+			add(a, "")
+			continue
+		}
+		err := readRepoList(os.Stdin, add)
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %w", err)
+		}
+	}
+	return repos, nil
+}
+
+// repoPatterns match repositories by name or URL.
+type repoPatterns []func(u, name string) bool
+
+// compileRepoPatterns parses comma separated patterns. A pattern between
+// slashes is a regular expression, others are globs matched against the
+// name, ignoring case, and the URL.
+func compileRepoPatterns(s string) (repoPatterns, error) {
+	var ps repoPatterns
+	for _, p := range splitList(s) {
+		if len(p) > 1 && p[0] == '/' && p[len(p)-1] == '/' {
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, err
+			}
+			ps = append(ps, func(u, name string) bool {
+				return re.MatchString(name) || re.MatchString(u)
+			})
+			continue
+		}
+		glob := strings.ToLower(p)
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("%q: %w", p, err)
+		}
+		ps = append(ps, func(u, name string) bool {
+			ok, _ := path.Match(glob, strings.ToLower(name))
+			if !ok {
+				ok, _ = path.Match(glob, u)
+			}
+			return ok
+		})
+	}
+	return ps, nil
+}
+
+func (ps repoPatterns) match(u, name string) bool {
+	for _, m := range ps {
+		if m(u, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// readRepoList reads one repository per line, a URL optionally followed
+// by a name. Blank lines and lines starting with # are skipped.
+func readRepoList(r io.Reader, add func(u, name string)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		u, name := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			u, name = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		add(u, name)
+	}
+	return sc.Err()
+}
+
+// fetchCommand fetches the due repositories into the cache without
+// rendering:
+//
+//	gitgraph fetch [URL... | -]
+func fetchCommand(ctx context.Context) error {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		cfg.TTL = ttl
+	}
+	err = cfg.selectRepos(commandArgs())
+	if err != nil {
+		return err
+	}
+	sum := newSummary()
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	ix, err := openIndex()
+	if err == nil {
+		err = fetch(ctx, cfg, ix, sum)
+	}
+	uerr := unlockCache(lock)
+	if err == nil {
+		err = uerr
+	}
+	if err != nil {
+		sum.Errors = append(sum.Errors, err.Error())
+	}
+	if len(*summaryOut) > 0 {
+		serr := sum.write(*summaryOut)
+		if err == nil {
+			err = serr
+		}
+	}
+	fetched := 0
+	for _, rs := range sum.Repos {
+		if rs.Fetched && len(rs.Error) == 0 {
+			fetched++
+		}
+	}
+	fmt.Printf("fetched %d of %d repositories in %v\n", fetched, len(sum.Repos), time.Since(sum.start).Round(time.Second))
+	return runStatus(sum, err)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// report is the data passed to the index.html template.
+type report struct {
+	Title     string
+	Generated time.Time
+	Repos     []*reportRepo
+	// Combined is the page of the combined charts, if rendered.
+	Combined string
+}
+
+type reportRepo struct {
+	URL     string
+	Name    string
+	Slug    string
+	Page    string
+	Commits int
+	First   time.Time
+	Last    time.Time
+	Health  history.Health
+	Charts  []reportChart
+	Card    string // Social card file name, if rendered.
+	// Unsigned are recent commits without a sign-off, listed only for
+	// repositories that use sign-offs.
+	Unsigned []reportCommit
+	// LargeFiles are the large and LFS files, up to twenty.
+	LargeFiles []largeFile
+	// Stale are the branches without recent commits.
+	Stale []branch
+	// Handoffs are the changes of the author with the most commits by
+	// quarter.
+	Handoffs []history.Handoff
+}
+
+type reportCommit struct {
+	Hash    string
+	When    time.Time
+	Author  string
+	Subject string
+}
+
+type reportChart struct {
+	Metric string
+	File   string
+}
+
+// repoPage is the data passed to the repo.html template.
+type repoPage struct {
+	Title     string
+	Generated time.Time
+	Repo      *reportRepo
+	Repos     []*reportRepo
+}
+
+func newReportRepo(u, slug string, ch *chart) *reportRepo {
+	rr := &reportRepo{
+		URL:     u,
+		Name:    ch.Name,
+		Slug:    slug,
+		Page:    slug + ".html",
+		Commits: len(ch.Commits),
+		Health:  history.NewHealth(ch.Commits, renderTime()),
+	}
+	for _, c := range ch.Commits {
+		if rr.First.IsZero() || c.When.Before(rr.First) {
+			rr.First = c.When
+		}
+		if c.When.After(rr.Last) {
+			rr.Last = c.When
+		}
+	}
+	rr.Unsigned = unsignedCommits(ch.Commits, renderTime())
+	rr.Stale = staleBranches(ch.Branches, renderTime())
+	rr.Handoffs = history.Handoffs(ch.Commits, history.Quarterly)
+	rr.LargeFiles = largeFileList(ch.Commits)
+	if len(rr.LargeFiles) > 20 {
+		rr.LargeFiles = rr.LargeFiles[:20]
+	}
+	return rr
+}
+
+// unsignedCommits returns up to ten of the latest commits of the last 90
+// days without a Signed-off-by trailer, if any commit of the last year has
+// one. Merge commits are not expected to be signed off.
+func unsignedCommits(commits []history.Commit, now time.Time) []reportCommit {
+	const max = 10
+	yearAgo := now.AddDate(-1, 0, 0)
+	recent := now.AddDate(0, 0, -90)
+	uses := false
+	var list []reportCommit
+	for i := range commits {
+		c := &commits[i]
+		if c.When.Before(yearAgo) || c.When.After(now) {
+			continue
+		}
+		if history.SignedOff(c) {
+			uses = true
+			continue
+		}
+		subject := c.Subject()
+		if c.When.Before(recent) || strings.HasPrefix(subject, "Merge ") {
+			continue
+		}
+		list = append(list, reportCommit{Hash: c.Hash, When: c.When, Author: c.Author, Subject: subject})
+	}
+	if !uses {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].When.After(list[j].When) })
+	if len(list) > max {
+		list = list[:max]
+	}
+	return list
+}
+
+// loadTemplate reads name from templateDir if present, or uses the
+// built-in template.
+func loadTemplate(templateDir, name string) (*template.Template, error) {
+	if len(templateDir) > 0 {
+		fn := filepath.Join(templateDir, name)
+		if _, err := os.Stat(fn); err == nil {
+			return template.ParseFiles(fn)
+		}
+	}
+	return template.ParseFS(defaultTemplates, "templates/"+name)
+}
+
+// write renders index.html and one page per repository into dir.
+func (r *report) write(dir, templateDir string) error {
+	index, err := loadTemplate(templateDir, "index.html")
+	if err != nil {
+		return err
+	}
+	page, err := loadTemplate(templateDir, "repo.html")
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(filepath.Join(dir, "index.html"), func(w io.Writer) error {
+		return index.Execute(w, r)
+	})
+	if err != nil {
+		return err
+	}
+	for _, rr := range r.Repos {
+		data := repoPage{
+			Title:     r.Title,
+			Generated: r.Generated,
+			Repo:      rr,
+			Repos:     r.Repos,
+		}
+		err = writeFileAtomic(filepath.Join(dir, rr.Page), func(w io.Writer) error {
+			return page.Execute(w, data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// fetchRebuilding fetches u into ch with fetchRepo. If the upstream
+// history was rewritten, by a force push to the default branch, the
+// cached commits, branches and mirror of u are dropped and it is fetched
+// again from scratch, so nothing read from the old history remains.
+func fetchRebuilding(ctx context.Context, cfg *config, u string, ch *chart, now time.Time) error {
+	ref, tip := ch.Ref, ch.Tip
+	err := fetchRepo(ctx, cfg, u, ch, now)
+	if err != nil || !rewritten(ch, ref, tip) {
+		return err
+	}
+	short := tip
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	fmt.Fprintf(progress, "%s: history rewritten upstream, %s is gone; fetching again\n", u, short)
+	if st := statsOf(ctx); st != nil {
+		st.Rewritten = true
+	}
+	*ch = *cfg.chart(u)
+	if *useMirrors && !isBundle(u) {
+		err = os.RemoveAll(mirrorPath(u))
+		if err != nil {
+			return err
+		}
+	}
+	return fetchRepo(ctx, cfg, u, ch, now)
+}
+
+// rewritten reports if the commits of ch, just read from ref, no longer
+// reach tip, the commit the cache was last read from on the same branch.
+func rewritten(ch *chart, ref, tip string) bool {
+	if len(tip) == 0 || ref != ch.Ref || tip == ch.Tip {
+		return false
+	}
+	return !hasCommit(ch.Commits, tip)
+}
+
+func hasCommit(commits []history.Commit, hash string) bool {
+	for _, c := range commits {
+		if c.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
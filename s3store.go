@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Store keeps the cache in an S3 bucket, or any service with the same
+// API, signing requests with AWS signature version 4. Credentials and
+// region are read from the usual AWS environment variables;
+// AWS_ENDPOINT_URL selects another service, such as MinIO.
+type s3Store struct {
+	endpoint *url.URL // With the bucket in the path.
+	prefix   string
+	region   string
+	key      string
+	secret   string
+	token    string
+}
+
+func openS3Store(u string) (*s3Store, error) {
+	rest := strings.TrimPrefix(u, "s3://")
+	bucket, prefix := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		bucket, prefix = rest[:i], strings.Trim(rest[i+1:], "/")
+	}
+	if len(bucket) == 0 {
+		return nil, fmt.Errorf("%q has no bucket", u)
+	}
+	if len(prefix) > 0 {
+		prefix += "/"
+	}
+	st := &s3Store{
+		prefix: prefix,
+		region: os.Getenv("AWS_REGION"),
+		key:    credential("AWS_ACCESS_KEY_ID"),
+		secret: credential("AWS_SECRET_ACCESS_KEY"),
+		token:  credential("AWS_SESSION_TOKEN"),
+	}
+	if len(st.region) == 0 {
+		st.region = "us-east-1"
+	}
+	if len(st.key) == 0 || len(st.secret) == 0 {
+		return nil, fmt.Errorf("%s needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", u)
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if len(endpoint) == 0 {
+		endpoint = "https://s3." + st.region + ".amazonaws.com"
+	}
+	var err error
+	st.endpoint, err = url.Parse(strings.TrimSuffix(endpoint, "/") + "/" + bucket)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// do sends a signed request for the object key, or the bucket if key is
+// empty, with the query q and body b.
+func (st *s3Store) do(method, key string, q url.Values, b []byte) (*http.Response, error) {
+	u := *st.endpoint
+	if len(key) > 0 {
+		u.Path += "/" + key
+	}
+	u.RawQuery = strings.Replace(q.Encode(), "+", "%20", -1)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	st.sign(req, b, time.Now().UTC())
+	return http.DefaultClient.Do(req)
+}
+
+// sign adds the signature version 4 headers to req.
+func (st *s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if len(st.token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", st.token)
+	}
+
+	var names []string
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := day + "/" + st.region + "/s3/aws4_request"
+	creq := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(creq[:])
+
+	mac := func(key []byte, v string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(v))
+		return h.Sum(nil)
+	}
+	k := mac([]byte("AWS4"+st.secret), day)
+	k = mac(k, st.region)
+	k = mac(k, "s3")
+	k = mac(k, "aws4_request")
+	sig := hex.EncodeToString(mac(k, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+st.key+"/"+scope+", SignedHeaders="+signed+", Signature="+sig)
+}
+
+// check returns an error for a failed response, closing its body.
+func (st *s3Store) check(resp *http.Response, name string) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return notExist(name)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s: %s: %s", name, resp.Status, bytes.TrimSpace(msg))
+}
+
+func (st *s3Store) Get(name string) ([]byte, error) {
+	resp, err := st.do("GET", st.prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = st.check(resp, name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (st *s3Store) Put(name string, b []byte) error {
+	resp, err := st.do("PUT", st.prefix+name, nil, b)
+	if err != nil {
+		return err
+	}
+	err = st.check(resp, name)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (st *s3Store) Delete(name string) error {
+	resp, err := st.do("DELETE", st.prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	err = st.check(resp, name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (st *s3Store) List(prefix string) ([]string, error) {
+	var list []string
+	q := url.Values{"list-type": {"2"}, "prefix": {st.prefix + prefix}}
+	for {
+		resp, err := st.do("GET", "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		err = st.check(resp, prefix)
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list %s: %w", prefix, err)
+		}
+		for _, c := range res.Contents {
+			list = append(list, strings.TrimPrefix(c.Key, st.prefix))
+		}
+		if !res.IsTruncated {
+			break
+		}
+		q.Set("continuation-token", res.NextContinuationToken)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (st *s3Store) Modified(name string) (time.Time, error) {
+	resp, err := st.do("HEAD", st.prefix+name, nil, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	err = st.check(resp, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp.Body.Close()
+	return http.ParseTime(resp.Header.Get("Last-Modified"))
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+var (
+	listenAddr = flag.String("addr", ":8080", "listen address of the serve command")
+	interval   = flag.Duration("interval", 0, "run every interval while serving, such as 6h; zero only serves")
+)
+
+// serve serves the output directory and a JSON API over the cached
+// commits. The cache is re-read when another run updates it.
+func serve(ctx context.Context) error {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	_, err = cfg.metrics(nil)
+	if err != nil {
+		return err
+	}
+	notes, err := loadAnnotations(*annotationsFile)
+	if err != nil {
+		return err
+	}
+	auth, err := newAuthenticator(ctx, cfg.Auth)
+	if err != nil {
+		return err
+	}
+	s := &server{cfg: cfg, notes: notes, wake: make(chan struct{}, 1)}
+	_, err = s.data()
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/repos", s.repos)
+	mux.HandleFunc("/api/repos/", s.series)
+	mux.HandleFunc("/chart/", s.chartImage)
+	s.grafana(mux)
+	mux.HandleFunc("/feed.atom", s.feed)
+	mux.HandleFunc("/feed/", s.feed)
+	h := newHub()
+	go s.watch(ctx, h)
+	mux.HandleFunc("/events", s.events(h))
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/metrics", s.metrics)
+	secret := credential("PUSH_SECRET")
+	if len(secret) > 0 {
+		mux.HandleFunc("/hooks/push", s.pushHook(secret))
+	}
+	mux.Handle("/", http.FileServer(http.Dir(outputDir)))
+
+	var handler http.Handler = mux
+	if auth != nil {
+		handler = auth.wrap(mux)
+	}
+	hs := &http.Server{
+		Addr:    *listenAddr,
+		Handler: handler,
+	}
+	if *interval > 0 || len(secret) > 0 {
+		go s.daemon(ctx)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		fmt.Println("listening on", *listenAddr)
+		errc <- hs.ListenAndServe()
+	}()
+	select {
+	case err = <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return hs.Shutdown(sctx)
+}
+
+// daemon runs the fetch and render cycle every interval, if set, and
+// after push webhooks, one run at a time.
+func (s *server) daemon(ctx context.Context) {
+	var tick <-chan time.Time
+	if *interval > 0 {
+		s.cycle(ctx)
+		t := time.NewTicker(*interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			s.cycle(ctx)
+		case <-s.wake:
+			s.cycle(withPushed(ctx, s.takePushed()))
+		}
+	}
+}
+
+// cycle runs once and sends the digest email when it is due.
+func (s *server) cycle(ctx context.Context) {
+	err := run(ctx)
+	if err != nil {
+		log.Print(err)
+	}
+	s.mu.Lock()
+	s.lastRun = time.Now()
+	s.lastErr = ""
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+	s.mu.Unlock()
+	if s.cfg.Email != nil && !*offline {
+		err = sendDigest(s.cfg, false)
+		if err != nil {
+			log.Print("digest: ", err)
+		}
+	}
+}
+
+type server struct {
+	cfg   *config
+	notes []annotation
+
+	mu      sync.Mutex
+	modTime time.Time
+	current *serverData
+	lastRun time.Time // Of the daemon.
+	lastErr string
+	pending map[string]bool // Pushed repositories.
+	wake    chan struct{}
+}
+
+// healthz reports whether the cache can be read, with the result of the
+// last run with -interval. Failed runs do not make it unhealthy, as
+// restarting would not fix them.
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	var st struct {
+		Status    string
+		LastRun   time.Time `json:",omitempty"`
+		LastError string    `json:",omitempty"`
+	}
+	st.Status = "ok"
+	code := http.StatusOK
+	_, err := s.data()
+	if err != nil {
+		st.Status = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+	s.mu.Lock()
+	st.LastRun, st.LastError = s.lastRun, s.lastErr
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(st)
+}
+
+// serverData is an immutable snapshot of the cache.
+type serverData struct {
+	charts FileType
+	slugs  map[string]string // URL to slug.
+	bySlug map[string]string // Slug to URL.
+}
+
+// data returns the current cache, reading it again if the index changed.
+func (s *server) data() (*serverData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := cacheStore()
+	if err != nil {
+		return nil, err
+	}
+	mod, err := st.Modified(indexFilename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if s.current != nil && (mod.IsZero() || mod.Equal(s.modTime)) {
+		return s.current, nil
+	}
+	charts := s.cfg.charts()
+	err = charts.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range charts {
+		s.cfg.sanitizeDates(ch, time.Now())
+	}
+	d := &serverData{
+		charts: charts,
+		slugs:  charts.slugs(),
+		bySlug: map[string]string{},
+	}
+	for u, slug := range d.slugs {
+		d.bySlug[slug] = u
+	}
+	if !mod.IsZero() {
+		s.modTime = mod
+	}
+	s.current = d
+	return d, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	e.Encode(v)
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	http.Error(w, err.Error(), code)
+}
+
+type apiRepo struct {
+	URL     string
+	Name    string
+	Slug    string
+	Fetched time.Time
+	Commits int
+	First   time.Time
+	Last    time.Time
+	Health  int
+	Series  string
+}
+
+func (s *server) apiRepos() ([]apiRepo, error) {
+	d, err := s.data()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]apiRepo, 0, len(d.charts))
+	for _, u := range d.charts.urls() {
+		ch := d.charts[u]
+		slug := d.slugs[u]
+		rr := newReportRepo(u, slug, ch)
+		list = append(list, apiRepo{
+			URL:     u,
+			Name:    ch.Name,
+			Slug:    slug,
+			Fetched: ch.Fetched,
+			Commits: rr.Commits,
+			First:   rr.First,
+			Last:    rr.Last,
+			Health:  rr.Health.Score,
+			Series:  "/api/repos/" + slug + "/series",
+		})
+	}
+	return list, nil
+}
+
+func (s *server) repos(w http.ResponseWriter, r *http.Request) {
+	list, err := s.apiRepos()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, list)
+}
+
+type apiSeries struct {
+	URL    string
+	Name   string
+	Metric string
+	Window string
+	Points []history.Point
+}
+
+// series handles /api/repos/{slug}/series?metric=commits&window=weekly&since=2020-01-01&until=2021-01-01.
+func (s *server) series(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/repos/"), "/")
+	if len(parts) != 2 || parts[1] != "series" {
+		http.NotFound(w, r)
+		return
+	}
+	d, err := s.data()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	u, ok := d.bySlug[parts[0]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	q, err := parseSeriesQuery(r.URL.Query())
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	ch := d.charts[u]
+	writeJSON(w, apiSeries{
+		URL:    u,
+		Name:   ch.Name,
+		Metric: q.metric,
+		Window: q.window,
+		Points: q.points(ch),
+	})
+}
+
+type seriesQuery struct {
+	metric string
+	window string
+	since  time.Time
+	until  time.Time
+}
+
+func parseSeriesQuery(v map[string][]string) (seriesQuery, error) {
+	get := func(key, def string) string {
+		if list := v[key]; len(list) > 0 && len(list[0]) > 0 {
+			return list[0]
+		}
+		return def
+	}
+	q := seriesQuery{
+		metric: get("metric", "commits"),
+		window: get("window", "weekly"),
+		until:  time.Now(),
+	}
+	if history.New(q.metric) == nil {
+		return q, fmt.Errorf("unknown metric %q", q.metric)
+	}
+	if history.LookupWindow(q.window) == nil {
+		return q, fmt.Errorf("unknown window %q, have %s", q.window, strings.Join(history.WindowNames(), ", "))
+	}
+	var err error
+	if s := get("since", ""); len(s) > 0 {
+		q.since, err = parseTime(s)
+		if err != nil {
+			return q, err
+		}
+	}
+	if s := get("until", ""); len(s) > 0 {
+		q.until, err = parseTime(s)
+		if err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+func (q seriesQuery) points(ch *chart) []history.Point {
+	list := make([]history.Commit, 0, len(ch.Commits))
+	for _, c := range ch.Commits {
+		if c.When.Before(q.since) || !c.When.Before(q.until) {
+			continue
+		}
+		list = append(list, c)
+	}
+	points := history.Series(list, history.LookupWindow(q.window), ch.metric(q.metric))
+	if points == nil {
+		points = []history.Point{}
+	}
+	return points
+}
+
+// parseTime accepts a date or an RFC 3339 time.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, errors.New("invalid time " + s + ", use 2006-01-02 or RFC 3339")
+	}
+	return t, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseSeriesQuery(t *testing.T) {
+	tests := []struct {
+		query  string
+		metric string
+		window string
+		since  time.Time
+		until  time.Time // Zero for about now.
+		err    bool
+	}{
+		{query: "", metric: "commits", window: "weekly"},
+		{query: "metric=commits&window=monthly", metric: "commits", window: "monthly"},
+		{query: "metric=&window=", metric: "commits", window: "weekly"},
+		{
+			query:  "since=2020-01-01&until=2021-06-30T12:00:00Z",
+			metric: "commits", window: "weekly",
+			since: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+			until: time.Date(2021, time.June, 30, 12, 0, 0, 0, time.UTC),
+		},
+		{query: "metric=nope", err: true},
+		{query: "window=fortnightly", err: true},
+		{query: "since=yesterday", err: true},
+		{query: "until=2021-13-01", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			v, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := parseSeriesQuery(v)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parsed %+v", q)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.metric != tt.metric || q.window != tt.window || !q.since.Equal(tt.since) {
+				t.Errorf("got %+v", q)
+			}
+			if tt.until.IsZero() {
+				if time.Since(q.until) > time.Minute {
+					t.Errorf("until %v, want now", q.until)
+				}
+			} else if !q.until.Equal(tt.until) {
+				t.Errorf("until %v, want %v", q.until, tt.until)
+			}
+		})
+	}
+}
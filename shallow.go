@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+var unshallow = flag.Bool("unshallow", false, "fetch the full history of shallow mirrors with the cli backend instead of charting the truncated history")
+
+// oldestCommit returns the time of the oldest of commits.
+func oldestCommit(commits []history.Commit) time.Time {
+	var t time.Time
+	for _, c := range commits {
+		if t.IsZero() || c.When.Before(t) {
+			t = c.When
+		}
+	}
+	return t
+}
+
+// noteTruncated records in ch whether its history stops early, because
+// the upstream is shallow or grafted.
+func noteTruncated(u string, ch *chart, truncated bool) {
+	ch.Truncated = time.Time{}
+	if !truncated {
+		return
+	}
+	ch.Truncated = oldestCommit(ch.Commits)
+	fmt.Fprintf(progress, "%s: history truncated at %s\n", u, ch.Truncated.Format("2006-01-02"))
+}
+
+// isShallowCLI reports if the repository in dir is a shallow clone.
+func isShallowCLI(ctx context.Context, dir string) (bool, error) {
+	out, err := gitCommand(ctx, "-C", dir, "rev-parse", "--is-shallow-repository").Output()
+	if err != nil {
+		return false, fmt.Errorf("git rev-parse: %w", err)
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+// truncatedNote marks where the history of ch stops, if it does.
+func truncatedNote(ch *chart) []annotation {
+	if ch.Truncated.IsZero() {
+		return nil
+	}
+	return []annotation{{Time: ch.Truncated, Label: "history truncated at " + ch.Truncated.Format("2006-01-02")}}
+}
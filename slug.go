@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	manifestFilename = "manifest.json"
+	maxSlugLen       = 100
+)
+
+// slugify turns a display name into a file name that is valid on common
+// file systems. Letters and digits of any script are kept as is.
+func slugify(name string) string {
+	var b strings.Builder
+	var pending rune
+	for _, r := range name {
+		var sep rune
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.Is(unicode.Mn, r):
+		case r == '-', r == '_', r == '.':
+			sep = r
+		case unicode.IsSpace(r):
+			sep = '_'
+		default:
+			sep = '-'
+		}
+		if sep != 0 {
+			if pending == 0 || pending == '-' {
+				pending = sep
+			}
+			continue
+		}
+		if pending != 0 && b.Len() > 0 {
+			b.WriteRune(pending)
+		}
+		pending = 0
+		if b.Len()+utf8.RuneLen(r) > maxSlugLen {
+			break
+		}
+		b.WriteRune(r)
+	}
+	s := b.String()
+	if len(s) == 0 {
+		return "chart"
+	}
+	if reservedName(s) {
+		s += "_"
+	}
+	return s
+}
+
+// reservedName reports if s is a device name on Windows.
+func reservedName(s string) bool {
+	switch strings.ToUpper(s) {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(s) == 4 {
+		switch strings.ToUpper(s[:3]) {
+		case "COM", "LPT":
+			return s[3] >= '1' && s[3] <= '9'
+		}
+	}
+	return false
+}
+
+// slugger hands out unique slugs. Comparison ignores case so output works
+// on case-insensitive file systems.
+type slugger map[string]bool
+
+// reservedSlugs are the names, without extension, of the output files
+// that belong to no repository.
+var reservedSlugs = []string{"index", combinedSlug, "heatmap", "correlation", "manifest", "summary"}
+
+// newSlugger returns a slugger that never hands out a reserved slug, so a
+// repository named "index" does not overwrite the report index.
+func newSlugger() slugger {
+	sg := slugger{}
+	for _, s := range reservedSlugs {
+		sg[s] = true
+	}
+	return sg
+}
+
+func (sg slugger) unique(name string) string {
+	base := slugify(name)
+	s := base
+	for i := 2; sg[strings.ToLower(s)]; i++ {
+		s = base + "-" + strconv.Itoa(i)
+	}
+	sg[strings.ToLower(s)] = true
+	return s
+}
+
+type manifestEntry struct {
+	URL   string `json:",omitempty"` // Empty for groups.
+	Name  string
+	Slug  string
+	Files []string
+	// Ref, Tip and Fetched are the branch, commit and time of the data,
+	// and Tool the version of gitgraph that read it.
+	Ref     string    `json:",omitempty"`
+	Tip     string    `json:",omitempty"`
+	Fetched time.Time `json:",omitempty"`
+	Tool    string    `json:",omitempty"`
+}
+
+func writeManifest(location string, list []manifestEntry) error {
+	return writeFileAtomic(location, func(w io.Writer) error {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		return e.Encode(list)
+	})
+}
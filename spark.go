@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var termChart = flag.String("term", "", "also print a sparkline of weekly commits per repository: \"blocks\" or \"braille\"")
+
+// sparkWeeks is the number of weeks shown in the terminal UI.
+const sparkWeeks = 26
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// weeklyCounts returns the commits of each of the weeks before now.
+func weeklyCounts(ch *chart, now time.Time, weeks int) []int {
+	const week = 7 * 24 * time.Hour
+	counts := make([]int, weeks)
+	start := now.Add(-time.Duration(weeks) * week)
+	for _, c := range ch.Commits {
+		if c.When.Before(start) || !c.When.Before(now) {
+			continue
+		}
+		counts[int(c.When.Sub(start)/week)]++
+	}
+	return counts
+}
+
+func maxCount(counts []int) int {
+	max := 0
+	for _, n := range counts {
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// sparkline draws counts with one block character each. Empty weeks are
+// blank.
+func sparkline(counts []int) string {
+	max := maxCount(counts)
+	var b strings.Builder
+	for _, n := range counts {
+		if n == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkBlocks[(n*len(sparkBlocks)-1)/max])
+	}
+	return b.String()
+}
+
+// brailleLine draws counts with two weeks per braille character, each as a
+// column of up to four dots.
+func brailleLine(counts []int) string {
+	// Dot bits of the left and right column, from the bottom up.
+	left := []rune{0x40, 0x04, 0x02, 0x01}
+	right := []rune{0x80, 0x20, 0x10, 0x08}
+	max := maxCount(counts)
+	level := func(n int) int {
+		if n == 0 {
+			return 0
+		}
+		return (n*4-1)/max + 1
+	}
+	var b strings.Builder
+	for i := 0; i < len(counts); i += 2 {
+		r := rune(0x2800)
+		for d := 0; d < level(counts[i]); d++ {
+			r |= left[d]
+		}
+		if i+1 < len(counts) {
+			for d := 0; d < level(counts[i+1]); d++ {
+				r |= right[d]
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// termLine formats a repository for -term, fitting the terminal width.
+func termLine(ch *chart, now time.Time) string {
+	const nameWidth = 24
+	width, _, err := termSize(os.Stdout)
+	if err != nil || width < nameWidth+20 {
+		width = 80
+	}
+	cols := width - nameWidth - 14
+	spark := sparkline(weeklyCounts(ch, now, cols))
+	if *termChart == "braille" {
+		spark = brailleLine(weeklyCounts(ch, now, 2*cols))
+	}
+	return fmt.Sprintf("%-*s %s %6d", nameWidth, fitWidth(ch.Name, nameWidth), spark, len(ch.Commits))
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var storeURL = flag.String("store", "", "where the cached commits are kept: the cache directory if empty, s3://bucket/prefix, sqlite:FILE or postgres://..., so several machines can share them")
+
+// Store holds the commit cache: the index and a shard per repository, by
+// slash separated names such as "index.json" or "repos/ab/abcd.json".
+// Mirrors, the lock and the other state stay in the cache directory.
+type Store interface {
+	// Get returns the content of name, or an error for which
+	// os.IsNotExist is true if there is none.
+	Get(name string) ([]byte, error)
+	// Put replaces the content of name.
+	Put(name string, b []byte) error
+	// Delete removes name if it exists.
+	Delete(name string) error
+	// List returns the names starting with prefix, sorted.
+	List(prefix string) ([]string, error)
+	// Modified returns the time name last changed.
+	Modified(name string) (time.Time, error)
+}
+
+var openedStore Store
+
+// cacheStore returns the store selected by -store, opening it on first
+// use, after the config may have moved the cache directory.
+func cacheStore() (Store, error) {
+	if openedStore != nil {
+		return openedStore, nil
+	}
+	u := *storeURL
+	var st Store
+	var err error
+	switch {
+	case len(u) == 0:
+		return fileStore(cacheDir), nil
+	case strings.HasPrefix(u, "s3://"):
+		st, err = openS3Store(u)
+	case strings.HasPrefix(u, "sqlite:"):
+		st, err = openSQLStore(strings.TrimPrefix(u, "sqlite:"), "BLOB", "sqlite", "sqlite", "sqlite3")
+	case strings.HasPrefix(u, "postgres://"), strings.HasPrefix(u, "postgresql://"):
+		st, err = openSQLStore(u, "BYTEA", "postgres", "pgx", "postgres")
+	default:
+		return nil, fmt.Errorf("-store %q: use s3://, sqlite: or postgres://", u)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("-store: %w", err)
+	}
+	openedStore = st
+	return st, nil
+}
+
+// sharedStore reports if the cache is kept outside the cache directory,
+// where the cache lock does not reach.
+func sharedStore() bool {
+	return len(*storeURL) > 0
+}
+
+func notExist(name string) error {
+	return &fs.PathError{Op: "get", Path: name, Err: fs.ErrNotExist}
+}
+
+// fileStore keeps the cache in a directory.
+type fileStore string
+
+func (d fileStore) path(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+func (d fileStore) Get(name string) ([]byte, error) {
+	return os.ReadFile(d.path(name))
+}
+
+func (d fileStore) Put(name string, b []byte) error {
+	return writeFileAtomic(d.path(name), func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+func (d fileStore) Delete(name string) error {
+	err := os.Remove(d.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (d fileStore) List(prefix string) ([]string, error) {
+	var list []string
+	// Only the index and the shards are walked, never the mirrors and
+	// other state in the cache directory.
+	roots := []string{indexFilename, shardDir}
+	if dir := path.Dir(prefix); dir == shardDir || strings.HasPrefix(dir, shardDir+"/") {
+		roots = []string{dir}
+	}
+	walk := func(p string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(string(d), p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		// Temporary files of writes in progress start with a dot.
+		if strings.HasPrefix(name, prefix) && !strings.HasPrefix(filepath.Base(p), ".") {
+			list = append(list, name)
+		}
+		return nil
+	}
+	for _, root := range roots {
+		err := filepath.Walk(d.path(root), walk)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (d fileStore) Modified(name string) (time.Time, error) {
+	fi, err := os.Stat(d.path(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// sqlStore keeps the cache in a table of a database. The drivers are
+// linked in by the sqlite and postgres build tags.
+type sqlStore struct {
+	db *sql.DB
+}
+
+// openSQLStore opens dsn with the first of the drivers registered and
+// creates the table, with a data column of blobType, if needed. tag is
+// the build tag that links a driver in.
+func openSQLStore(dsn, blobType, tag string, drivers ...string) (*sqlStore, error) {
+	have := map[string]bool{}
+	for _, d := range sql.Drivers() {
+		have[d] = true
+	}
+	for _, d := range drivers {
+		if !have[d] {
+			continue
+		}
+		db, err := sql.Open(d, dsn)
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS gitgraph_cache (
+	name TEXT PRIMARY KEY,
+	data ` + blobType + ` NOT NULL,
+	modified BIGINT NOT NULL
+)`)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &sqlStore{db: db}, nil
+	}
+	return nil, fmt.Errorf("no %s database driver is linked in; build gitgraph with -tags %s", strings.Join(drivers, " or "), tag)
+}
+
+func (s *sqlStore) Get(name string) ([]byte, error) {
+	var b []byte
+	err := s.db.QueryRow(`SELECT data FROM gitgraph_cache WHERE name = $1`, name).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notExist(name)
+	}
+	return b, err
+}
+
+func (s *sqlStore) Put(name string, b []byte) error {
+	_, err := s.db.Exec(`INSERT INTO gitgraph_cache (name, data, modified) VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET data = excluded.data, modified = excluded.modified`, name, b, time.Now().UnixNano())
+	return err
+}
+
+func (s *sqlStore) Delete(name string) error {
+	_, err := s.db.Exec(`DELETE FROM gitgraph_cache WHERE name = $1`, name)
+	return err
+}
+
+func (s *sqlStore) List(prefix string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM gitgraph_cache ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []string
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(name, prefix) {
+			list = append(list, name)
+		}
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) Modified(name string) (time.Time, error) {
+	var ns int64
+	err := s.db.QueryRow(`SELECT modified FROM gitgraph_cache WHERE name = $1`, name).Scan(&ns)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, notExist(name)
+	}
+	return time.Unix(0, ns), err
+}
@@ -0,0 +1,7 @@
+//go:build postgres
+// +build postgres
+
+package main
+
+// The PostgreSQL driver for -store postgres://...
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,8 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+// The SQLite driver for -store sqlite:FILE, pure Go so the build needs
+// no C compiler.
+import _ "modernc.org/sqlite"
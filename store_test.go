@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileStoreList(t *testing.T) {
+	d := fileStore(t.TempDir())
+	for _, name := range []string{"index.json", "repos/ab/abcd.json", "repos/cd/cdef.json", "mirrors/ab/HEAD", "previous.json"} {
+		err := d.Put(name, []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A write in progress.
+	err := os.WriteFile(filepath.Join(string(d), "repos", "ab", ".abcd.json.1.tmp"), nil, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"index.json", "repos/ab/abcd.json", "repos/cd/cdef.json"}},
+		{"repos/", []string{"repos/ab/abcd.json", "repos/cd/cdef.json"}},
+		{"repos/ab/", []string{"repos/ab/abcd.json"}},
+		{"mirrors/", nil},
+	}
+	for _, tt := range tests {
+		got, err := d.List(tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var styleFlag = flag.String("style", "", "how metric charts draw their values: line, bar, step or area; overrides the config Style")
+
+// chartStyles are the values of -style and the config Style.
+var chartStyles = []string{"line", "bar", "step", "area"}
+
+// chartStyle returns the style of the charts of u: -style if set, else
+// the repository or global config, else line.
+func (cfg *config) chartStyle(u string) string {
+	switch {
+	case len(*styleFlag) > 0:
+		return *styleFlag
+	case cfg.Repos[u] != nil && len(cfg.Repos[u].Style) > 0:
+		return cfg.Repos[u].Style
+	case len(cfg.Style) > 0:
+		return cfg.Style
+	}
+	return "line"
+}
+
+func checkChartStyle(s string) error {
+	if len(s) == 0 {
+		return nil
+	}
+	for _, name := range chartStyles {
+		if s == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown style %q, use line, bar, step or area", s)
+}
+
+// addSeries adds data to p in the style of opt, in the color of the
+// style or clr. It returns the plotters to show in the legend.
+func addSeries(p *plot.Plot, data plotter.XYs, w history.Window, opt chartOptions, clr color.Color) ([]plot.Thumbnailer, error) {
+	if opt.style.Color != nil {
+		clr = opt.style.Color
+	}
+	style := opt.chartStyle
+	if len(style) == 0 {
+		style = "line"
+	}
+	if style != "line" {
+		// Windows without commits are drawn as zero rather than bridged.
+		data = fillWindows(data, w)
+	}
+	switch style {
+	case "line":
+		line, points, err := plotter.NewLinePoints(data)
+		if err != nil {
+			return nil, err
+		}
+		line.Color = clr
+		line.Dashes = opt.style.Dashes
+		points.Shape = draw.CircleGlyph{}
+		points.Color = clr
+		p.Add(line, points)
+		return []plot.Thumbnailer{line, points}, nil
+	case "bar":
+		width := (7 * 24 * time.Hour).Seconds()
+		if len(data) > 1 {
+			width = (data[len(data)-1].X - data[0].X) / float64(len(data)-1)
+		}
+		b := &bars{XYs: data, Width: width, Up: clr, Down: clr}
+		p.Add(b)
+		return []plot.Thumbnailer{b}, nil
+	case "step", "area":
+		line, err := plotter.NewLine(data)
+		if err != nil {
+			return nil, err
+		}
+		line.Color = clr
+		line.Dashes = opt.style.Dashes
+		if style == "step" {
+			line.StepStyle = plotter.PostStep
+		} else {
+			r, g, b, _ := clr.RGBA()
+			line.FillColor = color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0x60}
+		}
+		p.Add(line)
+		return []plot.Thumbnailer{line}, nil
+	}
+	return nil, checkChartStyle(style)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// summary describes a run for consumption by scripts and CI jobs.
+type summary struct {
+	Start   time.Time
+	Seconds float64
+	Repos   []*repoSummary
+	Alerts  []alert
+	Errors  []string
+
+	start time.Time
+	index map[string]*repoSummary
+}
+
+type repoSummary struct {
+	URL           string
+	Name          string
+	Fetched       bool
+	NewCommits    int
+	Commits       int
+	Charts        []string
+	FetchSeconds  float64
+	RenderSeconds float64
+	Change        *change `json:",omitempty"`
+	// CloneSeconds, UpdateSeconds and WalkSeconds split FetchSeconds
+	// into cloning, fetching into the mirror and reading the commits.
+	CloneSeconds  float64 `json:",omitempty"`
+	UpdateSeconds float64 `json:",omitempty"`
+	WalkSeconds   float64 `json:",omitempty"`
+	// FetchedBytes is the growth of the mirror, close to the bytes
+	// transferred.
+	FetchedBytes int64 `json:",omitempty"`
+	// Rewritten is set if the upstream history was rewritten, so the
+	// cached commits were dropped and the repository fetched again.
+	Rewritten bool `json:",omitempty"`
+	// Anomalies are the windows of the last 90 days far from the usual,
+	// with -anomalies.
+	Anomalies []anomaly `json:",omitempty"`
+	// BadDates are the commits with implausible dates left out of the
+	// charts or clamped.
+	BadDates *dateReport `json:",omitempty"`
+	// Skipped is why the repository was not fetched for a quota; its
+	// cached commits are charted.
+	Skipped string `json:",omitempty"`
+	Error   string `json:",omitempty"`
+
+	sum *summary
+}
+
+func newSummary() *summary {
+	now := time.Now()
+	return &summary{
+		Start:  now.UTC(),
+		Repos:  []*repoSummary{},
+		Alerts: []alert{},
+		Errors: []string{},
+		start:  now,
+		index:  map[string]*repoSummary{},
+	}
+}
+
+// repo returns the entry for u, adding it if needed.
+func (s *summary) repo(u, name string) *repoSummary {
+	rs, ok := s.index[u]
+	if !ok {
+		rs = &repoSummary{URL: u, Name: name, Charts: []string{}, sum: s}
+		s.index[u] = rs
+		s.Repos = append(s.Repos, rs)
+	}
+	return rs
+}
+
+func (rs *repoSummary) fail(err error) {
+	if len(rs.Error) == 0 {
+		rs.Error = err.Error()
+	}
+	rs.sum.Errors = append(rs.sum.Errors, rs.URL+": "+err.Error())
+}
+
+func (s *summary) failed() int {
+	n := 0
+	for _, rs := range s.Repos {
+		if len(rs.Error) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// write writes the summary to location, or to stdout if location is "-".
+func (s *summary) write(location string) error {
+	s.Seconds = time.Since(s.start).Seconds()
+	enc := func(w io.Writer) error {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		return e.Encode(s)
+	}
+	if location == "-" {
+		return enc(os.Stdout)
+	}
+	return writeFileAtomic(location, enc)
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("terminal UI is not supported on this system")
+
+func makeRaw(in *os.File) (func(), error) { return nil, errNoTerminal }
+
+func termSize(out *os.File) (int, int, error) { return 0, 0, errNoTerminal }
@@ -0,0 +1,53 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw turns off line buffering and echo on the terminal in and returns
+// a function restoring the previous state. Signals such as Ctrl-C still
+// work.
+func makeRaw(in *os.File) (func(), error) {
+	fd := in.Fd()
+	var old syscall.Termios
+	err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old))
+	if err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	err = ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(fd, ioctlSetTermios, unsafe.Pointer(&old))
+	}, nil
+}
+
+// termSize returns the columns and rows of the terminal out.
+func termSize(out *os.File) (int, int, error) {
+	var ws struct {
+		Row, Col, X, Y uint16
+	}
+	err := ioctl(out.Fd(), syscall.TIOCGWINSZ, unsafe.Pointer(&ws))
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
@@ -0,0 +1,82 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+const (
+	enableEchoInput                 = 0x0004
+	enableLineInput                 = 0x0002
+	enableVirtualTerminalInput      = 0x0200
+	enableVirtualTerminalProcessing = 0x0004
+)
+
+func getConsoleMode(f *os.File) (uint32, error) {
+	var mode uint32
+	r, _, err := procGetConsoleMode.Call(f.Fd(), uintptr(unsafe.Pointer(&mode)))
+	if r == 0 {
+		return 0, err
+	}
+	return mode, nil
+}
+
+func setConsoleMode(f *os.File, mode uint32) error {
+	r, _, err := procSetConsoleMode.Call(f.Fd(), uintptr(mode))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// makeRaw turns off line buffering and echo on the console in, enables
+// escape sequences on stdout, and returns a function restoring both.
+func makeRaw(in *os.File) (func(), error) {
+	oldIn, err := getConsoleMode(in)
+	if err != nil {
+		return nil, err
+	}
+	oldOut, err := getConsoleMode(os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	err = setConsoleMode(in, (oldIn&^(enableEchoInput|enableLineInput))|enableVirtualTerminalInput)
+	if err != nil {
+		return nil, err
+	}
+	err = setConsoleMode(os.Stdout, oldOut|enableVirtualTerminalProcessing)
+	if err != nil {
+		setConsoleMode(in, oldIn)
+		return nil, err
+	}
+	return func() {
+		setConsoleMode(in, oldIn)
+		setConsoleMode(os.Stdout, oldOut)
+	}, nil
+}
+
+// termSize returns the columns and rows of the console window.
+func termSize(out *os.File) (int, int, error) {
+	var info struct {
+		Size, Cursor          [2]int16
+		Attributes            uint16
+		Left, Top, Right, Bot int16
+		MaxSize               [2]int16
+	}
+	r, _, err := procGetConsoleScreenBufferInfo.Call(out.Fd(), uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0, 0, err
+	}
+	return int(info.Right-info.Left) + 1, int(info.Bot-info.Top) + 1, nil
+}
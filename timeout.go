@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// defaultTimeout limits fetching a repository when neither -timeout nor
+// the config sets a limit. Cloning very large repositories may need more.
+const defaultTimeout = 30 * time.Minute
+
+var (
+	fetchTimeout duration
+	grace        = flag.Duration("grace", 3*time.Second, "time to finish the current step after an interrupt before exiting")
+)
+
+func init() {
+	flag.Var(&fetchTimeout, "timeout", "limit fetching each repository, clone and log walk included, to this long, such as 10m; overrides the config Timeout")
+}
+
+// timeout returns the limit for fetching u: -timeout if set, else the
+// repository or global config, else defaultTimeout. A negative limit in
+// the config disables it.
+func (cfg *config) timeout(u string) time.Duration {
+	switch {
+	case fetchTimeout > 0:
+		return time.Duration(fetchTimeout)
+	case cfg.Repos[u] != nil && cfg.Repos[u].Timeout != nil:
+		return time.Duration(*cfg.Repos[u].Timeout)
+	case cfg.Timeout != 0:
+		return time.Duration(cfg.Timeout)
+	}
+	return defaultTimeout
+}
+
+// withTimeout runs fn with the fetch limit of u. An error caused by the
+// limit says so.
+func (cfg *config) withTimeout(ctx context.Context, u string, fn func(ctx context.Context) error) error {
+	limit := cfg.timeout(u)
+	if limit <= 0 {
+		return fn(ctx)
+	}
+	tctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+	err := fn(tctx)
+	if err != nil && ctx.Err() == nil && tctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %v: %w", limit, err)
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+var (
+	titleFlag    = flag.String("chart-title", "", "template of chart titles, such as '{{.Name}}, {{.TotalCommits}} commits'; overrides the config ChartTitle")
+	subtitleFlag = flag.String("chart-subtitle", "", "template of a second title line; overrides the config ChartSubtitle")
+)
+
+// titleTemplate and subtitleTemplate replace the default chart title when
+// set, from the config or flags.
+var titleTemplate, subtitleTemplate *template.Template
+
+// titleData is passed to the title templates.
+type titleData struct {
+	Name, URL string
+	Metric    string
+	Window    string
+	// Default is the title the chart has without a template.
+	Default      string
+	TotalCommits int
+	Authors      int
+	First, Last  time.Time
+	// FirstYear and LastYear are the years of the oldest and newest
+	// commit.
+	FirstYear, LastYear int
+	Health              int
+	// Tip is the commit the data was read from, if known.
+	Tip string
+	// Now is the date of the run in the locale format.
+	Now string
+}
+
+// parseTitles parses the title templates, the flags taking precedence.
+func parseTitles(title, subtitle string) error {
+	if len(*titleFlag) > 0 {
+		title = *titleFlag
+	}
+	if len(*subtitleFlag) > 0 {
+		subtitle = *subtitleFlag
+	}
+	var err error
+	titleTemplate, err = parseTitle("title", title)
+	if err != nil {
+		return err
+	}
+	subtitleTemplate, err = parseTitle("subtitle", subtitle)
+	return err
+}
+
+func parseTitle(name, text string) (*template.Template, error) {
+	if len(text) == 0 {
+		return nil, nil
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// chartTitle returns the title of a chart of ch, def unless a template is
+// set. A subtitle is added as a second line.
+func chartTitle(ch *chart, metric string, opt chartOptions, def string) (string, error) {
+	if titleTemplate == nil && subtitleTemplate == nil {
+		return def, nil
+	}
+	now := renderTime()
+	wname, _, err := opt.lookupWindow()
+	if err != nil {
+		return "", err
+	}
+	d := titleData{
+		Name:         ch.Name,
+		URL:          opt.url,
+		Metric:       metric,
+		Window:       wname,
+		Default:      def,
+		TotalCommits: len(ch.Commits),
+		Health:       history.NewHealth(ch.Commits, now).Score,
+		Tip:          ch.Tip,
+	}
+	d.Now = now.Format("2006-01-02")
+	if len(opt.loc.DateFormat) > 0 {
+		d.Now = now.Format(opt.loc.DateFormat)
+	}
+	authors := map[string]bool{}
+	for _, c := range ch.Commits {
+		if d.First.IsZero() || c.When.Before(d.First) {
+			d.First = c.When
+		}
+		if c.When.After(d.Last) {
+			d.Last = c.When
+		}
+		for _, p := range c.Authors() {
+			authors[p.Key()] = true
+		}
+	}
+	d.Authors = len(authors)
+	d.FirstYear, d.LastYear = d.First.Year(), d.Last.Year()
+	var lines []string
+	for _, t := range []*template.Template{titleTemplate, subtitleTemplate} {
+		if t == nil {
+			if len(lines) == 0 {
+				lines = append(lines, def)
+			}
+			continue
+		}
+		var b strings.Builder
+		err = t.Execute(&b, d)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+	"unicode/utf8"
+)
+
+type tuiRepo struct {
+	url     string
+	name    string
+	slug    string
+	commits int
+	last    time.Time
+	fetched time.Time
+	spark   string
+	status  string
+	busy    bool // Being fetched.
+}
+
+func newTUIRepo(u, slug string, ch *chart, now time.Time) *tuiRepo {
+	r := &tuiRepo{
+		url:     u,
+		name:    ch.Name,
+		slug:    slug,
+		commits: len(ch.Commits),
+		fetched: ch.Fetched,
+		spark:   sparkline(weeklyCounts(ch, now, sparkWeeks)),
+	}
+	for _, c := range ch.Commits {
+		if c.When.After(r.last) {
+			r.last = c.When
+		}
+	}
+	return r
+}
+
+// tuiResult is the outcome of refreshing a repository, or with status
+// set, its progress.
+type tuiResult struct {
+	index  int
+	repo   *tuiRepo
+	err    error
+	status string
+}
+
+// tui lists the repositories in the terminal. Up and down (or k and j)
+// select a repository, r fetches it again, enter or o opens its chart and
+// q quits.
+func tui(ctx context.Context) error {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	err = cfg.selectRepos(nil)
+	if err != nil {
+		return err
+	}
+	loc, err := lookupLocale(*localeName)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	slugs := cfg.slugs()
+	var repos []*tuiRepo
+	for _, u := range cfg.urls() {
+		ch := cfg.chart(u)
+		err = loadShard(u, ch)
+		if err != nil {
+			return err
+		}
+		repos = append(repos, newTUIRepo(u, slugs[u], ch, now))
+	}
+
+	progress = io.Discard
+	unraw, err := makeRaw(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("terminal: %w", err)
+	}
+	defer unraw()
+	out := bufio.NewWriter(os.Stdout)
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	// Refreshes still running when the list is closed stop, and do not
+	// block sending their result.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buf[:n])
+		}
+	}()
+	results := make(chan tuiResult)
+	send := func(res tuiResult) {
+		select {
+		case results <- res:
+		case <-ctx.Done():
+		}
+	}
+
+	selected, offset := 0, 0
+	message := "↑/↓ select  r refresh  enter open chart  q quit"
+	for {
+		width, height, err := termSize(os.Stdout)
+		if err != nil || height < 4 {
+			width, height = 80, 24
+		}
+		rows := height - 3
+		if selected < offset {
+			offset = selected
+		}
+		if selected >= offset+rows {
+			offset = selected - rows + 1
+		}
+		fmt.Fprint(out, "\x1b[H\x1b[2J")
+		fmt.Fprintf(out, "\x1b[1m%s\x1b[0m\r\n", fitWidth(fmt.Sprintf("%-30s %8s  %-10s  %-*s  %s", cfg.Title, "commits", "last", sparkWeeks, "last 26 weeks", "status"), width))
+		for i := offset; i < len(repos) && i < offset+rows; i++ {
+			r := repos[i]
+			last := "-"
+			if !r.last.IsZero() {
+				last = r.last.Format("2006-01-02")
+			}
+			status := r.status
+			if len(status) == 0 {
+				status = "not fetched"
+				if !r.fetched.IsZero() {
+					status = "fetched " + r.fetched.Format("2006-01-02 15:04")
+				}
+			}
+			line := fitWidth(fmt.Sprintf("%-30s %8d  %-10s  %s  %s", fitWidth(r.name, 30), r.commits, last, r.spark, status), width)
+			if i == selected {
+				line = "\x1b[7m" + line + "\x1b[0m"
+			}
+			fmt.Fprint(out, line, "\r\n")
+		}
+		fmt.Fprintf(out, "\x1b[%d;1H%s", height, fitWidth(message, width))
+		out.Flush()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case res := <-results:
+			var qe *quotaError
+			switch {
+			case len(res.status) > 0:
+				repos[res.index].status = res.status
+			case errors.As(res.err, &qe):
+				repos[res.index].status = "skipped: " + qe.Error()
+				repos[res.index].busy = false
+			case res.err != nil:
+				repos[res.index].status = "error: " + res.err.Error()
+				repos[res.index].busy = false
+			default:
+				repos[res.index] = res.repo
+			}
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			if len(repos) == 0 {
+				if k == "q" {
+					return nil
+				}
+				continue
+			}
+			switch k {
+			case "q", "\x1b":
+				return nil
+			case "\x1b[A", "\x1bOA", "k":
+				if selected > 0 {
+					selected--
+				}
+			case "\x1b[B", "\x1bOB", "j":
+				if selected < len(repos)-1 {
+					selected++
+				}
+			case "\x1b[5~":
+				selected -= rows
+				if selected < 0 {
+					selected = 0
+				}
+			case "\x1b[6~":
+				selected += rows
+				if selected >= len(repos) {
+					selected = len(repos) - 1
+				}
+			case "r":
+				r := repos[selected]
+				if r.busy {
+					continue
+				}
+				r.status, r.busy = "fetching", true
+				i := selected
+				go func() {
+					status := func(s string) { send(tuiResult{index: i, status: s}) }
+					nr, err := tuiRefresh(ctx, cfg, loc, r.url, r.slug, status)
+					send(tuiResult{index: i, repo: nr, err: err})
+				}()
+			case "\r", "\n", "o":
+				r := repos[selected]
+				fn := filepath.Join(outputDir, chartFilename(r.slug, "commits"))
+				if _, err := os.Stat(fn); err != nil {
+					message = "no chart for " + r.name + "; press r to fetch and render it"
+					continue
+				}
+				err = openFile(fn)
+				if err != nil {
+					message = err.Error()
+					continue
+				}
+				message = "opened " + fn
+			}
+		}
+	}
+}
+
+// tuiRefresh fetches u as a run does and renders its commits chart. It
+// reports waiting for the cache lock to status.
+func tuiRefresh(ctx context.Context, cfg *config, loc locale, u, slug string, status func(string)) (*tuiRepo, error) {
+	lock, err := lockCacheWaiting(cacheDir, func(string) {
+		status("waiting for another run")
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer unlockCache(lock)
+	status("fetching")
+	ix, err := openIndex()
+	if err != nil {
+		return nil, err
+	}
+	err = newCacheQuota(cfg.quota().MaxCacheSize).check()
+	if err != nil {
+		return nil, err
+	}
+	limitRate(cfg.quota().RateLimit)
+	ch := cfg.chart(u)
+	now := time.Now()
+	err = fetchOne(ctx, cfg, u, ch, now)
+	if err != nil {
+		return nil, err
+	}
+	err = saveShard(u, ch)
+	if err != nil {
+		return nil, err
+	}
+	ix.set(u, ch)
+	err = ix.write()
+	if err != nil {
+		return nil, err
+	}
+	r := newTUIRepo(u, slug, ch, now)
+	r.status = "refreshed"
+	cutOff(ch)
+	cfg.sanitizeDates(ch, now)
+	if len(ch.Commits) == 0 {
+		return r, nil
+	}
+	err = os.MkdirAll(outputDir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := loadAnnotations(*annotationsFile)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := loadCalendar()
+	if err != nil {
+		return nil, err
+	}
+	opt := chartOptions{loc: loc, notes: annotationsFor(notes, u, ch.Name), cal: cal}
+	return r, display(ch, "commits", opt, filepath.Join(outputDir, chartFilename(slug, "commits")))
+}
+
+// fitWidth cuts s to at most width characters.
+func fitWidth(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	if width < 1 {
+		return ""
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// openFile opens fn with the default application of the system.
+func openFile(fn string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("cmd", "/C", "start", "", fn)
+	case "darwin":
+		cmd = exec.Command("open", fn)
+	default:
+		cmd = exec.Command("xdg-open", fn)
+	}
+	return cmd.Start()
+}
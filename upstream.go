@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var useAPI = flag.Bool("api", true, "ask the GitHub or GitLab API whether a repository changed before fetching it")
+
+// upstream checks GitHub and GitLab repositories for changes with
+// conditional API requests, which do not count against the rate limit
+// when nothing changed. When the limit is reached it waits for the reset
+// instead of failing.
+type upstream struct {
+	client *http.Client
+	// resume is when the rate limit of each host resets, once exhausted.
+	resume map[string]time.Time
+}
+
+func newUpstream() *upstream {
+	return &upstream{
+		client: &http.Client{Timeout: 30 * time.Second},
+		resume: map[string]time.Time{},
+	}
+}
+
+// apiState is what the API reported when the repository was last fetched.
+type apiState struct {
+	ETag   string `json:",omitempty"`
+	Pushed string `json:",omitempty"`
+}
+
+// apiRequest returns the API URL and token for a repository, or false if
+// u is not hosted on GitHub or GitLab.
+func apiRequest(u string) (api, token string, ok bool) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", "", false
+	}
+	path := strings.TrimSuffix(strings.Trim(pu.Path, "/"), ".git")
+	if strings.Count(path, "/") < 1 {
+		return "", "", false
+	}
+	switch strings.ToLower(pu.Host) {
+	case "github.com":
+		if strings.Count(path, "/") != 1 {
+			return "", "", false
+		}
+		return "https://api.github.com/repos/" + path, credential("GITHUB_TOKEN"), true
+	case "gitlab.com":
+		return "https://gitlab.com/api/v4/projects/" + url.PathEscape(path), credential("GITLAB_TOKEN"), true
+	}
+	return "", "", false
+}
+
+// check reports whether u is unchanged since prev, and returns the current
+// state to store after fetching.
+func (up *upstream) check(ctx context.Context, u string, prev apiState) (apiState, bool, error) {
+	api, token, ok := apiRequest(u)
+	if !ok {
+		return apiState{}, false, nil
+	}
+	host := strings.SplitN(strings.TrimPrefix(api, "https://"), "/", 2)[0]
+	for attempt := 0; ; attempt++ {
+		err := up.wait(ctx, host)
+		if err != nil {
+			return prev, false, err
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", api, nil)
+		if err != nil {
+			return prev, false, err
+		}
+		if len(prev.ETag) > 0 {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if len(token) > 0 {
+			if host == "api.github.com" {
+				req.Header.Set("Authorization", "token "+token)
+			} else {
+				req.Header.Set("PRIVATE-TOKEN", token)
+			}
+		}
+		resp, err := up.client.Do(req)
+		if err != nil {
+			return prev, false, err
+		}
+		limited := up.limit(host, resp)
+		if limited && attempt < 3 {
+			resp.Body.Close()
+			continue
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			return prev, true, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return prev, false, fmt.Errorf("%s: %s", api, resp.Status)
+		}
+		var info struct {
+			PushedAt       string `json:"pushed_at"`
+			LastActivityAt string `json:"last_activity_at"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		resp.Body.Close()
+		if err != nil {
+			return prev, false, fmt.Errorf("%s: %w", api, err)
+		}
+		cur := apiState{
+			ETag:   resp.Header.Get("ETag"),
+			Pushed: info.PushedAt + info.LastActivityAt,
+		}
+		unchanged := len(prev.Pushed) > 0 && cur.Pushed == prev.Pushed
+		return cur, unchanged, nil
+	}
+}
+
+// limit records the rate limit headers of resp and reports whether the
+// request was rejected by the limit.
+func (up *upstream) limit(host string, resp *http.Response) bool {
+	h := resp.Header
+	remaining := h.Get("X-RateLimit-Remaining")
+	reset := h.Get("X-RateLimit-Reset")
+	if len(remaining) == 0 {
+		remaining = h.Get("RateLimit-Remaining")
+		reset = h.Get("RateLimit-Reset")
+	}
+	var until time.Time
+	if sec, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		until = time.Now().Add(time.Duration(sec) * time.Second)
+	} else if remaining == "0" {
+		if ts, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			until = time.Unix(ts, 0)
+		}
+	}
+	if !until.IsZero() {
+		up.resume[host] = until
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return !until.IsZero()
+	}
+	return false
+}
+
+// wait pauses until the rate limit of host resets.
+func (up *upstream) wait(ctx context.Context, host string) error {
+	d := time.Until(up.resume[host])
+	if d <= 0 {
+		return nil
+	}
+	fmt.Printf("%s rate limit reached, resuming at %s\n", host, up.resume[host].Format("15:04:05"))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
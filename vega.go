@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var vegaMode = flag.String("vega", "", "also write a Vega-Lite spec per metric chart: inline to embed the data in the spec, or url to write it to a JSON file the spec references")
+
+func checkVegaMode(s string) error {
+	switch s {
+	case "", "inline", "url":
+		return nil
+	}
+	return fmt.Errorf("unknown -vega %q, use inline or url", s)
+}
+
+// vegaFilename returns the file name of the Vega-Lite spec of a chart.
+func vegaFilename(slug, metric string) string {
+	return strings.TrimSuffix(chartFilename(slug, metric), ".png") + ".vl.json"
+}
+
+// vegaDataFilename returns the file name of the data a spec references.
+func vegaDataFilename(slug, metric string) string {
+	return strings.TrimSuffix(chartFilename(slug, metric), ".png") + ".data.json"
+}
+
+// vegaPoint is one value of the chart data.
+type vegaPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// vegaSpec is the part of the Vega-Lite schema the charts use.
+type vegaSpec struct {
+	Schema   string                 `json:"$schema"`
+	Title    interface{}            `json:"title"`
+	Width    int                    `json:"width"`
+	Height   int                    `json:"height"`
+	Data     vegaData               `json:"data"`
+	Mark     map[string]interface{} `json:"mark"`
+	Encoding map[string]interface{} `json:"encoding"`
+}
+
+type vegaData struct {
+	Values []vegaPoint `json:"values,omitempty"`
+	URL    string      `json:"url,omitempty"`
+}
+
+// writeVega writes the Vega-Lite spec of the metric chart of ch to dir as
+// fn, drawn like the PNG chart. With dataFile the values go to that file
+// next to the spec instead of into it. It returns the files written.
+func writeVega(ch *chart, metric string, opt chartOptions, dir, fn, dataFile string) ([]string, error) {
+	wname, w, err := opt.lookupWindow()
+	if err != nil {
+		return nil, err
+	}
+	data, err := seriesPoints(ch, metric, w, opt.cal, renderTime())
+	if err != nil {
+		return nil, err
+	}
+	title, err := chartTitle(ch, metric, opt, ch.Name)
+	if err != nil {
+		return nil, err
+	}
+	style := opt.chartStyle
+	if len(style) == 0 {
+		style = "line"
+	}
+	if style != "line" {
+		data = fillWindows(data, w)
+	}
+	values := make([]vegaPoint, len(data))
+	for i, pt := range data {
+		values[i] = vegaPoint{
+			Date:  time.Unix(int64(pt.X), 0).UTC().Format("2006-01-02"),
+			Value: pt.Y,
+		}
+	}
+
+	mark := map[string]interface{}{"type": style, "tooltip": true}
+	switch style {
+	case "step":
+		mark["type"] = "line"
+		mark["interpolate"] = "step-after"
+	case "area":
+		mark["line"] = true
+		mark["opacity"] = 0.4
+	}
+	if opt.style.Color != nil {
+		mark["color"] = hexColor(opt.style.Color)
+	}
+	if len(opt.style.Dashes) > 0 && style != "bar" && style != "area" {
+		dash := make([]float64, len(opt.style.Dashes))
+		for i, d := range opt.style.Dashes {
+			dash[i] = float64(d)
+		}
+		mark["strokeDash"] = dash
+	}
+	ylabel := fmt.Sprintf("%s (%s)", metric, wname)
+	if opt.cal != nil {
+		ylabel = fmt.Sprintf("%s per business day (%s)", metric, wname)
+	}
+	spec := vegaSpec{
+		Schema: "https://vega.github.io/schema/vega-lite/v5.json",
+		Title:  title,
+		Width:  800,
+		Height: 300,
+		Mark:   mark,
+		Encoding: map[string]interface{}{
+			"x": map[string]interface{}{"field": "date", "type": "temporal", "title": nil},
+			"y": map[string]interface{}{"field": "value", "type": "quantitative", "title": ylabel},
+		},
+	}
+	// Multi line titles are a list of lines in Vega-Lite.
+	if lines := strings.Split(title, "\n"); len(lines) > 1 {
+		spec.Title = lines
+	}
+
+	files := []string{fn}
+	if len(dataFile) > 0 {
+		spec.Data.URL = dataFile
+		err = writeJSONFile(filepath.Join(dir, dataFile), values)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dataFile)
+	} else {
+		spec.Data.Values = values
+	}
+	return files, writeJSONFile(filepath.Join(dir, fn), spec)
+}
+
+// writeJSONFile writes v as indented JSON to location.
+func writeJSONFile(location string, v interface{}) error {
+	return writeFileAtomic(location, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(v)
+	})
+}
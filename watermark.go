@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // Logo formats.
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var (
+	watermarkText = flag.String("watermark", "", "attribution text drawn in a corner of every chart image; overrides the config Watermark Text")
+	watermarkLogo = flag.String("watermark-logo", "", "PNG or JPEG logo drawn in a corner of every chart image; overrides the config Watermark Logo")
+)
+
+// watermarkConfig is the attribution drawn on chart images.
+type watermarkConfig struct {
+	Text string `json:",omitempty"`
+	// Logo is a PNG or JPEG file, relative to the config file, drawn
+	// one centimeter high.
+	Logo string `json:",omitempty"`
+	// Position is bottom-right, the default, bottom-left, top-right or
+	// top-left.
+	Position string `json:",omitempty"`
+}
+
+// watermark is drawn by writeCanvas when set.
+var watermark struct {
+	text string
+	logo image.Image
+	top  bool
+	left bool
+}
+
+var watermarkColor = color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xa0}
+
+// loadWatermark sets the watermark from wc, relative to dir, and the
+// flags.
+func loadWatermark(wc *watermarkConfig, dir string) error {
+	var c watermarkConfig
+	if wc != nil {
+		c = *wc
+		if len(c.Logo) > 0 && !filepath.IsAbs(c.Logo) {
+			c.Logo = filepath.Join(dir, c.Logo)
+		}
+	}
+	if len(*watermarkText) > 0 {
+		c.Text = *watermarkText
+	}
+	if len(*watermarkLogo) > 0 {
+		c.Logo = *watermarkLogo
+	}
+	watermark.text = c.Text
+	watermark.logo = nil
+	switch c.Position {
+	case "", "bottom-right":
+		watermark.top, watermark.left = false, false
+	case "bottom-left":
+		watermark.top, watermark.left = false, true
+	case "top-right":
+		watermark.top, watermark.left = true, false
+	case "top-left":
+		watermark.top, watermark.left = true, true
+	default:
+		return fmt.Errorf("watermark: unknown position %q", c.Position)
+	}
+	if len(c.Logo) == 0 {
+		return nil
+	}
+	f, err := os.Open(c.Logo)
+	if err != nil {
+		return fmt.Errorf("watermark: %w", err)
+	}
+	defer f.Close()
+	watermark.logo, _, err = image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("watermark %s: %w", c.Logo, err)
+	}
+	return nil
+}
+
+// drawWatermark draws the logo in the configured corner of dc, with the
+// text beside it.
+func drawWatermark(dc draw.Canvas) error {
+	if len(watermark.text) == 0 && watermark.logo == nil {
+		return nil
+	}
+	margin := 0.3 * vg.Centimeter
+	x, y := dc.Max.X-margin, dc.Min.Y+margin
+	if watermark.left {
+		x = dc.Min.X + margin
+	}
+	if watermark.top {
+		y = dc.Max.Y - margin
+	}
+	// dir moves away from the corner horizontally.
+	dir := vg.Length(-1)
+	if watermark.left {
+		dir = 1
+	}
+	if img := watermark.logo; img != nil {
+		b := img.Bounds()
+		h := vg.Centimeter
+		w := h * vg.Length(b.Dx()) / vg.Length(b.Dy())
+		r := vg.Rectangle{Min: vg.Point{X: x, Y: y}, Max: vg.Point{X: x + dir*w, Y: y + h}}
+		if watermark.top {
+			r.Min.Y, r.Max.Y = y-h, y
+		}
+		if !watermark.left {
+			r.Min.X, r.Max.X = r.Max.X, r.Min.X
+		}
+		dc.DrawImage(r, img)
+		x += dir * (w + 0.2*vg.Centimeter)
+		if watermark.top {
+			y -= h / 2
+		} else {
+			y += h / 2
+		}
+	}
+	if len(watermark.text) > 0 {
+		fnt := plot.DefaultFont
+		fnt.Size = vg.Points(9)
+		sty := draw.TextStyle{Color: watermarkColor, Font: fnt, XAlign: draw.XRight, YAlign: draw.YBottom, Handler: plot.DefaultTextHandler}
+		if watermark.left {
+			sty.XAlign = draw.XLeft
+		}
+		switch {
+		case watermark.logo != nil:
+			sty.YAlign = draw.YCenter
+		case watermark.top:
+			sty.YAlign = draw.YTop
+		}
+		dc.FillText(sty, vg.Point{X: x, Y: y}, watermark.text)
+	}
+	return nil
+}
+
+// savePlot draws p at the size of the metric charts and writes it to
+// filename, in the format of its extension.
+func savePlot(p *plot.Plot, filename string) error {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	c, err := draw.NewFormattedCanvas(40*vg.Centimeter, 20*vg.Centimeter, format)
+	if err != nil {
+		return err
+	}
+	p.Draw(draw.New(c))
+	return writeCanvas(c, filename)
+}
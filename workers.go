@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var leaseTime = flag.Duration("lease", 45*time.Minute, "time a worker has to fetch a repository before the coordinator hands it to another")
+
+const (
+	// jobTries is how often a repository is handed out before the
+	// coordinator records it as failed.
+	jobTries = 3
+	// workerPoll is how long a worker waits when all remaining jobs are
+	// leased to others.
+	workerPoll = 10 * time.Second
+)
+
+// job is a repository handed to a worker.
+type job struct {
+	URL  string
+	Name string
+}
+
+// jobResult is what a worker reports for a job.
+type jobResult struct {
+	URL     string
+	Entry   *indexEntry `json:",omitempty"`
+	Error   string      `json:",omitempty"`
+	Skipped string      `json:",omitempty"`
+	Seconds float64
+	Stats   fetchStats
+}
+
+// jobQueue holds the repositories of a coordinator run.
+type jobQueue struct {
+	mu      sync.Mutex
+	waiting []job
+	leased  map[string]time.Time // Lease expiry by URL.
+	jobs    map[string]job
+	tries   map[string]int
+	left    int // Jobs neither done nor failed.
+	done    chan struct{}
+}
+
+func newJobQueue(list []job) *jobQueue {
+	q := &jobQueue{
+		waiting: list,
+		leased:  map[string]time.Time{},
+		jobs:    map[string]job{},
+		tries:   map[string]int{},
+		left:    len(list),
+		done:    make(chan struct{}),
+	}
+	for _, j := range list {
+		q.jobs[j.URL] = j
+	}
+	if q.left == 0 {
+		close(q.done)
+	}
+	return q
+}
+
+// next leases the next job. ok is false if there is none now; finished
+// is set if there will be none.
+func (q *jobQueue) next(now time.Time) (j job, ok, finished bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for u, exp := range q.leased {
+		if now.After(exp) {
+			fmt.Fprintln(progress, "lease expired:", u)
+			delete(q.leased, u)
+			q.waiting = append(q.waiting, q.jobs[u])
+		}
+	}
+	if len(q.waiting) == 0 {
+		return job{}, false, q.left == 0
+	}
+	j = q.waiting[0]
+	q.waiting = q.waiting[1:]
+	q.leased[j.URL] = now.Add(*leaseTime)
+	q.tries[j.URL]++
+	return j, true, false
+}
+
+// finish records the result of a job. It reports if the job is done, as
+// opposed to queued again after an error.
+func (q *jobQueue) finish(res jobResult) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.jobs[res.URL]; !ok {
+		return false
+	}
+	if _, ok := q.leased[res.URL]; !ok {
+		// The lease expired and the job was queued again; a result still
+		// completes it.
+		for i, j := range q.waiting {
+			if j.URL == res.URL {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(q.leased, res.URL)
+	if len(res.Error) > 0 && q.tries[res.URL] < jobTries {
+		q.waiting = append(q.waiting, q.jobs[res.URL])
+		return false
+	}
+	delete(q.jobs, res.URL)
+	q.left--
+	if q.left == 0 {
+		close(q.done)
+	}
+	return true
+}
+
+// checkWorkerSecret reports if r carries the WORKER_SECRET, if one is
+// set.
+func checkWorkerSecret(r *http.Request) bool {
+	secret := credential("WORKER_SECRET")
+	if len(secret) == 0 {
+		return true
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// coordinate queues the repositories that are due for workers to fetch
+// into the shared store, and writes the index as they report back:
+//
+//	gitgraph -store s3://bucket/gitgraph coordinate [URL... | -]
+//
+// It returns when every repository is fetched or has failed.
+func coordinate(ctx context.Context) error {
+	if !sharedStore() {
+		return errors.New("coordinate needs a -store the workers share")
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		cfg.TTL = ttl
+	}
+	err = cfg.selectRepos(commandArgs())
+	if err != nil {
+		return err
+	}
+	sum := newSummary()
+	lock, err := lockCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	defer unlockCache(lock)
+	ix, err := openIndex()
+	if err != nil {
+		return err
+	}
+	var list []job
+	for _, u := range cfg.dueRepos(ctx, ix, sum, time.Now()) {
+		list = append(list, job{URL: u, Name: cfg.Repos[u].Name})
+	}
+	fmt.Printf("queued %d repositories\n", len(list))
+	q := newJobQueue(list)
+
+	var mu sync.Mutex // Guards ix, sum and pending.
+	pending := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs/next", func(w http.ResponseWriter, r *http.Request) {
+		if !checkWorkerSecret(r) {
+			http.Error(w, "invalid worker secret", http.StatusUnauthorized)
+			return
+		}
+		j, ok, finished := q.next(time.Now())
+		switch {
+		case finished:
+			w.Header().Set("X-Queue", "done")
+			w.WriteHeader(http.StatusNoContent)
+		case !ok:
+			w.Header().Set("Retry-After", strconv.Itoa(int(workerPoll/time.Second)))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, j)
+		}
+	})
+	mux.HandleFunc("/jobs/done", func(w http.ResponseWriter, r *http.Request) {
+		if !checkWorkerSecret(r) {
+			http.Error(w, "invalid worker secret", http.StatusUnauthorized)
+			return
+		}
+		var res jobResult
+		err := json.NewDecoder(r.Body).Decode(&res)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(res.Error) > 0 {
+			fmt.Fprintf(os.Stderr, "fetch %s: %s\n", res.URL, res.Error)
+		}
+		if !q.finish(res) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		rs := sum.repo(res.URL, cfg.Repos[res.URL].Name)
+		rs.FetchSeconds = res.Seconds
+		rs.addFetchStats(res.Stats)
+		if len(res.Skipped) > 0 {
+			rs.skip(errors.New(res.Skipped))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rs.Fetched = true
+		if len(res.Error) > 0 || res.Entry == nil {
+			rs.fail(errors.New(res.Error))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		before := rs.Commits
+		if old := ix[res.URL]; old != nil {
+			res.Entry.API = old.API
+		}
+		ix[res.URL] = res.Entry
+		rs.Commits = res.Entry.Commits
+		if rs.Commits > before {
+			rs.NewCommits = rs.Commits - before
+		}
+		pending++
+		if pending >= indexFlush {
+			err = ix.write()
+			if err != nil {
+				log.Print(err)
+			} else {
+				pending = 0
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	hs := &http.Server{Addr: *listenAddr, Handler: mux}
+	errc := make(chan error, 1)
+	go func() {
+		fmt.Println("coordinating on", *listenAddr)
+		errc <- hs.ListenAndServe()
+	}()
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+	case <-q.done:
+		// Let the last workers see that the queue is done.
+		time.Sleep(workerPoll)
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	hs.Shutdown(sctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	werr := ix.write()
+	if err == nil {
+		err = werr
+	}
+	if len(*summaryOut) > 0 {
+		serr := sum.write(*summaryOut)
+		if err == nil {
+			err = serr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return runStatus(sum, nil)
+}
+
+// work fetches the repositories a coordinator hands out into the shared
+// store until its queue is done:
+//
+//	gitgraph -store s3://bucket/gitgraph work http://coordinator:8080
+func work(ctx context.Context) error {
+	args := flag.Args()[1:]
+	if len(args) != 1 {
+		return errors.New("usage: work COORDINATOR_URL")
+	}
+	if !sharedStore() {
+		return errors.New("work needs a -store the coordinator shares")
+	}
+	base := strings.TrimSuffix(args[0], "/")
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	post := func(path string, v interface{}) (*http.Response, error) {
+		var b []byte
+		if v != nil {
+			var err error
+			b, err = json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", base+path, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if secret := credential("WORKER_SECRET"); len(secret) > 0 {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return http.DefaultClient.Do(req)
+	}
+	// Each worker keeps its own mirrors, so the cache quota is its own.
+	quota := newCacheQuota(cfg.quota().MaxCacheSize)
+	limitRate(cfg.quota().RateLimit)
+	n := 0
+	for ctx.Err() == nil {
+		resp, err := post("/jobs/next", nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusNoContent {
+			resp.Body.Close()
+			if resp.Header.Get("X-Queue") == "done" {
+				break
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(workerPoll):
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("%s/jobs/next: %s", base, resp.Status)
+		}
+		var j job
+		err = json.NewDecoder(resp.Body).Decode(&j)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if cfg.Repos[j.URL] == nil {
+			cfg.Repos[j.URL] = &repoConfig{Name: j.Name}
+		}
+		ch := cfg.chart(j.URL)
+		start := time.Now()
+		res := jobResult{URL: j.URL}
+		err = quota.check()
+		if err == nil {
+			err = fetchOne(withFetchStats(ctx, &res.Stats), cfg, j.URL, ch, time.Now())
+			quota.add(res.Stats)
+		}
+		if err == nil {
+			err = saveShard(j.URL, ch)
+		}
+		var qe *quotaError
+		switch {
+		case errors.As(err, &qe):
+			fmt.Fprintf(progress, "skip %s: %v\n", j.URL, err)
+			res.Skipped = err.Error()
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "fetch %s: %v\n", j.URL, err)
+			res.Error = err.Error()
+		default:
+			ix := cacheIndex{}
+			ix.set(j.URL, ch)
+			res.Entry = ix[j.URL]
+			n++
+		}
+		res.Seconds = time.Since(start).Seconds()
+		resp, err = post("/jobs/done", res)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	fmt.Printf("fetched %d repositories\n", n)
+	return ctx.Err()
+}
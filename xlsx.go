@@ -0,0 +1,301 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// exportXLSX writes a workbook with one worksheet per repository. Each
+// sheet lists the weekly value of every metric and holds a line chart of
+// them.
+func exportXLSX(ctx context.Context, d *exportData, out string) error {
+	return writeFileAtomic(out, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		err := writeWorkbook(zw, d)
+		if err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+type xlsxSheet struct {
+	Name    string
+	Title   string
+	Metrics []string
+	Weeks   []time.Time
+	// Values holds a column per metric, indexed like Weeks. NaN is an
+	// empty cell.
+	Values [][]float64
+}
+
+func writeWorkbook(zw *zip.Writer, d *exportData) error {
+	var sheets []*xlsxSheet
+	used := map[string]bool{}
+	for _, u := range d.Charts.urls() {
+		ch := d.Charts[u]
+		if len(ch.Commits) == 0 {
+			continue
+		}
+		sheets = append(sheets, newXLSXSheet(ch, d.Metrics, sheetName(ch.Name, used)))
+	}
+	if len(sheets) == 0 {
+		return fmt.Errorf("no cached commits to export")
+	}
+
+	files := map[string]string{
+		"[Content_Types].xml":        xlsxContentTypes(len(sheets)),
+		"_rels/.rels":                xlsxRootRels,
+		"xl/workbook.xml":            xlsxWorkbook(sheets),
+		"xl/_rels/workbook.xml.rels": xlsxWorkbookRels(len(sheets)),
+		"xl/styles.xml":              xlsxStyles,
+	}
+	for i, sh := range sheets {
+		n := strconv.Itoa(i + 1)
+		files["xl/worksheets/sheet"+n+".xml"] = sh.worksheet()
+		files["xl/worksheets/_rels/sheet"+n+".xml.rels"] = xlsxRels("drawing", "../drawings/drawing"+n+".xml")
+		files["xl/drawings/drawing"+n+".xml"] = sh.drawing()
+		files["xl/drawings/_rels/drawing"+n+".xml.rels"] = xlsxRels("chart", "../charts/chart"+n+".xml")
+		files["xl/charts/chart"+n+".xml"] = sh.chart()
+	}
+	// Sorting keeps the content types first, as required.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, files[name])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newXLSXSheet(ch *chart, metrics []string, name string) *xlsxSheet {
+	sh := &xlsxSheet{Name: name, Title: ch.Name, Metrics: metrics}
+	series := make([]map[int64]float64, len(metrics))
+	var first, last time.Time
+	for i, metric := range metrics {
+		series[i] = map[int64]float64{}
+		for _, pt := range history.Series(ch.Commits, history.Weekly, ch.metric(metric)) {
+			series[i][pt.Time.Unix()] = pt.Value
+			if first.IsZero() || pt.Time.Before(first) {
+				first = pt.Time
+			}
+			if pt.Time.After(last) {
+				last = pt.Time
+			}
+		}
+	}
+	for t := first; !t.After(last); t = t.AddDate(0, 0, 7) {
+		sh.Weeks = append(sh.Weeks, t)
+	}
+	sh.Values = make([][]float64, len(metrics))
+	for i := range metrics {
+		col := make([]float64, len(sh.Weeks))
+		for j, t := range sh.Weeks {
+			v, ok := series[i][t.Unix()]
+			if !ok {
+				v = math.NaN()
+			}
+			col[j] = v
+		}
+		sh.Values[i] = col
+	}
+	return sh
+}
+
+// sheetName returns a unique worksheet name of at most 31 characters.
+func sheetName(name string, used map[string]bool) string {
+	clean := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	clean = strings.Trim(clean, "'")
+	if len(clean) == 0 {
+		clean = "Sheet"
+	}
+	trim := func(s string, n int) string {
+		r := []rune(s)
+		if len(r) > n {
+			r = r[:n]
+		}
+		return string(r)
+	}
+	s := trim(clean, 31)
+	for i := 2; used[strings.ToLower(s)]; i++ {
+		suffix := " (" + strconv.Itoa(i) + ")"
+		s = trim(clean, 31-len(suffix)) + suffix
+	}
+	used[strings.ToLower(s)] = true
+	return s
+}
+
+// column returns the column letters of the zero based index i.
+func column(i int) string {
+	s := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = string(rune('A'+(i-1)%26)) + s
+	}
+	return s
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// excelDate returns the spreadsheet serial day number of t.
+func excelDate(t time.Time) float64 {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return t.Sub(epoch).Hours() / 24
+}
+
+func (sh *xlsxSheet) ref() string {
+	return "'" + strings.ReplaceAll(sh.Name, "'", "''") + "'"
+}
+
+func (sh *xlsxSheet) worksheet() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	b.WriteString(`<cols><col min="1" max="1" width="12" customWidth="1"/></cols><sheetData>`)
+	b.WriteString(`<row r="1"><c r="A1" t="inlineStr"><is><t>Week</t></is></c>`)
+	for i, metric := range sh.Metrics {
+		fmt.Fprintf(&b, `<c r="%s1" t="inlineStr"><is><t>%s</t></is></c>`, column(i+1), xmlText(metric))
+	}
+	b.WriteString(`</row>`)
+	for j, t := range sh.Weeks {
+		r := j + 2
+		fmt.Fprintf(&b, `<row r="%d"><c r="A%d" s="1"><v>%s</v></c>`, r, r, strconv.FormatFloat(excelDate(t), 'f', -1, 64))
+		for i, col := range sh.Values {
+			if math.IsNaN(col[j]) {
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s%d"><v>%s</v></c>`, column(i+1), r, strconv.FormatFloat(col[j], 'f', -1, 64))
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData><drawing r:id="rId1"/></worksheet>`)
+	return b.String()
+}
+
+func (sh *xlsxSheet) drawing() string {
+	from := len(sh.Metrics) + 2
+	return xml.Header + `<xdr:wsDr xmlns:xdr="http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart">` +
+		`<xdr:twoCellAnchor>` +
+		`<xdr:from><xdr:col>` + strconv.Itoa(from) + `</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>1</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>` +
+		`<xdr:to><xdr:col>` + strconv.Itoa(from+14) + `</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>24</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:to>` +
+		`<xdr:graphicFrame macro=""><xdr:nvGraphicFramePr><xdr:cNvPr id="2" name="Chart 1"/><xdr:cNvGraphicFramePr/></xdr:nvGraphicFramePr>` +
+		`<xdr:xfrm><a:off x="0" y="0"/><a:ext cx="0" cy="0"/></xdr:xfrm>` +
+		`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart"><c:chart r:id="rId1"/></a:graphicData></a:graphic>` +
+		`</xdr:graphicFrame><xdr:clientData/></xdr:twoCellAnchor></xdr:wsDr>`
+}
+
+func (sh *xlsxSheet) chart() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	b.WriteString(`<c:chart><c:title><c:tx><c:rich><a:bodyPr/><a:p><a:r><a:t>`)
+	b.WriteString(xmlText(sh.Title))
+	b.WriteString(`</a:t></a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title><c:autoTitleDeleted val="0"/>`)
+	b.WriteString(`<c:plotArea><c:layout/><c:lineChart><c:grouping val="standard"/><c:varyColors val="0"/>`)
+	last := strconv.Itoa(len(sh.Weeks) + 1)
+	for i := range sh.Metrics {
+		col := "$" + column(i+1) + "$"
+		fmt.Fprintf(&b, `<c:ser><c:idx val="%d"/><c:order val="%d"/>`, i, i)
+		fmt.Fprintf(&b, `<c:tx><c:strRef><c:f>%s!%s1</c:f></c:strRef></c:tx>`, xmlText(sh.ref()), col)
+		b.WriteString(`<c:marker><c:symbol val="none"/></c:marker>`)
+		fmt.Fprintf(&b, `<c:cat><c:numRef><c:f>%s!$A$2:$A$%s</c:f></c:numRef></c:cat>`, xmlText(sh.ref()), last)
+		fmt.Fprintf(&b, `<c:val><c:numRef><c:f>%s!%s2:%s%s</c:f></c:numRef></c:val>`, xmlText(sh.ref()), col, col, last)
+		b.WriteString(`<c:smooth val="0"/></c:ser>`)
+	}
+	b.WriteString(`<c:marker val="1"/><c:axId val="1"/><c:axId val="2"/></c:lineChart>`)
+	b.WriteString(`<c:dateAx><c:axId val="1"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="b"/>`)
+	b.WriteString(`<c:numFmt formatCode="yyyy-mm-dd" sourceLinked="0"/><c:crossAx val="2"/><c:auto val="1"/><c:lblOffset val="100"/><c:baseTimeUnit val="days"/></c:dateAx>`)
+	b.WriteString(`<c:valAx><c:axId val="2"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="l"/><c:majorGridlines/>`)
+	b.WriteString(`<c:numFmt formatCode="General" sourceLinked="1"/><c:crossAx val="1"/></c:valAx>`)
+	b.WriteString(`</c:plotArea><c:legend><c:legendPos val="b"/><c:overlay val="0"/></c:legend><c:plotVisOnly val="1"/><c:dispBlanksAs val="gap"/></c:chart></c:chartSpace>`)
+	return b.String()
+}
+
+func xlsxContentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+		fmt.Fprintf(&b, `<Override PartName="/xl/drawings/drawing%d.xml" ContentType="application/vnd.openxmlformats-officedocument.drawing+xml"/>`, i)
+		fmt.Fprintf(&b, `<Override PartName="/xl/charts/chart%d.xml" ContentType="application/vnd.openxmlformats-officedocument.drawingml.chart+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+const xlsxRelNS = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/"
+
+var xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="` + xlsxRelNS + `officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+// xlsxRels returns a relationship part with a single relationship.
+func xlsxRels(kind, target string) string {
+	return xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="` + xlsxRelNS + kind + `" Target="` + target + `"/></Relationships>`
+}
+
+func xlsxWorkbook(sheets []*xlsxSheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sh := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlText(sh.Name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+func xlsxWorkbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="%sworksheet" Target="worksheets/sheet%d.xml"/>`, i, xlsxRelNS, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="%sstyles" Target="styles.xml"/>`, sheets+1, xlsxRelNS)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// xlsxStyles has the default style and a date style at index 1.
+var xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>` +
+	`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`</styleSheet>`
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+)
+
+// unzipText lists the files of the zip archive b with their content, in
+// archive order, so the parts are compared rather than their compressed
+// bytes.
+func unzipText(t *testing.T, b []byte) []byte {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.WriteString("== " + f.Name + " ==\n")
+		_, err = io.Copy(&out, r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.WriteString("\n")
+	}
+	return out.Bytes()
+}
+
+func TestExportXLSX(t *testing.T) {
+	day := time.Date(2022, time.January, 3, 9, 30, 0, 0, time.UTC)
+	charts := FileType{
+		"https://example.com/a.git": &chart{Name: "Alpha", Commits: []history.Commit{
+			{Hash: "a3", When: day.AddDate(0, 0, 15), Author: "Ann", Files: []history.File{{Name: "main.go", Add: 10, Del: 2}}},
+			{Hash: "a2", When: day.AddDate(0, 0, 1), Author: "Bob", Files: []history.File{{Name: "doc.md", Add: 4}}},
+			{Hash: "a1", When: day, Author: "Ann", Files: []history.File{{Name: "main.go", Add: 30}}},
+		}},
+		"https://example.com/b.git": &chart{Name: "Beta & <Gamma>", Commits: []history.Commit{
+			{Hash: "b1", When: day.AddDate(0, 0, 8), Author: "Cé", Files: []history.File{{Name: "x.c", Add: 5, Del: 5}}},
+		}},
+		"https://example.com/empty.git": &chart{Name: "Empty"},
+	}
+	d := &exportData{Charts: charts, Slugs: charts.slugs(), Metrics: []string{"commits"}}
+	out := filepath.Join(t.TempDir(), "commits.xlsx")
+	err := exportXLSX(context.Background(), d, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "commits.xlsx.txt", unzipText(t, b))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"time"
+
+	"github.com/kardianos/gitgraph/history"
+	"gonum.org/v1/plot"
+)
+
+var (
+	yPad    = flag.Float64("y-pad", 0.05, "space above the highest point of a chart, as a fraction of its Y range")
+	yZero   = flag.Bool("y-zero", true, "start the Y axis of charts at zero")
+	sharedY = flag.Bool("shared-y", false, "use the same Y range for the charts of a metric across all repositories; groups can set SharedY instead")
+)
+
+// setYRange sets the Y axis of p after the data is added: from zero with
+// -y-zero, up to the larger of maxY and shared with -y-pad added.
+func setYRange(p *plot.Plot, maxY, shared float64) {
+	top := math.Max(maxY, shared)
+	if *yZero {
+		p.Y.Min = math.Min(p.Y.Min, 0)
+	}
+	if top <= p.Y.Min {
+		top = p.Y.Min + 1
+	}
+	p.Y.Max = top + (top-p.Y.Min)**yPad
+}
+
+// sharedYMax returns the highest value of each metric over the
+// repositories that share a Y range, by URL and metric: all of them with
+// -shared-y, else the members of groups with SharedY. Repositories that
+// fail to load are left out; the render loop reports them.
+func sharedYMax(cfg *config, metrics []string, cal *history.Calendar, now time.Time) (map[string]map[string]float64, error) {
+	var sets [][]string
+	if *sharedY {
+		sets = append(sets, cfg.urls())
+	}
+	for _, g := range cfg.Groups {
+		if !g.SharedY {
+			continue
+		}
+		urls, err := cfg.groupURLs(g)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, urls)
+	}
+	if len(sets) == 0 {
+		return nil, nil
+	}
+	// Each repository is read once; its maxima are kept.
+	repoMax := map[string]map[string]float64{}
+	for _, urls := range sets {
+		for _, u := range urls {
+			if _, ok := repoMax[u]; ok {
+				continue
+			}
+			ch := cfg.chart(u)
+			if loadShard(u, ch) != nil {
+				continue
+			}
+			cfg.sanitizeDates(ch, now)
+			opt := chartOptions{cal: cal, window: cfg.window(u)}
+			_, w, err := opt.lookupWindow()
+			if err != nil {
+				return nil, err
+			}
+			m := map[string]float64{}
+			for _, metric := range metrics {
+				data, err := seriesPoints(ch, metric, w, cal, now)
+				if err != nil {
+					return nil, err
+				}
+				for _, pt := range data {
+					m[metric] = math.Max(m[metric], pt.Y)
+				}
+			}
+			repoMax[u] = m
+		}
+	}
+	shared := map[string]map[string]float64{}
+	for _, urls := range sets {
+		setMax := map[string]float64{}
+		for _, u := range urls {
+			for metric, v := range repoMax[u] {
+				setMax[metric] = math.Max(setMax[metric], v)
+			}
+		}
+		for _, u := range urls {
+			if shared[u] == nil {
+				shared[u] = map[string]float64{}
+			}
+			for metric, v := range setMax {
+				shared[u][metric] = math.Max(shared[u][metric], v)
+			}
+		}
+	}
+	return shared, nil
+}
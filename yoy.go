@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+var yoyCharts = flag.Bool("yoy", false, "also render a year-over-year chart of each metric, one line per calendar year")
+
+// yoyFilename returns the file name of the year-over-year chart of a metric.
+func yoyFilename(slug, metric string) string {
+	return strings.TrimSuffix(chartFilename(slug, metric), ".png") + "--yoy.png"
+}
+
+// yoyYear is the leap year every point is moved into so all years share
+// one January to December axis.
+const yoyYear = 2000
+
+// displayYOY draws each calendar year of the metric as its own line over
+// a January to December axis. Older years are drawn lighter.
+func displayYOY(ch *chart, metric string, opt chartOptions, filename string) error {
+	now := renderTime()
+	wname, w, err := opt.lookupWindow()
+	if err != nil {
+		return err
+	}
+	data, err := seriesPoints(ch, metric, w, opt.cal, now)
+	if err != nil {
+		return err
+	}
+	var years []int
+	byYear := map[int]plotter.XYs{}
+	for _, pt := range data {
+		t := time.Unix(int64(pt.X), 0).UTC()
+		if _, ok := byYear[t.Year()]; !ok {
+			years = append(years, t.Year())
+		}
+		x := time.Date(yoyYear, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		byYear[t.Year()] = append(byYear[t.Year()], plotter.XY{X: float64(x.Unix()), Y: pt.Y})
+	}
+
+	p := plot.New()
+	addProvenance(p, ch)
+	p.Title.Text, err = chartTitle(ch, metric, opt, ch.Name+" (year over year)")
+	if err != nil {
+		return err
+	}
+	p.Y.Label.Text = opt.loc.YLabel
+	if metric != "commits" || wname != "weekly" {
+		p.Y.Label.Text = fmt.Sprintf("%s (%s)", metric, wname)
+	}
+	p.X.Min = float64(time.Date(yoyYear, time.January, 1, 0, 0, 0, 0, time.UTC).Unix())
+	p.X.Max = float64(time.Date(yoyYear+1, time.January, 1, 0, 0, 0, 0, time.UTC).Unix())
+	p.X.Tick.Marker = plot.TimeTicks{
+		Ticker: plot.TickerFunc(func(min, max float64) []plot.Tick {
+			list := make([]plot.Tick, 12)
+			for i := range list {
+				// TimeTicks only formats ticks that have a label.
+				list[i] = plot.Tick{
+					Value: float64(time.Date(yoyYear, time.Month(i+1), 1, 0, 0, 0, 0, time.UTC).Unix()),
+					Label: "|",
+				}
+			}
+			return list
+		}),
+		Format: "Jan",
+	}
+	p.Add(plotter.NewGrid())
+	p.Legend.Top = true
+
+	for i, year := range years {
+		line, err := plotter.NewLine(byYear[year])
+		if err != nil {
+			return err
+		}
+		// Fade from light grey for the oldest year to full green for the
+		// latest.
+		f := 1.0
+		if len(years) > 1 {
+			f = float64(i) / float64(len(years)-1)
+		}
+		line.Color = color.RGBA{
+			R: uint8(0xd0 * (1 - f)),
+			G: uint8(0xd0 + 0x2f*f),
+			B: uint8(0xd0 * (1 - f)),
+			A: 0xff,
+		}
+		if i == len(years)-1 {
+			line.Width = 2 * vg.Points(1)
+		}
+		p.Add(line)
+		p.Legend.Add(strconv.Itoa(year), line)
+	}
+	return savePlot(p, filename)
+}